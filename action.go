@@ -0,0 +1,62 @@
+package main
+
+import (
+	"runtime"
+	"time"
+)
+
+// builtinActions are the named commands on_work_end understands, per OS.
+var builtinActions = map[string]map[string][]string{
+	"linux": {
+		"lock":        {"loginctl", "lock-session"},
+		"display-off": {"xset", "dpms", "force", "off"},
+	},
+	"darwin": {
+		// The lock screen shortcut; System Events needs the accessibility
+		// permission to send it.
+		"lock":        {"osascript", "-e", `tell application "System Events" to keystroke "q" using {control down, command down}`},
+		"display-off": {"pmset", "displaysleepnow"},
+	},
+}
+
+// workEndAction returns the command breaks.on_work_end asks for: a
+// built-in action or, for anything else, a shell command. It is nil when
+// nothing is configured.
+func workEndAction(cfg config) []string {
+	action := cfg.get("breaks.on_work_end", "")
+	if action == "" {
+		return nil
+	}
+	if args, ok := builtinActions[runtime.GOOS][action]; ok {
+		return args
+	}
+	return []string{"sh", "-c", action}
+}
+
+// runWorkEnd runs breaks.on_work_end now that t's work interval has ended.
+// A blocking hook holds t until it succeeds; if it fails, t stays held and
+// an alert is raised.
+func (d *daemon) runWorkEnd(t *timer, now time.Time) {
+	if d.workEndPolicy.block && dryRun == nil {
+		t.hold(now)
+	}
+	runHook("on_work_end", d.workEndPolicy, commandHook(d.workEnd), func(err error) {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		now := time.Now()
+		if d.timers[t.name] != t || !t.held {
+			return
+		}
+		if err != nil {
+			d.alerts.start(now, t.name+": on_work_end failed, resume when ready")
+			return
+		}
+		t.resume(now)
+		d.refresh(now)
+		d.publish(now)
+		select {
+		case d.changed <- struct{}{}:
+		default:
+		}
+	})
+}
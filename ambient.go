@@ -0,0 +1,50 @@
+package main
+
+import (
+	"log"
+	"os/exec"
+	"syscall"
+)
+
+// ambient runs the configured ambient sound command (white noise, a lo-fi
+// stream, ...) while work is being timed.
+type ambient struct {
+	command   string
+	cmd       *exec.Cmd
+	simulated bool // playing in a dry run
+}
+
+// sync starts or stops the ambient command so that it runs exactly when
+// want is set.
+func (a *ambient) sync(want bool) {
+	switch {
+	case a.command == "":
+	case dryRun != nil:
+		if want != a.simulated {
+			a.simulated = want
+			simulated("ambient sound %s", map[bool]string{true: "started", false: "stopped"}[want])
+		}
+	case want && a.cmd == nil:
+		// Run in its own process group so players spawned by the command
+		// are killed along with it.
+		cmd := exec.Command("sh", "-c", a.command)
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+		if err := cmd.Start(); err != nil {
+			log.Printf("Failed to start ambient sound: %v", err)
+			return
+		}
+		a.cmd = cmd
+		go cmd.Wait()
+	case !want && a.cmd != nil:
+		a.stop()
+	}
+}
+
+// stop kills the ambient command and everything it started.
+func (a *ambient) stop() {
+	if a.cmd == nil {
+		return
+	}
+	syscall.Kill(-a.cmd.Process.Pid, syscall.SIGTERM)
+	a.cmd = nil
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// awayPeriod is time set aside with `pomo away`, e.g. a vacation or sick
+// leave, which goals leave out. Days are local dates, YYYY-MM-DD, so that
+// they stay the same days whatever the time zone.
+type awayPeriod struct {
+	From  string `json:"from"`            // the first day away
+	Until string `json:"until,omitempty"` // the first day back; "" until further notice
+}
+
+// includes reports whether day, a YYYY-MM-DD date, is spent away.
+func (p awayPeriod) includes(day string) bool {
+	return day >= p.From && (p.Until == "" || day < p.Until)
+}
+
+func awayPath() string {
+	return filepath.Join(dataDir(), "away.jsonl")
+}
+
+// loadAway reads the away periods, oldest first. A missing file yields
+// none.
+func loadAway() ([]awayPeriod, error) {
+	f, err := os.Open(awayPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var periods []awayPeriod
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var p awayPeriod
+		if json.Unmarshal(scanner.Bytes(), &p) == nil {
+			periods = append(periods, p)
+		}
+	}
+	return periods, scanner.Err()
+}
+
+// saveAway replaces the away periods with periods.
+func saveAway(periods []awayPeriod) error {
+	if err := os.MkdirAll(dataDir(), 0755); err != nil {
+		return err
+	}
+	return writeJSONLines(awayPath(), periods)
+}
+
+// awayDays counts the days from from up to to, both local midnights,
+// spent away.
+func awayDays(periods []awayPeriod, from, to time.Time) int {
+	n := 0
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		if awayOn(periods, day) {
+			n++
+		}
+	}
+	return n
+}
+
+// awayOn reports whether the day holding t is spent away.
+func awayOn(periods []awayPeriod, t time.Time) bool {
+	day := t.Format(time.DateOnly)
+	for _, p := range periods {
+		if p.includes(day) {
+			return true
+		}
+	}
+	return false
+}
+
+// currentAway returns the index of the period holding now, or -1.
+func currentAway(periods []awayPeriod, now time.Time) int {
+	day := now.Format(time.DateOnly)
+	for i, p := range periods {
+		if p.includes(day) {
+			return i
+		}
+	}
+	return -1
+}
+
+// endAway ends the period holding now, if any, counting today as a day
+// back, and reports whether there was one.
+func endAway(now time.Time) (bool, error) {
+	periods, err := loadAway()
+	if err != nil {
+		return false, err
+	}
+	i := currentAway(periods, now)
+	if i < 0 {
+		return false, nil
+	}
+	if today := now.Format(time.DateOnly); periods[i].From == today {
+		periods = append(periods[:i], periods[i+1:]...)
+	} else {
+		periods[i].Until = today
+	}
+	return true, saveAway(periods)
+}
+
+// welcomeBack ends away mode when a timer is started, saying so.
+func welcomeBack() {
+	ended, err := endAway(time.Now())
+	if err != nil {
+		log.Printf("Failed to end away mode: %v", err)
+	}
+	if ended && !jsonOutput {
+		fmt.Fprintln(os.Stderr, "Welcome back: away mode is off.")
+	}
+}
+
+// awayCommand implements `pomo away [until DATE]`, which leaves today,
+// and every day up to and including DATE or else until further notice,
+// out of weekly goals and the focus score trend, e.g. for a vacation or
+// sick leave. `pomo away off`, or starting a timer, ends it early.
+func awayCommand(args []string) {
+	now := time.Now()
+	if len(args) == 1 && args[0] == "off" {
+		ended, err := endAway(now)
+		if err != nil {
+			failf(codeFailed, "Failed to end away mode: %v", err)
+		}
+		if !ended {
+			if !jsonOutput {
+				fmt.Fprintln(os.Stderr, "Not away")
+			}
+			fail(codeNotFound, "not away")
+		}
+		succeed(nil)
+		return
+	}
+	period := awayPeriod{From: now.Format(time.DateOnly)}
+	switch {
+	case len(args) == 2 && args[0] == "until":
+		last, err := parseDay(args[1])
+		if err != nil {
+			invalid(fmt.Errorf("bad date %q: use YYYY-MM-DD", args[1]))
+		}
+		if last.Before(startOfDay(now)) {
+			invalid(fmt.Errorf("%s has passed", args[1]))
+		}
+		period.Until = last.AddDate(0, 0, 1).Format(time.DateOnly)
+	case len(args) != 0:
+		usage("pomo away [until YYYY-MM-DD | off]")
+	}
+
+	periods, err := loadAway()
+	if err != nil {
+		failf(codeFailed, "Failed to read away periods: %v", err)
+	}
+	// Going away again from today replaces the period under way.
+	if i := currentAway(periods, now); i >= 0 {
+		if periods[i].From == period.From {
+			periods = append(periods[:i], periods[i+1:]...)
+		} else {
+			periods[i].Until = period.From
+		}
+	}
+	if err := saveAway(append(periods, period)); err != nil {
+		failf(codeFailed, "Failed to save away period: %v", err)
+	}
+	if jsonOutput {
+		succeed(period)
+		return
+	}
+	if period.Until == "" {
+		fmt.Println("Away until further notice: pomo away off, or starting a timer, ends it.")
+		return
+	}
+	back, _ := parseDay(period.Until)
+	fmt.Printf("Away until %s, back %s.\n", back.AddDate(0, 0, -1).Format("Mon Jan 2"), back.Format("Mon Jan 2"))
+}
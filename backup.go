@@ -0,0 +1,262 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// backupDirs maps the top-level directory names used inside a backup
+// archive to the directories they are taken from.
+func backupDirs() map[string]string {
+	return map[string]string{
+		"data":   dataDir(),
+		"config": filepath.Dir(configPath()),
+	}
+}
+
+// backup writes the data and config directories to a gzipped tarball at
+// dest, along with the schema version of the data. It holds the history
+// lock, so that the daemon does not append to or prune the history while
+// it is copied.
+func backup(dest string) error {
+	lock, err := lockHistory()
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	return writeBackup(dest)
+}
+
+// writeBackup writes the backup for backup. The archive is written
+// to a temporary file first and renamed into place once complete. It is
+// called with the history lock held.
+func writeBackup(dest string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".pomo-backup-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	gz := gzip.NewWriter(tmp)
+	tw := tar.NewWriter(gz)
+
+	current, err := dataVersion()
+	if err != nil {
+		return err
+	}
+	version := []byte(strconv.Itoa(current))
+	hdr := &tar.Header{Name: "schema", Mode: 0644, Size: int64(len(version))}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if _, err := tw.Write(version); err != nil {
+		return err
+	}
+
+	for name, dir := range backupDirs() {
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			continue
+		}
+		if err := addDir(tw, dir, name); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dest)
+}
+
+// restore replaces the data and config directories with the contents of
+// the backup at src. Everything is extracted to staging directories before
+// the live directories are swapped out, so a corrupt archive leaves the
+// current data untouched, and a failed swap puts back the directories
+// swapped before it.
+func restore(src string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+
+	dirs := backupDirs()
+	staging := map[string]string{}
+	defer func() {
+		for _, dir := range staging {
+			os.RemoveAll(dir)
+		}
+	}()
+
+	version := -1
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if hdr.Name == "schema" {
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return err
+			}
+			if version, err = strconv.Atoi(strings.TrimSpace(string(data))); err != nil {
+				return fmt.Errorf("invalid schema version %q", data)
+			}
+			continue
+		}
+
+		top, rel, _ := strings.Cut(path.Clean(hdr.Name), "/")
+		target, ok := dirs[top]
+		if !ok || rel == "" || !fs.ValidPath(rel) {
+			continue
+		}
+		if staging[top] == "" {
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			dir, err := os.MkdirTemp(filepath.Dir(target), ".pomo-restore-*")
+			if err != nil {
+				return err
+			}
+			staging[top] = dir
+		}
+
+		dest := filepath.Join(staging[top], filepath.FromSlash(rel))
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(dest, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+				return err
+			}
+			out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, hdr.FileInfo().Mode().Perm())
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(out, tr)
+			out.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if version < 0 {
+		return fmt.Errorf("%s is not a pomo backup", src)
+	}
+	if version > schemaVersion {
+		return fmt.Errorf("backup uses schema version %d, this pomo supports up to %d", version, schemaVersion)
+	}
+
+	var swapped []string
+	undo := func() {
+		for _, top := range swapped {
+			os.RemoveAll(dirs[top])
+			os.Rename(dirs[top]+".old", dirs[top])
+		}
+	}
+	for top, dir := range staging {
+		target := dirs[top]
+		old := target + ".old"
+		os.RemoveAll(old)
+		if err := os.Rename(target, old); err != nil && !os.IsNotExist(err) {
+			undo()
+			return err
+		}
+		if err := os.Rename(dir, target); err != nil {
+			os.Rename(old, target)
+			undo()
+			return err
+		}
+		swapped = append(swapped, top)
+	}
+	for _, top := range swapped {
+		delete(staging, top)
+		os.RemoveAll(dirs[top] + ".old")
+	}
+	return nil
+}
+
+// addDir writes every regular file below dir to tw, named under prefix.
+func addDir(tw *tar.Writer, dir, prefix string) error {
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = path.Join(prefix, filepath.ToSlash(rel))
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+}
+
+// backupCommand implements `pomo backup <file>`.
+func backupCommand(args []string) {
+	if len(args) != 1 {
+		usage("pomo backup <file>")
+	}
+	if err := backup(args[0]); err != nil {
+		failf(codeFailed, "Failed to write backup: %v", err)
+	}
+	succeed(nil)
+}
+
+// restoreCommand implements `pomo restore <file>`. It refuses to run while
+// the daemon answers, since it would write to the old history.
+func restoreCommand(args []string) {
+	if len(args) != 1 {
+		usage("pomo restore <file>")
+	}
+	if _, err := send(request{Cmd: "ping"}); !errors.Is(err, errNoDaemon) {
+		failf(codeExists, "Stop the daemon before restoring")
+	}
+	if err := restore(args[0]); err != nil {
+		failf(codeFailed, "Failed to restore backup: %v", err)
+	}
+	succeed(nil)
+}
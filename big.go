@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// bigFont holds five-line block glyphs for the characters of a countdown.
+var bigFont = map[rune][5]string{
+	'0': {"█████", "█   █", "█   █", "█   █", "█████"},
+	'1': {"  █  ", " ██  ", "  █  ", "  █  ", " ███ "},
+	'2': {"█████", "    █", "█████", "█    ", "█████"},
+	'3': {"█████", "    █", " ████", "    █", "█████"},
+	'4': {"█   █", "█   █", "█████", "    █", "    █"},
+	'5': {"█████", "█    ", "█████", "    █", "█████"},
+	'6': {"█████", "█    ", "█████", "█   █", "█████"},
+	'7': {"█████", "    █", "   █ ", "  █  ", "  █  "},
+	'8': {"█████", "█   █", "█████", "█   █", "█████"},
+	'9': {"█████", "█   █", "█████", "    █", "█████"},
+	':': {"   ", " █ ", "   ", " █ ", "   "},
+	'+': {"     ", "  █  ", "█████", "  █  ", "     "},
+}
+
+// bigText renders s in the big font, one string per line.
+func bigText(s string) []string {
+	lines := make([]string, 5)
+	for _, r := range s {
+		glyph, ok := bigFont[r]
+		if !ok {
+			continue
+		}
+		for i := range lines {
+			lines[i] += glyph[i] + " "
+		}
+	}
+	return lines
+}
+
+// terminalSize returns the size of the terminal on stdout, falling back
+// to 80x24.
+func terminalSize() (cols, rows int) {
+	var ws struct{ rows, cols, x, y uint16 }
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, os.Stdout.Fd(), uintptr(syscall.TIOCGWINSZ), uintptr(unsafe.Pointer(&ws)))
+	if errno != 0 || ws.cols == 0 {
+		return 80, 24
+	}
+	return int(ws.cols), int(ws.rows)
+}
+
+// drawBig clears the screen and draws the countdown and caption centred.
+// inverse draws it in reverse video, which is used to flash.
+func drawBig(clock, caption string, inverse bool) {
+	cols, rows := terminalSize()
+	lines := append(bigText(clock), "", caption)
+
+	var b strings.Builder
+	b.WriteString("\033[H\033[2J")
+	if inverse {
+		b.WriteString("\033[7m")
+	}
+	top := max((rows-len(lines))/2, 0)
+	b.WriteString(strings.Repeat("\n", top))
+	for _, line := range lines {
+		pad := max((cols-len([]rune(line)))/2, 0)
+		b.WriteString(strings.Repeat(" ", pad) + line + "\n")
+	}
+	b.WriteString("\033[0m")
+	os.Stdout.WriteString(b.String())
+}
+
+// bigCommand implements `pomo big [name]`, a full-screen countdown for a
+// dedicated tmux pane or projector. It flashes when a phase ends and exits
+// once the timer is gone. In plain mode it prints a line whenever the
+// spoken description changes instead.
+func bigCommand(args []string) {
+	name := ""
+	if len(args) > 0 {
+		name = args[0]
+	}
+	if accessible(loadConfig()) {
+		plainWatch(name)
+		return
+	}
+
+	// Hide the cursor and restore it on exit.
+	os.Stdout.WriteString("\033[?25l")
+	restore := func() { os.Stdout.WriteString("\033[0m\033[?25h\033[H\033[2J") }
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+
+	lastPhase := ""
+	flash := 0
+	for {
+		resp, err := send(request{Cmd: "list", Name: name})
+		if err != nil || len(resp.Timers) == 0 {
+			restore()
+			return
+		}
+		t := resp.Timers[0]
+
+		if lastPhase != "" && t.Phase != lastPhase {
+			flash = 6
+		}
+		lastPhase = t.Phase
+		if t.Phase == "done" && flash == 0 {
+			flash = 2
+		}
+
+		clock := formatClock(t.Remaining)
+		caption := strings.ToUpper(t.Phase)
+		if t.Paused {
+			caption = "PAUSED"
+		}
+		if t.Task != "" {
+			caption += " · " + t.Task
+		}
+		drawBig(clock, caption, flash%2 == 1)
+		if flash > 0 {
+			flash--
+		}
+
+		select {
+		case <-sigChan:
+			restore()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// plainWatch prints the state of the timer called name in words each time
+// it changes, until the timer is gone.
+func plainWatch(name string) {
+	last := ""
+	for {
+		resp, err := send(request{Cmd: "list", Name: name})
+		if err != nil || len(resp.Timers) == 0 {
+			fmt.Println("timer stopped")
+			return
+		}
+		t := resp.Timers[0]
+		state := strings.ReplaceAll(t.Phase, " ", "_")
+		switch {
+		case t.Paused:
+			state = "paused"
+		case t.Phase == "meeting" && t.Remaining < 0:
+			state = "overtime"
+		}
+		line := describe(state, "", t.Remaining)
+		if t.Phase == "done" {
+			line = "finished"
+		}
+		if t.Task != "" {
+			line += ", task: " + t.Task
+		}
+		if line != last {
+			fmt.Println(line)
+			last = line
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// skippedUnder is how little of a break must be taken for it to count as
+// skipped rather than cut short.
+const skippedUnder = time.Minute
+
+// takenBreak is a finished break as recorded in the breaks file.
+type takenBreak struct {
+	Start     time.Time     `json:"start"` // stored in UTC, as sessions are
+	End       time.Time     `json:"end"`
+	Offset    int           `json:"utc_offset,omitempty"`
+	Planned   time.Duration `json:"planned"`
+	Taken     time.Duration `json:"taken"` // not counting pauses
+	Completed bool          `json:"completed"`
+	Kind      string        `json:"kind"` // "break" or "long break"
+
+	Pauses   int           `json:"pauses,omitempty"`
+	Paused   time.Duration `json:"paused,omitempty"`
+	Extended time.Duration `json:"extended,omitempty"` // added with `pomo add`
+}
+
+// outcome returns "taken", "cut short" or "skipped".
+func (b takenBreak) outcome() string {
+	switch {
+	case b.Completed:
+		return "taken"
+	case b.Taken < skippedUnder:
+		return "skipped"
+	}
+	return "cut short"
+}
+
+func breaksPath() string {
+	return filepath.Join(dataDir(), "breaks.jsonl")
+}
+
+// appendBreak adds b to the end of the breaks file.
+func appendBreak(b takenBreak) error {
+	if simulated("record %s: %s, %s", b.Kind, formatMinutes(b.Taken), b.outcome()) {
+		return nil
+	}
+	if err := os.MkdirAll(dataDir(), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(breaksPath(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, b.Offset = b.Start.Zone()
+	b.Start, b.End = b.Start.UTC(), b.End.UTC()
+	return json.NewEncoder(f).Encode(b)
+}
+
+// loadBreaks reads the breaks that started at or after since. A missing
+// file yields no breaks.
+func loadBreaks(since time.Time) ([]takenBreak, error) {
+	f, err := os.Open(breaksPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var breaks []takenBreak
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var b takenBreak
+		if json.Unmarshal(scanner.Bytes(), &b) == nil && !b.Start.Before(since) {
+			b.Start, b.End = b.Start.Local(), b.End.Local()
+			breaks = append(breaks, b)
+		}
+	}
+	return breaks, scanner.Err()
+}
+
+// compliance summarises how many of a set of breaks were taken.
+type compliance struct {
+	breaks, taken, short, skipped int
+	paused, extended              time.Duration
+}
+
+func breakCompliance(breaks []takenBreak) compliance {
+	c := compliance{breaks: len(breaks)}
+	for _, b := range breaks {
+		c.paused += b.Paused
+		c.extended += b.Extended
+		switch b.outcome() {
+		case "taken":
+			c.taken++
+		case "skipped":
+			c.skipped++
+		default:
+			c.short++
+		}
+	}
+	return c
+}
+
+// rate returns the fraction of breaks taken in full.
+func (c compliance) rate() float64 {
+	if c.breaks == 0 {
+		return 1
+	}
+	return float64(c.taken) / float64(c.breaks)
+}
+
+// printCompliance prints the break compliance of the breaks since.
+func printCompliance(since time.Time, days int) {
+	breaks, err := loadBreaks(since)
+	if err != nil {
+		log.Printf("Failed to read breaks: %v", err)
+	}
+	c := breakCompliance(breaks)
+	if c.breaks == 0 {
+		return
+	}
+	period := "today"
+	if days > 1 {
+		period = fmt.Sprintf("last %d days", days)
+	}
+	fmt.Printf("\nBreaks (%s):\n", period)
+	fmt.Printf("  Compliance            %.0f%% (%d/%d)\n", 100*c.rate(), c.taken, c.breaks)
+	fmt.Printf("  Cut short             %d\n", c.short)
+	fmt.Printf("  Skipped               %d\n", c.skipped)
+	if c.paused > 0 {
+		fmt.Printf("  Paused                %s\n", formatMinutes(c.paused))
+	}
+	if c.extended > 0 {
+		fmt.Printf("  Extended              %s\n", formatMinutes(c.extended))
+	}
+}
+
+// nagWindow and nagMinimum are the period break compliance is judged over
+// for the nag, and the fewest breaks it needs to judge.
+const (
+	nagWindow  = 7 * 24 * time.Hour
+	nagMinimum = 5
+)
+
+// breakNag reminds the user to take their breaks when they have taken
+// fewer than threshold of them lately, from breaks.nag.
+type breakNag struct {
+	threshold float64
+}
+
+// loadBreakNag reads breaks.nag, a percentage such as "60%". It returns
+// nil when unset.
+func loadBreakNag(cfg config) *breakNag {
+	if threshold := parsePercent(cfg, "breaks.nag"); threshold > 0 {
+		return &breakNag{threshold: threshold}
+	}
+	return nil
+}
+
+// recordBreak adds b to the breaks file, nagging if it was not taken in
+// full and too few breaks have been lately. n may be nil.
+func (n *breakNag) recordBreak(b takenBreak) {
+	if err := appendBreak(b); err != nil {
+		log.Printf("Failed to record break: %v", err)
+	}
+	if n == nil || b.Completed {
+		return
+	}
+	breaks, err := loadBreaks(b.End.Add(-nagWindow))
+	if err != nil {
+		return
+	}
+	c := breakCompliance(breaks)
+	if c.breaks >= nagMinimum && c.rate() < n.threshold {
+		showMessage(fmt.Sprintf("Only %.0f%% of your breaks in the last week were taken in full. Rest helps you focus.", 100*c.rate()))
+	}
+}
+
+// breakRules limits what may be done to a running break, from the
+// [breaks] section.
+type breakRules struct {
+	strict    bool          // breaks may not be skipped or stopped before min
+	min       time.Duration // how long a strict break lasts at least
+	pausable  bool
+	maxExtend time.Duration // most a break may be extended by; 0 for no limit
+}
+
+// loadBreakRules reads breaks.strict, breaks.min, breaks.pausable and
+// breaks.max_extend.
+func loadBreakRules(cfg config) breakRules {
+	r := breakRules{
+		strict:   cfg.get("breaks.strict", "false") == "true",
+		min:      2 * time.Minute,
+		pausable: cfg.get("breaks.pausable", "true") == "true",
+	}
+	if d, err := time.ParseDuration(cfg.get("breaks.min", "")); err == nil && d >= 0 {
+		r.min = d
+	}
+	if d, err := time.ParseDuration(cfg.get("breaks.max_extend", "")); err == nil && d >= 0 {
+		r.maxExtend = d
+	}
+	return r
+}
+
+// refuse returns why req may not be applied to t at now, or "" if it may.
+// Only breaks in progress are limited.
+func (r breakRules) refuse(t *timer, req request, now time.Time) string {
+	if t == nil || !t.finished.IsZero() || !isBreak(t.phase().Kind) {
+		return ""
+	}
+	switch req.Cmd {
+	case "pause":
+		if !r.pausable {
+			return "breaks cannot be paused"
+		}
+	case "skip", "stop":
+		if left := r.min - t.taken(now); r.strict && left > 0 {
+			return fmt.Sprintf("breaks last at least %s, %s to go", formatMinutes(r.min), formatClock(left))
+		}
+	case "add":
+		if r.maxExtend > 0 && t.extended+req.Duration > r.maxExtend {
+			return fmt.Sprintf("breaks can be extended by at most %s", formatMinutes(r.maxExtend))
+		}
+	}
+	return ""
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// budget is a weekly limit on the pomodoros completed with a tag.
+type budget struct {
+	tag   string
+	max   bool // a ceiling rather than a floor
+	count int
+}
+
+// loadBudgets reads the [budgets] section of cfg, whose entries look like
+// meetings = "max 10" or writing = "min 8".
+func loadBudgets(cfg config) []budget {
+	var budgets []budget
+	for key, value := range cfg {
+		tag, ok := strings.CutPrefix(key, "budgets.")
+		if !ok {
+			continue
+		}
+		kind, n, _ := strings.Cut(strings.TrimSpace(value), " ")
+		count, err := strconv.Atoi(strings.TrimSpace(n))
+		if err != nil || (kind != "max" && kind != "min") {
+			continue
+		}
+		budgets = append(budgets, budget{tag: tag, max: kind == "max", count: count})
+	}
+	sort.Slice(budgets, func(i, j int) bool { return budgets[i].tag < budgets[j].tag })
+	return budgets
+}
+
+// completedByTag counts the completed pomodoros per tag.
+func completedByTag(sessions []session) map[string]int {
+	done := map[string]int{}
+	for _, s := range sessions {
+		if !s.Completed {
+			continue
+		}
+		for _, tag := range s.Tags {
+			done[tag]++
+		}
+	}
+	return done
+}
+
+// broken reports whether a budget is exceeded (for a max) or not yet met
+// (for a min) with done pomodoros.
+func (b budget) broken(done int) bool {
+	if b.max {
+		return done > b.count
+	}
+	return done < b.count
+}
+
+// thisWeek returns the sessions of the current week.
+func thisWeek() []session {
+	sessions, _ := loadSummary()
+	return sessionsSince(sessions, startOfWeek(time.Now()))
+}
+
+// budgetField renders the {budget} status field, listing the budgets that
+// are currently broken, e.g. "⚠ meetings 11/10", or in plain mode "over
+// budget: meetings 11 of 10".
+func budgetField(cfg config) string {
+	budgets := loadBudgets(cfg)
+	if len(budgets) == 0 {
+		return ""
+	}
+	done := completedByTag(thisWeek())
+	var warnings []string
+	for _, b := range budgets {
+		if !b.broken(done[b.tag]) {
+			continue
+		}
+		if accessible(cfg) {
+			warnings = append(warnings, fmt.Sprintf("%s %d of %d", b.tag, done[b.tag], b.count))
+		} else {
+			warnings = append(warnings, fmt.Sprintf("%s %d/%d", b.tag, done[b.tag], b.count))
+		}
+	}
+	if len(warnings) == 0 {
+		return ""
+	}
+	if accessible(cfg) {
+		return "over budget: " + strings.Join(warnings, ", ")
+	}
+	return "⚠ " + strings.Join(warnings, " ")
+}
+
+// printBudgets prints this week's progress against each budget.
+func printBudgets(cfg config) {
+	budgets := loadBudgets(cfg)
+	if len(budgets) == 0 {
+		return
+	}
+	done := completedByTag(thisWeek())
+	fmt.Println("\nBudgets (this week):")
+	for _, b := range budgets {
+		kind, state := "min", "ok"
+		if b.max {
+			kind = "max"
+		}
+		switch {
+		case b.max && b.broken(done[b.tag]):
+			state = "over budget"
+		case b.broken(done[b.tag]):
+			state = "under budget"
+		}
+		fmt.Printf("  %-30s %d (%s %d)  %s\n", b.tag, done[b.tag], kind, b.count, state)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// caldavEvent is a session on its way to a CalDAV calendar: the iCalendar
+// object to store as <uid>.ics in the calendar collection. Storing it
+// under the session's ID means a retried or repeated delivery replaces the
+// event rather than adding another.
+type caldavEvent struct {
+	UID string `json:"uid"`
+	ICS string `json:"ics"`
+}
+
+// loadCaldav returns the sink that completed sessions are published to,
+// from caldav.url, the calendar collection, with caldav.user and
+// caldav.password, which may name a keyring entry. It returns nil when no
+// URL is configured.
+func loadCaldav(cfg config) *sink {
+	url := cfg.get("caldav.url", "")
+	if url == "" {
+		return nil
+	}
+	headers := http.Header{}
+	password, err := resolveSecret(cfg.get("caldav.password", ""))
+	if err != nil {
+		log.Printf("Failed to read caldav.password: %v", err)
+	}
+	if user := cfg.get("caldav.user", ""); user != "" {
+		headers.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(user+":"+password)))
+	}
+	return &sink{
+		url:     strings.TrimSuffix(url, "/") + "/",
+		headers: headers,
+		timeout: loadHookPolicy(cfg, "caldav").timeout,
+		caldav:  true,
+	}
+}
+
+// caldavPayload renders s as the event stored in the calendar: named
+// after its task, or "Focus", and described by its project, tags, pauses
+// and interruptions.
+func caldavPayload(s session, now time.Time) ([]byte, error) {
+	uid := s.ID
+	if uid == "" {
+		// Recorded before sessions had IDs.
+		uid = fmt.Sprintf("session-%d", s.Start.Unix())
+	}
+	summary := s.Task
+	if summary == "" {
+		summary = "Focus"
+	}
+	var details []string
+	if s.Project != "" {
+		details = append(details, "Project: "+s.Project)
+	}
+	if len(s.Tags) > 0 {
+		details = append(details, "Tags: "+strings.Join(s.Tags, ", "))
+	}
+	details = append(details, fmt.Sprintf("Focus: %s, %d pauses, %d interruptions", formatMinutes(s.focused()), s.Pauses, s.Interruptions))
+	host, _ := os.Hostname()
+	lines := []string{
+		"BEGIN:VCALENDAR", "VERSION:2.0", "PRODID:-//pomo//sessions//EN",
+		"BEGIN:VEVENT",
+		"UID:pomo-" + uid + "@" + host,
+		"DTSTAMP:" + icsTime(now),
+		"DTSTART:" + icsTime(s.Start),
+		"DTEND:" + icsTime(s.End),
+		"SUMMARY:" + icsText(summary),
+		"DESCRIPTION:" + icsText(strings.Join(details, "\n")),
+		"TRANSP:OPAQUE",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}
+	return json.Marshal(caldavEvent{UID: uid, ICS: strings.Join(lines, "\r\n") + "\r\n"})
+}
+
+// icsText escapes s for an iCalendar text value.
+func icsText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(s)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// flowHorizon is how far ahead an open-ended flow session is marked busy,
+// as its end isn't known.
+const flowHorizon = 15 * time.Minute
+
+// busyBlock is a stretch of focus published in the calendar feed.
+type busyBlock struct {
+	uid        string
+	start, end time.Time
+}
+
+// sessionBlocks returns the sessions recorded today up to now.
+func sessionBlocks(now time.Time) []busyBlock {
+	var blocks []busyBlock
+	sessions, err := loadSessions()
+	if err != nil {
+		log.Printf("Failed to read history for the calendar: %v", err)
+	}
+	for _, s := range sessionsSince(sessions, startOfDay(now)) {
+		blocks = append(blocks, busyBlock{uid: fmt.Sprintf("session-%d", s.Start.Unix()), start: s.Start, end: s.End})
+	}
+	return blocks
+}
+
+// timerBlocks returns the current and coming work phases of the running
+// timers. It is called with the lock held.
+func (d *daemon) timerBlocks(now time.Time) []busyBlock {
+	var blocks []busyBlock
+	for _, name := range d.order {
+		t := d.timers[name]
+		if !t.finished.IsZero() {
+			continue
+		}
+		// Later phases follow on from when the current one is due to end.
+		start, end := t.startTime, now.Add(t.left(now))
+		if t.openEnded() {
+			end = now.Add(flowHorizon)
+		}
+		for i := t.current; i < len(t.phases); i++ {
+			if i > t.current {
+				length := t.phases[i].Duration
+				if length == 0 {
+					length = flowHorizon
+				}
+				start, end = end, end.Add(length)
+			}
+			if t.phases[i].Kind == "work" {
+				blocks = append(blocks, busyBlock{uid: fmt.Sprintf("%s-%d-%d", name, t.startTime.Unix(), i), start: start, end: end})
+			}
+		}
+	}
+	return blocks
+}
+
+// icsTime formats t as an iCalendar UTC date-time.
+func icsTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// calendarFeed renders blocks as an iCalendar feed of busy events called
+// summary.
+func calendarFeed(blocks []busyBlock, summary string, now time.Time) string {
+	host, _ := os.Hostname()
+	lines := []string{"BEGIN:VCALENDAR", "VERSION:2.0", "PRODID:-//pomo//busy//EN", "X-WR-CALNAME:" + summary}
+	for _, b := range blocks {
+		lines = append(lines,
+			"BEGIN:VEVENT",
+			"UID:pomo-"+b.uid+"@"+host,
+			"DTSTAMP:"+icsTime(now),
+			"DTSTART:"+icsTime(b.start),
+			"DTEND:"+icsTime(b.end),
+			"SUMMARY:"+summary,
+			"TRANSP:OPAQUE",
+			"END:VEVENT",
+		)
+	}
+	lines = append(lines, "END:VCALENDAR")
+	return strings.Join(lines, "\r\n") + "\r\n"
+}
+
+// serveCalendar serves the busy feed at /busy.ics on addr. Calendar
+// subscriptions can't send headers, so a token, if set, is passed in the
+// URL: /busy.ics?token=.... Without a token it is only served on
+// loopback, as the board is.
+func (d *daemon) serveCalendar(addr, token, summary string) {
+	if token == "" && !loopback(addr) {
+		log.Printf("Refusing to serve the calendar feed on %s without calendar.token", addr)
+		return
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /busy.ics", func(w http.ResponseWriter, r *http.Request) {
+		if token != "" && !tokenMatches(r.URL.Query().Get("token"), token) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		now := time.Now()
+		blocks := sessionBlocks(now)
+		if s := d.latest.Load(); s != nil {
+			blocks = append(blocks, s.blocks...)
+		}
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		w.Write([]byte(calendarFeed(blocks, summary, now)))
+	})
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Printf("Failed to serve calendar feed: %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// defaultQuestion is what a check-in asks unless checkin.question says
+// otherwise.
+const defaultQuestion = "What did you accomplish?"
+
+// checkin asks at the end of each work interval what was accomplished,
+// keeping the one-line answer as the session's note.
+type checkin struct {
+	prompt   string // checkin.prompt: "popup", "notify" or "" for none
+	question string
+	policy   hookPolicy
+}
+
+// loadCheckin reads checkin.prompt and checkin.question. "popup" asks in
+// a tmux popup, "notify" asks in a desktop notification, to be answered
+// with `pomo checkin`.
+func loadCheckin(cfg config) checkin {
+	c := checkin{
+		prompt:   cfg.get("checkin.prompt", "off"),
+		question: cfg.get("checkin.question", defaultQuestion),
+		policy:   loadHookPolicy(cfg, "checkin"),
+	}
+	switch c.prompt {
+	case "popup", "notify":
+	case "off":
+		c.prompt = ""
+	default:
+		log.Printf("Ignoring checkin.prompt %q: use popup, notify or off", c.prompt)
+		c.prompt = ""
+	}
+	return c
+}
+
+// ask asks about the session with id, which has just been recorded.
+func (c checkin) ask(id string) {
+	switch c.prompt {
+	case "popup":
+		self, err := os.Executable()
+		if err != nil {
+			self = os.Args[0]
+		}
+		cmd := tmuxCommand("display-popup", "-E", "-w", "60", "-h", "5", "-T", " check-in ",
+			fmt.Sprintf("%s checkin --session %s", self, id))
+		if err := cmd.Start(); err != nil {
+			log.Printf("Failed to open check-in popup: %v", err)
+			return
+		}
+		go cmd.Wait()
+	case "notify":
+		message := c.question + " Answer with pomo checkin <note>"
+		runHook("checkin", c.policy, commandHook(notifyArgs(message), "POMO_MESSAGE="+message), nil)
+	}
+}
+
+// addNote adds note to the session with id in the history, or the latest
+// session when id is "", after any note it has already.
+func addNote(id, note string) error {
+	// The daemon may be appending a session meanwhile.
+	lock, err := lockHistory()
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	sessions, err := readSessions()
+	if err != nil {
+		return err
+	}
+	if len(sessions) == 0 {
+		return errors.New("no sessions recorded yet")
+	}
+	latest := sessions[len(sessions)-1]
+	if id == "" && latest.ID == "" {
+		return errors.New("the latest session has no ID to find it by")
+	}
+	if id == "" {
+		id = latest.ID
+	}
+	// Sealing cannot fail below once the cipher is set up.
+	if _, err := historyCipher(); err != nil {
+		return err
+	}
+	found := false
+	err = rewriteRecords(historyPath(), func(s *session) {
+		if s.ID != id {
+			return
+		}
+		found = true
+		if existing := unseal(s.Note); existing != "" {
+			note = existing + "; " + note
+		}
+		s.Note, _ = seal(note)
+	})
+	if err == nil && !found {
+		err = fmt.Errorf("no session %s in the history", id)
+	}
+	return err
+}
+
+// checkinCommand implements `pomo checkin [note]`, which adds note to the
+// latest session, asking for it on a terminal when it is not given. The
+// check-in popup runs it with --session for the session that just ended.
+func checkinCommand(args []string) {
+	fs := flag.NewFlagSet("checkin", flag.ExitOnError)
+	id := fs.String("session", "", "ID of the session to add the note to (the latest by default)")
+	args = parseFlags(fs, args)
+	note := strings.TrimSpace(strings.Join(args, " "))
+	if note == "" {
+		textOnly("checkin without a note")
+		if !interactive() {
+			usage("pomo checkin [--session id] <note>")
+		}
+		fmt.Printf("%s ", loadCheckin(loadConfig()).question)
+		line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if note = strings.TrimSpace(line); note == "" {
+			return
+		}
+	}
+	if err := addNote(*id, note); err != nil {
+		failf(codeFailed, "Failed to save note: %v", err)
+	}
+	succeed(nil)
+}
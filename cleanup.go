@@ -0,0 +1,90 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"time"
+)
+
+// savedStatusOption is the tmux user option holding the user's own
+// status-right while pomo shows a timer there with status.cleanup =
+// "restore", so that it can still be put back after a daemon that was
+// killed outright.
+const savedStatusOption = "@pomo-status-right"
+
+// cleanupPolicy is what the status shows once no timer is left, from
+// status.cleanup: "segment", the default, takes pomo's segment out of a
+// status-right with a {pomo} placeholder and otherwise empties it;
+// "restore" puts status-right back exactly as it was; and "summary" shows
+// status.summary for status.summary_for first and then does what
+// "segment" does. Other displays are always put back as they were.
+type cleanupPolicy struct {
+	mode    string
+	summary string        // the format shown in summary mode
+	lasting time.Duration // how long the summary is shown
+
+	until time.Time // when the summary on show goes; zero if none
+}
+
+// loadCleanupPolicy reads status.cleanup, status.summary and
+// status.summary_for.
+func loadCleanupPolicy(cfg config) cleanupPolicy {
+	c := cleanupPolicy{
+		mode:    cfg.get("status.cleanup", "segment"),
+		summary: cfg.get("status.summary", "{today}"),
+		lasting: 10 * time.Second,
+	}
+	switch c.mode {
+	case "segment", "restore", "summary":
+	default:
+		log.Printf("Ignoring status.cleanup %q: use segment, restore or summary", c.mode)
+		c.mode = "segment"
+	}
+	if v := cfg.get("status.summary_for", ""); v != "" {
+		if lasting, err := time.ParseDuration(v); err == nil && lasting > 0 {
+			c.lasting = lasting
+		} else {
+			log.Printf("Ignoring status.summary_for %q", v)
+		}
+	}
+	return c
+}
+
+// summarizing reports whether the summary is on show at now.
+func (c cleanupPolicy) summarizing(now time.Time) bool {
+	return now.Before(c.until)
+}
+
+// clearStatus tidies up the status once no timer is left: it shows the
+// summary, when there is one and the timers were on show, until it is
+// due to go, and then puts every destination back. It is called with the
+// lock held.
+func (d *daemon) clearStatus(now time.Time) {
+	if d.writer == nil || d.cleanup.summarizing(now) {
+		return
+	}
+	if d.cleanup.mode == "summary" && d.cleanup.until.IsZero() && d.writer.showing(d.dest) {
+		if summary := renderStatus(d.cleanup.summary, historyFields(loadConfig())); summary != "" {
+			d.cleanup.until = now.Add(d.cleanup.lasting)
+			d.show(map[destination]string{d.dest: summary})
+			return
+		}
+	}
+	d.cleanup.until = time.Time{}
+	d.show(nil)
+}
+
+// savedStatus returns the user's own status-right, saving it in
+// savedStatusOption first. An earlier daemon's copy is kept, as the
+// current value is likely what that daemon showed.
+func savedStatus() string {
+	saved, _ := tmuxCommand("show-option", "-gqv", savedStatusOption).Output()
+	if value := strings.TrimRight(string(saved), "\n"); value != "" {
+		return value
+	}
+	current := destination{Display: "status-right"}.current()
+	if current != "" {
+		tmuxCommand(tmuxBatch([]string{"set-option", "-g", savedStatusOption, current})...).Run()
+	}
+	return current
+}
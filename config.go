@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// config holds the values read from the config file, keyed by
+// "section.key" (or just "key" for entries before the first section).
+type config map[string]string
+
+// configPath returns the location of the config file, honouring
+// XDG_CONFIG_HOME.
+func configPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, _ := os.UserHomeDir()
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "pomo", "config.toml")
+}
+
+// loadConfig reads the config file. A missing file yields an empty config.
+//
+// Only a small TOML subset is understood: [section] headers, comments and
+// single-line key = value pairs.
+func loadConfig() config {
+	cfg := config{}
+	f, err := os.Open(configPath())
+	if err != nil {
+		return cfg
+	}
+	defer f.Close()
+
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.Trim(strings.TrimSpace(key), `"`)
+		if section != "" {
+			key = section + "." + key
+		}
+		cfg[key] = unquote(strings.TrimSpace(value))
+	}
+	return cfg
+}
+
+// unquote strips surrounding double quotes and a trailing comment from a
+// config value.
+func unquote(value string) string {
+	if strings.HasPrefix(value, `"`) {
+		if end := strings.Index(value[1:], `"`); end >= 0 {
+			return value[1 : end+1]
+		}
+	}
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = value[:i]
+	}
+	return strings.TrimSpace(value)
+}
+
+// get returns the value for key, or def when it is not set.
+func (c config) get(key, def string) string {
+	if v, ok := c[key]; ok {
+		return v
+	}
+	return def
+}
+
+// list returns the value for key parsed as a single-line array of strings,
+// e.g. ["a", "b"]. A plain value yields a one element list.
+func (c config) list(key string) []string {
+	v, ok := c[key]
+	if !ok {
+		return nil
+	}
+	inner, isArray := strings.CutPrefix(v, "[")
+	if !isArray {
+		return []string{v}
+	}
+	inner = strings.TrimSuffix(strings.TrimSpace(inner), "]")
+	var items []string
+	for _, item := range strings.Split(inner, ",") {
+		item = strings.Trim(strings.TrimSpace(item), `"`)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// setConfig sets key (in "section.key" form) to value in the config file,
+// replacing an existing entry or adding one, along with its section, at
+// the end of the file. Comments and layout are preserved.
+func setConfig(key, value string) error {
+	section, name := "", key
+	if i := strings.LastIndex(key, "."); i >= 0 {
+		section, name = key[:i], key[i+1:]
+	}
+	entry := fmt.Sprintf("%s = %q", name, value)
+
+	data, err := os.ReadFile(configPath())
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(data) == 0 {
+		lines = nil
+	}
+
+	current, sectionEnd := "", -1
+	if section == "" {
+		sectionEnd = 0
+	}
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			current = strings.TrimSpace(trimmed[1 : len(trimmed)-1])
+			if current == section {
+				sectionEnd = i + 1
+			}
+			continue
+		}
+		if current != section {
+			continue
+		}
+		if k, _, ok := strings.Cut(trimmed, "="); ok && strings.Trim(strings.TrimSpace(k), `"`) == name {
+			lines[i] = entry
+			return writeConfig(lines)
+		}
+		if trimmed != "" {
+			sectionEnd = i + 1
+		}
+	}
+
+	if sectionEnd < 0 {
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, "["+section+"]", entry)
+	} else {
+		lines = slices.Insert(lines, sectionEnd, entry)
+	}
+	return writeConfig(lines)
+}
+
+// writeConfig replaces the config file with lines.
+func writeConfig(lines []string) error {
+	if err := os.MkdirAll(filepath.Dir(configPath()), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(configPath(), []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}
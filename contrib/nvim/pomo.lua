@@ -0,0 +1,66 @@
+-- pomo for Neovim: shows the timer in the statusline and adds :Pomo.
+--
+-- Put this file on your runtimepath as lua/pomo.lua, then:
+--
+--   require("pomo").setup()
+--   vim.o.statusline = "%f %m%=%{v:lua.require'pomo'.status()} "
+--
+-- or, with lualine: sections = { lualine_x = { require("pomo").status } }.
+--
+-- The status is read straight from the pomo daemon's socket once a second,
+-- so redrawing the statusline never runs a process.
+
+local M = {}
+
+local socket = "/tmp/tmuxstatus.sock"
+local current = ""
+
+-- ask sends one request to the daemon and calls back with its decoded
+-- response, or nil when the daemon is not running.
+local function ask(request, callback)
+  local pipe = vim.uv.new_pipe(false)
+  local chunks = {}
+  pipe:connect(socket, function(err)
+    if err then
+      pipe:close()
+      return callback(nil)
+    end
+    pipe:write(vim.json.encode(request) .. "\n")
+    pipe:read_start(function(read_err, data)
+      if data then
+        table.insert(chunks, data)
+        return
+      end
+      pipe:close()
+      local ok, response = pcall(vim.json.decode, table.concat(chunks))
+      callback(not read_err and ok and response or nil)
+    end)
+  end)
+end
+
+local function refresh()
+  ask({ cmd = "statusline" }, function(response)
+    local status = response and response.status or ""
+    if status ~= current then
+      current = status
+      vim.schedule(function()
+        vim.cmd.redrawstatus()
+      end)
+    end
+  end)
+end
+
+-- status returns the timer's status for the statusline.
+function M.status()
+  return current
+end
+
+function M.setup()
+  vim.uv.new_timer():start(0, 1000, refresh)
+  -- Starting a timer needs the pomo command, which starts the daemon.
+  vim.api.nvim_create_user_command("Pomo", function(opts)
+    vim.system(vim.list_extend({ "pomo" }, opts.fargs), { text = true }, refresh)
+  end, { nargs = "+", desc = "Run a pomo command, e.g. :Pomo pause" })
+end
+
+return M
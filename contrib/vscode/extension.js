@@ -0,0 +1,66 @@
+// pomo for VS Code: shows the timer in the status bar, where clicking it
+// pauses or resumes, and adds "pomo: ..." commands.
+//
+// Copy this directory into ~/.vscode/extensions/pomo and restart VS Code.
+// The status is read straight from the pomo daemon's socket once a second.
+
+const net = require("net");
+const { execFile } = require("child_process");
+const vscode = require("vscode");
+
+const socket = "/tmp/tmuxstatus.sock";
+
+// ask sends one request to the daemon and resolves to its response, or
+// null when the daemon is not running.
+function ask(request) {
+  return new Promise((resolve) => {
+    const conn = net.createConnection(socket);
+    let data = "";
+    conn.on("connect", () => conn.write(JSON.stringify(request) + "\n"));
+    conn.on("data", (chunk) => (data += chunk));
+    conn.on("end", () => {
+      try {
+        resolve(JSON.parse(data));
+      } catch {
+        resolve(null);
+      }
+    });
+    conn.on("error", () => resolve(null));
+  });
+}
+
+function activate(context) {
+  const item = vscode.window.createStatusBarItem(vscode.StatusBarAlignment.Left);
+  item.command = "pomo.toggle";
+  context.subscriptions.push(item);
+
+  let paused = false;
+  const refresh = async () => {
+    const response = await ask({ cmd: "statusline" });
+    if (!response || !response.status) {
+      item.hide();
+      return;
+    }
+    paused = (response.timers || []).every((t) => t.paused);
+    item.text = response.status;
+    item.tooltip = paused ? "Resume pomo" : "Pause pomo";
+    item.show();
+  };
+  const timer = setInterval(refresh, 1000);
+  context.subscriptions.push({ dispose: () => clearInterval(timer) });
+
+  // Starting a timer needs the pomo command, which starts the daemon.
+  const pomo = (...args) => execFile("pomo", args, refresh);
+  const commands = {
+    "pomo.start": () => pomo("start"),
+    "pomo.stop": () => pomo("stop"),
+    "pomo.skip": () => pomo("skip"),
+    "pomo.toggle": () => ask({ cmd: paused ? "resume" : "pause" }).then(refresh),
+  };
+  for (const [name, fn] of Object.entries(commands)) {
+    context.subscriptions.push(vscode.commands.registerCommand(name, fn));
+  }
+  refresh();
+}
+
+module.exports = { activate };
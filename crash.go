@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// crashReportAge is how long `pomo doctor` mentions a crash report for.
+const crashReportAge = 7 * 24 * time.Hour
+
+// release gives back what the daemon has taken over: the status, its
+// redraw interval and clicks on it, the terminal's tab bar, the ambient
+// sound, the lights and the tmux client moved for a break.
+func (d *daemon) release() {
+	if d.writer != nil {
+		d.writer.close()
+	}
+	if d.interval {
+		restoreInterval()
+	}
+	if d.mouse {
+		restoreMouse()
+	}
+	d.ambient.stop()
+	d.lights.reset()
+	d.tab.clear()
+	d.focus.restore()
+}
+
+// spawn runs fn in a goroutine whose panics bring the daemon down cleanly.
+func (d *daemon) spawn(fn func()) {
+	go func() {
+		defer d.guard()
+		fn()
+	}()
+}
+
+// guard, deferred at the top of a daemon goroutine, handles a panic in it:
+// it writes a crash report, releases the status and the rest as shutdown
+// would, and exits. The state file is kept, so the timers can be
+// recovered by the next daemon.
+func (d *daemon) guard() {
+	r := recover()
+	if r == nil {
+		return
+	}
+	stack := debug.Stack()
+	// The panic may have left the lock held; go on without it.
+	d.mu.TryLock()
+	path, err := writeCrashReport(d, r, stack)
+	if err != nil {
+		log.Printf("Failed to write crash report: %v", err)
+	}
+	log.Printf("Daemon crashed: %v (report in %s)", r, path)
+	d.release()
+	os.Remove(socketPath)
+	cleanup()
+	os.Exit(2)
+}
+
+// writeCrashReport saves the panic r with its stack and the timers that
+// were running to a crash-<time>.log file in the data directory.
+func writeCrashReport(d *daemon, r any, stack []byte) (string, error) {
+	now := time.Now()
+	var b strings.Builder
+	fmt.Fprintf(&b, "pomo daemon crashed at %s\n", now.Format(time.RFC3339))
+	fmt.Fprintf(&b, "pid %d, %s %s/%s, up %s\n\n", os.Getpid(), runtime.Version(), runtime.GOOS, runtime.GOARCH,
+		now.Sub(d.started).Truncate(time.Second))
+	fmt.Fprintf(&b, "panic: %v\n\n", r)
+	b.WriteString("timers:\n")
+	for _, name := range d.order {
+		if t := d.timers[name]; t != nil {
+			fmt.Fprintf(&b, "  %s: phase %d of %d (%s), paused %t\n", name, t.current+1, len(t.phases), t.phase().Kind, t.paused)
+		}
+	}
+	b.WriteString("\n")
+	b.Write(stack)
+
+	path := filepath.Join(dataDir(), "crash-"+now.Format("20060102-150405")+".log")
+	if err := os.MkdirAll(dataDir(), 0755); err != nil {
+		return path, err
+	}
+	return path, os.WriteFile(path, []byte(b.String()), 0644)
+}
+
+// recentCrashReports returns the crash reports written in the last
+// crashReportAge, oldest first.
+func recentCrashReports() []string {
+	paths, _ := filepath.Glob(filepath.Join(dataDir(), "crash-*.log"))
+	var recent []string
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) < crashReportAge {
+			recent = append(recent, path)
+		}
+	}
+	return recent
+}
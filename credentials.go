@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// keyringService is the service name credentials are stored under.
+const keyringService = "pomo"
+
+// keyringPrefix marks a config value as the name of a keyring entry rather
+// than the credential itself, e.g. token = "keyring:otel".
+const keyringPrefix = "keyring:"
+
+// keyring stores credentials with the OS: the Keychain on macOS and the
+// Secret Service (through secret-tool) elsewhere.
+type keyring struct{}
+
+// command returns the arguments that get, set or delete the entry called
+// name on this OS, and what to give them on stdin. The value to set is
+// only ever passed on stdin, where other users cannot see it as they can
+// arguments: secret-tool reads it from there, and security is given the
+// whole command in interactive mode.
+func (keyring) command(op, name, value string) ([]string, string) {
+	if runtime.GOOS == "darwin" {
+		switch op {
+		case "get":
+			return []string{"security", "find-generic-password", "-s", keyringService, "-a", name, "-w"}, ""
+		case "set":
+			line := fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n", securityQuote(keyringService), securityQuote(name), securityQuote(value))
+			return []string{"security", "-i"}, line
+		}
+		return []string{"security", "delete-generic-password", "-s", keyringService, "-a", name}, ""
+	}
+	switch op {
+	case "get":
+		return []string{"secret-tool", "lookup", "service", keyringService, "account", name}, ""
+	case "set":
+		return []string{"secret-tool", "store", "--label", "pomo: " + name, "service", keyringService, "account", name}, value
+	}
+	return []string{"secret-tool", "clear", "service", keyringService, "account", name}, ""
+}
+
+// securityQuote quotes s as an argument in a command given to security's
+// interactive mode.
+func securityQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// run runs op on the entry called name.
+func (k keyring) run(op, name, value string) (string, error) {
+	args, stdin := k.command(op, name, value)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = strings.NewReader(stdin)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err == nil && slices.Equal(args, []string{"security", "-i"}) && stderr.Len() > 0 {
+		// Interactive mode exits successfully whatever the command did.
+		err = errors.New("failed")
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("%s: %v", args[0], err)
+	}
+	return strings.TrimRight(string(out), "\n"), nil
+}
+
+func (k keyring) get(name string) (string, error) {
+	value, err := k.run("get", name, "")
+	if err == nil && value == "" {
+		err = fmt.Errorf("no keyring entry %q", name)
+	}
+	return value, err
+}
+
+func (k keyring) set(name, value string) error {
+	_, err := k.run("set", name, value)
+	return err
+}
+
+func (k keyring) delete(name string) error {
+	_, err := k.run("delete", name, "")
+	return err
+}
+
+// resolveSecret returns the credential value stands for: the keyring entry
+// it names with keyringPrefix, or else value itself.
+func resolveSecret(value string) (string, error) {
+	name, ok := strings.CutPrefix(value, keyringPrefix)
+	if !ok {
+		return value, nil
+	}
+	return keyring{}.get(name)
+}
+
+// secretCommand implements `pomo secret set <name>`, which stores a line
+// read from stdin in the keyring, and `pomo secret delete <name>`.
+func secretCommand(args []string) {
+	if len(args) != 2 {
+		usage("pomo secret <set|delete> <name>")
+	}
+	name := args[1]
+	switch args[0] {
+	case "set":
+		if !jsonOutput {
+			fmt.Fprintf(os.Stderr, "Value for %s: ", name)
+		}
+		line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		value := strings.TrimRight(line, "\r\n")
+		if value == "" {
+			usage("pomo secret set <name> < value")
+		}
+		if err := (keyring{}).set(name, value); err != nil {
+			failf(codeFailed, "Failed to store %s: %v", name, err)
+		}
+		if !jsonOutput {
+			fmt.Fprintf(os.Stderr, "Stored; use %q in the config.\n", keyringPrefix+name)
+		}
+	case "delete":
+		if err := (keyring{}).delete(name); err != nil {
+			failf(codeFailed, "Failed to delete %s: %v", name, err)
+		}
+	default:
+		usage("pomo secret <set|delete> <name>")
+	}
+	succeed(nil)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// cueMessageLength is how long a cue shown as a tmux message stays up.
+const cueMessageLength = 3 * time.Second
+
+// cues are the countdown announcements of the [cues] section: a subtle
+// beep or message at round marks of the time left in a work interval, and
+// at its halfway point, so you know roughly where you are without looking.
+type cues struct {
+	every   time.Duration // cue when the time left is a multiple of this; zero for none
+	halfway bool
+	style   string // "beep" or "message"
+	sound   string // played for a beep instead of the bell
+	plain   bool   // words only, for screen readers
+}
+
+// loadCues reads the [cues] section of cfg.
+func loadCues(cfg config) cues {
+	c := cues{
+		halfway: cfg.get("cues.halfway", "false") == "true",
+		style:   cfg.get("cues.style", "beep"),
+		sound:   expandHome(cfg.get("cues.sound", "")),
+		plain:   accessible(cfg),
+	}
+	if every, err := time.ParseDuration(cfg.get("cues.every", "")); err == nil && every >= time.Minute {
+		c.every = every.Truncate(time.Second)
+	}
+	return c
+}
+
+// due returns the cue for t at now, or "" when none falls due. A cue is
+// due when the time left has passed its mark since the last check, so
+// none is missed or repeated however the ticks fall. Only work intervals
+// with a set length are announced, and never at their start or end.
+func (c cues) due(t *timer, now time.Time) string {
+	rem, prev := t.left(now), t.cued
+	t.cued = rem
+	if !t.working() || t.openEnded() {
+		return ""
+	}
+	total := t.phase().Duration
+	if rem <= 0 || rem >= total || rem >= prev {
+		return ""
+	}
+	passed := func(mark time.Duration) bool {
+		return mark > 0 && mark < total && rem <= mark && mark < prev
+	}
+	if half := (total / 2).Truncate(time.Second); c.halfway && passed(half) {
+		return fmt.Sprintf("halfway, %s left", formatMinutes(half))
+	}
+	if c.every > 0 {
+		if mark := (prev - 1) / c.every * c.every; passed(mark) {
+			return formatMinutes(mark) + " left"
+		}
+	}
+	return ""
+}
+
+// announce gives the cue msg for the timer named name.
+func (c cues) announce(name, msg string, s sounds) {
+	if c.style != "message" {
+		s.play(c.sound)
+		return
+	}
+	if !c.plain {
+		msg = "⏳ " + msg
+	}
+	show := func() {
+		if err := tmuxCommand("display-message", "-d", fmt.Sprint(cueMessageLength.Milliseconds()), tmuxEscape(name+": "+msg)).Run(); err != nil {
+			log.Printf("Failed to show cue: %v", err)
+		}
+	}
+	if dryRun != nil {
+		show()
+		return
+	}
+	go show()
+}
@@ -0,0 +1,870 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultTimer is the name given to timers started without --name.
+const defaultTimer = "default"
+
+// daemon owns every running timer and the tmux status they are shown in.
+type daemon struct {
+	mu     sync.Mutex
+	timers map[string]*timer
+	order  []string // timer names in start order
+
+	format   string
+	style    phaseStyle
+	interval bool // status-interval is managed, see manageInterval
+	mouse    bool // status clicks are bound, see manageMouse
+	compact  *compactView
+	link     *slowLink         // notices a slow tmux
+	fields   map[string]string // cached status fields computed from history
+	dest     destination       // where timers are shown unless they say otherwise
+	writer   *statusWriter     // nil until something is shown
+	cleanup  cleanupPolicy     // what is shown once no timer is left
+	hidden   []string          // sessions the global status is kept out of, see detachCommand
+	tab      *tabBar           // the terminal tab bar of the last timer started from one, if any
+	sounds   sounds
+	cues     cues
+	alerts   escalation
+	ambient  ambient
+	guide    string   // break popup content: "breathing", "stretch" or ""
+	workEnd  []string // run when a work interval runs out
+	nudge    string   // git.nudge: "message" or "notify" to remind of uncommitted work then, or ""
+	focus    breakFocus
+	eyes     eyeRest
+	micro    microBreaks
+	bus      *eventBus
+	nag      *breakNag    // nil unless breaks.nag is set
+	team     *broadcaster // nil unless presence is broadcast
+	lights   *lights      // nil unless smart lights follow the timer
+	power    *powerSaver
+	workday  workday
+
+	// maxPause limits how long a timer may stay paused before onMaxPause
+	// ("resume", "abandon" or "alert") is applied; zero for no limit.
+	maxPause   time.Duration
+	onMaxPause string
+
+	stopRule      stopRule   // how timers stopped early are recorded
+	breakRules    breakRules // what may be done to a break
+	workEndPolicy hookPolicy // how on_work_end runs
+	checkin       checkin    // asks what each work interval accomplished
+
+	// display selects what the status shows: "" for every timer, "rotate"
+	// to cycle through them, or the name of a single timer.
+	display  string
+	rotate   time.Duration
+	rotation int // index of the timer shown while rotating
+	rotated  time.Time
+
+	emptied chan struct{} // signalled when a request leaves no timers
+	changed chan struct{} // signalled when a request may change when to tick next
+
+	// latest is what list and statusline answer with, published after
+	// every change so that polling them never waits for the lock.
+	latest atomic.Pointer[snapshot]
+
+	started   time.Time
+	persisted time.Time // when the state file was last written
+
+	// lastTick and due are when the timer loop last ran and is next due
+	// to, in Unix nanoseconds, for health checks that must answer without
+	// the lock when the loop is stuck holding it.
+	lastTick atomic.Int64
+	due      atomic.Int64
+
+	// persistent daemons keep running without timers, for supervision by
+	// systemd or launchd.
+	persistent bool
+}
+
+// stallAfter is how long the timer loop may go without running before the
+// daemon reports itself unhealthy.
+const stallAfter = 5 * time.Second
+
+// runDaemon serves requests on the control socket and drives the timers
+// until the last one has finished or the daemon is told to stop. A
+// persistent daemon keeps running until it is signalled.
+func runDaemon(persistent bool) {
+	ln, err := claimSocket(socketPath, startLock)
+	if err != nil {
+		log.Fatalf("Failed to listen on %s: %v", socketPath, err)
+	}
+	if ln == nil {
+		if jsonOutput {
+			fail(codeExists, "a pomo daemon is already running")
+		}
+		os.Exit(0)
+	}
+	succeed(map[string]any{"pid": os.Getpid(), "socket": socketPath})
+
+	// Log incidents to a file, as the daemon has no terminal.
+	if err := os.MkdirAll(dataDir(), 0755); err == nil {
+		if f, err := os.OpenFile(filepath.Join(dataDir(), "daemon.log"), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644); err == nil {
+			log.SetOutput(f)
+		}
+	}
+
+	// Bring data written by an older pomo up to date before reading it.
+	if err := migrate(); err != nil {
+		log.Fatalf("Failed to migrate data: %v", err)
+	}
+
+	// Write our PID to the PID file.
+	pid := os.Getpid()
+	if err := os.WriteFile(pidFile, []byte(strconv.Itoa(pid)), 0644); err != nil {
+		log.Fatalf("Failed to write PID file: %v", err)
+	}
+
+	cfg := loadConfig()
+	d := newDaemon(cfg, persistent)
+	defer d.guard()
+	d.interval = manageInterval(cfg)
+	d.mouse = manageMouse(cfg)
+	d.recoverState(cfg.get("recovery.policy", "ask"), d.started)
+	d.spawn(func() { d.serve(ln) })
+	d.spawn(d.compact.watch)
+	d.spawn(d.bus.run)
+	if keep := cfg.get("history.keep", ""); keep != "" {
+		go keepHistory(keep)
+	}
+	if addr := cfg.get("health.listen", ""); addr != "" {
+		go d.serveHealth(addr)
+	}
+	if addr := cfg.get("calendar.listen", ""); addr != "" {
+		token, err := resolveSecret(cfg.get("calendar.token", ""))
+		if err != nil {
+			log.Printf("Failed to read calendar.token: %v", err)
+		} else {
+			go d.serveCalendar(addr, token, cfg.get("calendar.summary", "Focus time"))
+		}
+	}
+
+	// Set up a signal channel to handle termination, pause, and resume.
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, append(signals, dumpSignals...)...)
+
+	ticker := time.NewTimer(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case s := <-sigChan:
+			now := time.Now()
+			d.mu.Lock()
+			d.onSignal(s, ln, now)
+			d.refresh(now)
+			d.publish(now)
+			d.mu.Unlock()
+		case <-d.emptied:
+			d.mu.Lock()
+			idle := len(d.timers) == 0
+			d.mu.Unlock()
+			if idle && !d.persistent {
+				d.shutdown(ln)
+			}
+		case <-d.changed:
+			// A new or resumed timer may need to tick before the one
+			// scheduled a minute ahead in low power mode.
+			now := time.Now()
+			d.mu.Lock()
+			if d.power.low(now) {
+				d.reschedule(ticker, now)
+			}
+			d.mu.Unlock()
+		case <-ticker.C:
+			now := time.Now()
+			d.mu.Lock()
+			busy := len(d.timers) > 0
+			d.tick(now)
+			d.publish(now)
+			idle := len(d.timers) == 0 && !d.alerts.active()
+			d.reschedule(ticker, now)
+			d.mu.Unlock()
+			// The first timer arrives over the socket shortly after we
+			// start; give up if it never does.
+			if idle && !d.persistent && (busy || now.Sub(d.started) > 5*time.Second) {
+				d.shutdown(ln)
+			}
+		}
+	}
+}
+
+// newDaemon sets up a daemon with no timers from cfg.
+func newDaemon(cfg config, persistent bool) *daemon {
+	rotate, err := time.ParseDuration(cfg.get("status.rotate_interval", "5s"))
+	if err != nil || rotate <= 0 {
+		rotate = 5 * time.Second
+	}
+	d := &daemon{
+		timers:  map[string]*timer{},
+		fields:  historyFields(cfg),
+		dest:    loadDestination(cfg),
+		compact: loadCompactView(cfg),
+		link:    loadSlowLink(cfg),
+		cleanup: loadCleanupPolicy(cfg),
+		sounds:  loadSounds(cfg),
+		cues:    loadCues(cfg),
+		ambient: ambient{command: cfg.get("ambient.command", "")},
+		guide:   cfg.get("breaks.popup", ""),
+		workEnd: workEndAction(cfg),
+		nudge:   cfg.get("git.nudge", ""),
+		focus:   breakFocus{target: cfg.get("breaks.window", "")},
+		eyes:    loadEyeRest(cfg),
+		micro:   loadMicroBreaks(cfg),
+		workday: loadWorkday(cfg),
+		bus:     loadEventBus(cfg),
+		nag:     loadBreakNag(cfg),
+		team:    loadBroadcaster(cfg),
+		lights:  loadLights(cfg),
+		power:   loadPowerSaver(cfg),
+		rotate:  rotate,
+
+		emptied: make(chan struct{}, 1),
+		changed: make(chan struct{}, 1),
+		started: time.Now(),
+
+		persistent: persistent,
+	}
+	d.lastTick.Store(d.started.UnixNano())
+	d.due.Store(d.started.Add(time.Second).UnixNano())
+	if limit, err := time.ParseDuration(cfg.get("pause.max", "")); err == nil && limit > 0 {
+		d.maxPause, d.onMaxPause = limit, cfg.get("pause.on_max", "alert")
+	}
+	d.stopRule = loadStopRule(cfg)
+	d.breakRules = loadBreakRules(cfg)
+	d.workEndPolicy = loadHookPolicy(cfg, "on_work_end")
+	d.checkin = loadCheckin(cfg)
+	d.alerts = loadEscalation(cfg, func() { d.sounds.play(d.sounds.alarm) })
+	d.useTheme(cfg, cfg.get("theme", defaultTheme))
+	return d
+}
+
+// shutdown exits once the daemon is idle, unless a timer was started
+// since it found itself so or the summary is still on show.
+func (d *daemon) shutdown(ln net.Listener) {
+	d.mu.Lock()
+	if len(d.timers) > 0 || d.cleanup.summarizing(time.Now()) {
+		d.mu.Unlock()
+		return
+	}
+	d.exit(ln, false)
+}
+
+// exit closes the control socket, resets the status and exits, keeping
+// the state file for recovery if keepState is set. It is called with the
+// lock held, which it keeps, so that requests already accepted are
+// dropped unanswered and their clients start another daemon.
+func (d *daemon) exit(ln net.Listener, keepState bool) {
+	if _, err := releaseSocket(ln, socketPath, startLock); err != nil {
+		log.Printf("Failed to lock %s: %v", startLock, err)
+	}
+	d.release()
+	recording.Wait()
+	d.bus.flush(2 * time.Second)
+	d.team.leave()
+	if !keepState {
+		os.Remove(statePath())
+	}
+	cleanup()
+	os.Exit(0)
+}
+
+// reschedule sets ticker for the next run of the timer loop, see nextTick.
+func (d *daemon) reschedule(ticker *time.Timer, now time.Time) {
+	wait := d.nextTick(now)
+	d.due.Store(now.Add(wait).UnixNano())
+	ticker.Reset(wait)
+}
+
+// tick advances every timer, drops finished ones and redraws the status.
+func (d *daemon) tick(now time.Time) {
+	last := time.Unix(0, d.lastTick.Swap(now.UnixNano()))
+	ticking, working := false, false
+	changed := now.Sub(d.persisted) >= persistEvery
+	for _, name := range append([]string(nil), d.order...) {
+		t := d.timers[name]
+		working = working || t.working()
+		if d.overPaused(t, now) {
+			continue
+		}
+		// Timers running as the workday ends wrap up, until the next
+		// day; one started after it has ended runs on.
+		if !d.workday.over(now) {
+			t.wrapUp = false
+		} else if d.workday.endedBetween(last, now) && d.workday.endedBetween(t.startTime, now) {
+			t.wrapUp = true
+		}
+		if t.tick(now) {
+			if t.wrapUp && !t.finished.IsZero() {
+				d.workday.finish(now)
+			}
+			d.alerts.start(now, t.ended())
+			if t.previous().Kind == "work" && t.saved != "" {
+				d.checkin.ask(t.saved)
+			}
+			if t.previous().Kind == "work" && d.workEnd != nil {
+				d.runWorkEnd(t, now)
+			}
+			if t.previous().Kind == "work" && d.nudge != "" && t.repo != "" {
+				nudgeCommit(d.nudge, t.name, t.repo)
+			}
+			d.fields = historyFields(loadConfig())
+			changed = true
+		} else if t.overran(now) {
+			d.sounds.play(d.sounds.alarm)
+			showMessage(t.name + ": over time")
+		} else if t.rotate(now) {
+			d.sounds.play(d.sounds.alarm)
+			showMessage(fmt.Sprintf("Rotate: %s drives, %s navigates", t.pair.Driver, t.pair.Navigator))
+		} else if cue := d.cues.due(t, now); cue != "" {
+			d.cues.announce(t.name, cue, d.sounds)
+		} else if t.working() && d.sounds.shouldTick(int(t.left(now).Seconds())) {
+			ticking = true
+		}
+		if t.expired(now) {
+			d.remove(name)
+		}
+	}
+	d.alerts.run(now)
+	// One tick is enough however many timers are running.
+	d.sounds.quiet = d.power.low(now)
+	if ticking && !d.sounds.quiet {
+		d.sounds.play(d.sounds.tick)
+	}
+	d.eyes.track(now, working)
+	d.micro.track(now, d.workLeft(now))
+	d.team.update(d.presence(now), now)
+	d.lights.update(d.lightState(now))
+	d.refresh(now)
+	if changed {
+		d.persist(now)
+	}
+}
+
+// overPaused applies pause.on_max to t if it has been paused for longer
+// than pause.max. It reports whether t was abandoned and removed.
+func (d *daemon) overPaused(t *timer, now time.Time) bool {
+	if d.maxPause == 0 || !t.paused || t.held || t.warned || now.Sub(t.pausedAt) < d.maxPause {
+		return false
+	}
+	switch d.onMaxPause {
+	case "resume":
+		t.resume(now)
+	case "abandon":
+		t.stop(now, "abandon")
+		d.remove(t.name)
+		d.fields = historyFields(loadConfig())
+		return true
+	default:
+		t.warned = true
+		d.alerts.start(now, fmt.Sprintf("%s: paused for %s", t.name, formatMinutes(d.maxPause)))
+	}
+	return false
+}
+
+// remove forgets the named timer, tidying up after its profile.
+func (d *daemon) remove(name string) {
+	if t := d.timers[name]; t.profile != nil {
+		runProfile(t, "on_end")
+	}
+	delete(d.timers, name)
+	for i, n := range d.order {
+		if n == name {
+			d.order = append(d.order[:i], d.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// stopAll stops every timer.
+func (d *daemon) stopAll(now time.Time) {
+	for _, name := range append([]string(nil), d.order...) {
+		d.stopTimer(name, now, "")
+	}
+}
+
+// stopTimer stops and removes the named timer, recording it as outcome or,
+// when that is "", as the stop rule decides.
+func (d *daemon) stopTimer(name string, now time.Time, outcome string) {
+	t := d.timers[name]
+	if outcome == "" {
+		outcome = d.stopRule.outcome(t, now)
+	}
+	t.stop(now, outcome)
+	d.remove(name)
+}
+
+// destination returns where t is shown.
+func (d *daemon) destination(t *timer) destination {
+	if t.dest != nil {
+		return *t.dest
+	}
+	return d.dest
+}
+
+// shared returns the names of the timers shown in the default destination.
+func (d *daemon) shared() []string {
+	var names []string
+	for _, name := range d.order {
+		if d.destination(d.timers[name]) == d.dest {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// shown returns the names of the timers the default destination should
+// display.
+func (d *daemon) shown(now time.Time) []string {
+	shared := d.shared()
+	switch {
+	case d.display == "rotate" && len(shared) > 1:
+		if now.Sub(d.rotated) >= d.rotate {
+			d.rotation++
+			d.rotated = now
+		}
+		return []string{shared[d.rotation%len(shared)]}
+	case slices.Contains(shared, d.display):
+		return []string{d.display}
+	}
+	return shared
+}
+
+// show writes statuses, by destination, starting the writer if needed.
+// Destinations left out are put back the way they were.
+func (d *daemon) show(statuses map[destination]string) {
+	if dryRun != nil {
+		// The dry run prints the status itself.
+		return
+	}
+	if d.writer == nil {
+		d.writer = newStatusWriter(d.link, d.cleanup.mode)
+	}
+	d.writer.show(statuses, d.hidden)
+}
+
+// phaseBegan reacts to t entering a new phase.
+func (d *daemon) phaseBegan(t *timer) {
+	if isBreak(t.phase().Kind) && d.guide != "" {
+		openGuide(d.guide, t.name)
+	}
+	d.focus.began(t.phase().Kind)
+	// The phase that ended is in the history now.
+	t.spentOn = ""
+}
+
+// countTaskTotals looks up the time the history spent on the task of
+// every timer whose task is new or whose phase has just begun.
+func (d *daemon) countTaskTotals() {
+	var sessions []session
+	loaded := false
+	for _, t := range d.timers {
+		if t.task == "" || t.spentOn == t.task {
+			continue
+		}
+		if !loaded {
+			sessions, _ = loadSessions()
+			loaded = true
+		}
+		t.taskSpent, t.spentOn = taskFocus(sessions, t.task), t.task
+	}
+}
+
+// refresh writes the current state of the timers to tmux.
+func (d *daemon) refresh(now time.Time) {
+	for _, name := range d.order {
+		if t := d.timers[name]; t.began {
+			t.began = false
+			d.phaseBegan(t)
+		}
+	}
+	if d.style.taskTotal {
+		d.countTaskTotals()
+	}
+
+	working := false
+	for _, t := range d.timers {
+		working = working || t.working()
+	}
+	d.ambient.sync(working)
+
+	if len(d.timers) == 0 {
+		d.tab.show("")
+		d.clearStatus(now)
+		return
+	}
+	d.cleanup.until = time.Time{}
+	d.tab.show(d.statusline(now))
+	format, style := d.format, d.style
+	if d.compact.narrow() {
+		format, style = d.compact.format, style.compact()
+	}
+	if d.link.degraded() {
+		format, style = d.compact.format, style.compact().minutes()
+	}
+	if d.power.low(now) {
+		style = style.minutes()
+	}
+	sep := " · "
+	if style.plain {
+		sep = "; "
+	}
+	// Timers with a destination of their own are shown there alone, e.g.
+	// a demo timer in a shared session. A timer named after its window
+	// needs no name there.
+	own := map[destination][]string{}
+	for _, name := range d.order {
+		if dest := d.destination(d.timers[name]); dest != d.dest {
+			named := len(d.order) > 1 && !(dest.Display == "window" && name == dest.Target)
+			own[dest] = append(own[dest], d.timers[name].status(now, named, style))
+		}
+	}
+	statuses := map[destination]string{}
+	for dest, parts := range own {
+		statuses[dest] = strings.Join(parts, sep)
+	}
+	if shown := d.shown(now); len(shown) > 0 {
+		parts := make([]string, 0, len(shown))
+		if label := d.eyes.label(now); label != "" {
+			parts = append(parts, label)
+		}
+		if label := d.micro.label(now); label != "" {
+			parts = append(parts, label)
+		}
+		for _, name := range shown {
+			parts = append(parts, d.timers[name].status(now, len(d.order) > 1, style))
+		}
+		fields := map[string]string{"timer": strings.Join(parts, sep)}
+		for k, v := range d.fields {
+			fields[k] = v
+		}
+		statuses[d.dest] = renderStatus(format, fields)
+	}
+	if d.mouse {
+		for dest, status := range statuses {
+			if !dest.window() {
+				statuses[dest] = clickable(status)
+			}
+		}
+	}
+	d.show(statuses)
+}
+
+// serve accepts control connections until ln is closed.
+func (d *daemon) serve(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go d.handle(conn)
+	}
+}
+
+// handle answers a single request.
+func (d *daemon) handle(conn net.Conn) {
+	defer d.guard()
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	var req request
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		return
+	}
+	if resp, ok := d.answer(req, time.Now()); ok {
+		json.NewEncoder(conn).Encode(resp)
+		return
+	}
+	d.mu.Lock()
+	resp := d.apply(req, time.Now())
+	d.publish(time.Now())
+	idle := len(d.timers) == 0
+	d.mu.Unlock()
+	json.NewEncoder(conn).Encode(resp)
+
+	if idle {
+		select {
+		case d.emptied <- struct{}{}:
+		default:
+		}
+	}
+	select {
+	case d.changed <- struct{}{}:
+	default:
+	}
+}
+
+// snapshot is the state of the timers as list, statusline and the
+// calendar feed report it.
+type snapshot struct {
+	taken  time.Time
+	status string
+	timers []timerInfo
+	blocks []busyBlock
+}
+
+// publish takes a snapshot for list, statusline and the calendar feed to
+// answer from. It is called with the lock held.
+func (d *daemon) publish(now time.Time) {
+	s := &snapshot{taken: now, timers: d.list(d.order, now), blocks: d.timerBlocks(now)}
+	if len(d.timers) > 0 {
+		s.status = d.statusline(now)
+	}
+	d.latest.Store(s)
+}
+
+// answer replies to ping, and to list and statusline from the latest
+// snapshot, without the lock. It reports false for other requests, and
+// for timers the snapshot does not know of, which apply then handles.
+func (d *daemon) answer(req request, now time.Time) (response, bool) {
+	if req.Cmd == "ping" {
+		return response{OK: true, Health: d.health(now)}, true
+	}
+	s := d.latest.Load()
+	if s == nil || req.Cmd != "list" && req.Cmd != "statusline" {
+		return response{}, false
+	}
+	resp := response{OK: true}
+	if req.Cmd == "statusline" {
+		resp.Status = s.status
+	}
+	for _, info := range s.timers {
+		if req.Cmd == "list" && req.Name != "" && info.Name != req.Name {
+			continue
+		}
+		if !info.Ends.IsZero() {
+			info.Remaining = info.Ends.Sub(now).Truncate(time.Second)
+		}
+		resp.Timers = append(resp.Timers, info)
+	}
+	if req.Name != "" && len(resp.Timers) == 0 {
+		return response{}, false
+	}
+	return resp, true
+}
+
+// list describes the named timers, with where each is shown: "-" for one
+// kept out of the shared status by the display setting.
+func (d *daemon) list(names []string, now time.Time) []timerInfo {
+	shown := map[string]bool{}
+	for _, name := range d.shown(now) {
+		shown[name] = true
+	}
+	var timers []timerInfo
+	for _, name := range names {
+		info := d.timers[name].info(now)
+		info.Target = d.destination(d.timers[name]).String()
+		if !shown[name] && d.destination(d.timers[name]) == d.dest {
+			info.Target = "-"
+		}
+		timers = append(timers, info)
+	}
+	return timers
+}
+
+// apply carries out req. It is called with the lock held.
+func (d *daemon) apply(req request, now time.Time) response {
+	if req.Name == "" && req.Cmd == "start" {
+		req.Name = defaultTimer
+	}
+	// Any command but the polling ones acknowledges a pending alert.
+	if req.Cmd != "list" && req.Cmd != "statusline" {
+		d.alerts.ack()
+	}
+	if req.Cmd == "statusline" {
+		resp := response{OK: true}
+		if len(d.timers) > 0 {
+			resp.Status = d.statusline(now)
+		}
+		for _, name := range d.order {
+			resp.Timers = append(resp.Timers, d.timers[name].info(now))
+		}
+		return resp
+	}
+	if req.Cmd == "restore" && req.State != nil {
+		d.restore(*req.State, now)
+		d.persist(now)
+		d.refresh(now)
+		return response{OK: true}
+	}
+	if req.Cmd == "unshelve" {
+		resp := d.unshelve(req.Name, now)
+		d.refresh(now)
+		return resp
+	}
+	if req.Cmd == "display" {
+		// The name selects what to display rather than a timer to act on.
+		switch {
+		case req.Name == "all":
+			d.display = ""
+		case req.Name == "rotate" || d.timers[req.Name] != nil:
+			d.display = req.Name
+		default:
+			return response{Error: fmt.Sprintf("no timer named %q", req.Name), Code: codeNotFound}
+		}
+		d.refresh(now)
+		return response{OK: true}
+	}
+	// Commands without a name act on every timer.
+	targets := d.order
+	if req.Name != "" {
+		if _, ok := d.timers[req.Name]; !ok && req.Cmd != "start" {
+			return response{Error: fmt.Sprintf("no timer named %q", req.Name), Code: codeNotFound}
+		}
+		targets = []string{req.Name}
+	}
+	for _, name := range targets {
+		if reason := d.breakRules.refuse(d.timers[name], req, now); reason != "" {
+			return response{Error: name + ": " + reason, Code: codeRejected}
+		}
+	}
+
+	switch req.Cmd {
+	case "list":
+		return response{OK: true, Timers: d.list(targets, now)}
+	case "start":
+		if len(req.Phases) == 0 {
+			return response{Error: "nothing to run"}
+		}
+		if _, ok := d.timers[req.Name]; ok {
+			if !req.Force {
+				return response{Error: fmt.Sprintf("timer %q is already running", req.Name), Code: codeExists}
+			}
+			d.stopTimer(req.Name, now, "")
+			d.fields = historyFields(loadConfig())
+		}
+		if req.Theme != "" {
+			d.useTheme(loadConfig(), req.Theme)
+		}
+		t := newTimer(req.Name, req.Phases, req.Task, req.Inferred, now)
+		t.project, t.tags, t.note = req.Project, req.Tags, req.Note
+		t.pair, t.pairSeen = req.Pair, now
+		t.dest, t.repo = req.Dest, req.Repo
+		t.git.Store(req.Git)
+		t.bus, t.nag = d.bus, d.nag
+		if req.Tab != nil && (d.tab == nil || *d.tab.tab != *req.Tab) {
+			go d.tab.clear()
+			d.tab = &tabBar{tab: req.Tab}
+		}
+		t.publish("started", now)
+		if t.profile = req.Profile; t.profile != nil {
+			runProfile(t, "on_start")
+		}
+		d.timers[req.Name] = t
+		d.order = append(d.order, req.Name)
+	case "shelve":
+		if resp := d.shelve(targets, now); !resp.OK {
+			return resp
+		}
+		if len(d.timers) == 0 {
+			return response{OK: true}
+		}
+	case "stop":
+		for _, name := range append([]string(nil), targets...) {
+			d.timers[name].exit = req.Exit
+			d.stopTimer(name, now, req.Outcome)
+		}
+		d.fields = historyFields(loadConfig())
+	case "pause":
+		for _, name := range targets {
+			d.timers[name].pause(now, req.Duration)
+		}
+	case "resume":
+		for _, name := range targets {
+			d.timers[name].resume(now)
+		}
+	case "add":
+		for _, name := range targets {
+			d.timers[name].extend(req.Duration)
+		}
+	case "skip":
+		for _, name := range targets {
+			d.timers[name].skip(now)
+		}
+		d.fields = historyFields(loadConfig())
+	case "attach":
+		for _, name := range targets {
+			d.timers[name].dest = req.Dest
+		}
+		session, _, _ := strings.Cut(req.Dest.Target, ":")
+		d.hidden = slices.DeleteFunc(d.hidden, func(s string) bool { return s == session })
+	case "detach":
+		session := req.Dest.Target
+		for _, name := range targets {
+			t := d.timers[name]
+			if t.dest != nil && (t.dest.Target == session || strings.HasPrefix(t.dest.Target, session+":")) {
+				t.dest = nil
+			}
+		}
+		if !slices.Contains(d.hidden, session) {
+			d.hidden = append(d.hidden, session)
+		}
+	case "exited":
+		// A `pomo run` command finished before its session.
+		for _, name := range targets {
+			d.timers[name].exit = req.Exit
+		}
+	case "interrupt":
+		for _, name := range targets {
+			d.timers[name].interrupt(now)
+		}
+	case "checkpoint":
+		for _, name := range targets {
+			d.timers[name].checkpoint(now, req.Note)
+		}
+	default:
+		return response{Error: fmt.Sprintf("unknown command %q", req.Cmd), Code: codeUsage}
+	}
+	d.persist(now)
+	d.refresh(now)
+	return response{OK: true}
+}
+
+// health reports the daemon's state. It does not need the lock.
+func (d *daemon) health(now time.Time) *health {
+	h := &health{
+		PID:      os.Getpid(),
+		Uptime:   now.Sub(d.started).Truncate(time.Second),
+		LastTick: now.Sub(time.Unix(0, d.lastTick.Load())).Truncate(time.Millisecond),
+		Healthy:  now.Before(time.Unix(0, d.due.Load()).Add(stallAfter)),
+		Hooks:    recentHookFailures(),
+		Outbox:   d.bus.pending(),
+	}
+	if s := d.latest.Load(); s != nil {
+		h.Timers = len(s.timers)
+	}
+	return h
+}
+
+// serveHealth serves GET /healthz on addr for supervision tools. It
+// answers 503 when the timer loop has stalled.
+func (d *daemon) serveHealth(addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		h := d.health(time.Now())
+		w.Header().Set("Content-Type", "application/json")
+		if !h.Healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(h)
+	})
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Printf("Failed to serve health endpoint: %v", err)
+	}
+}
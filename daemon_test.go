@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testDaemon returns a daemon with an empty config that keeps its data in
+// a temporary directory and finds no tmux, hooks or sounds to run.
+func testDaemon(t *testing.T) *daemon {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("XDG_DATA_HOME", filepath.Join(dir, "data"))
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "config"))
+	t.Setenv("PATH", dir)
+	t.Setenv("TMUX", "")
+	out := log.Writer()
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(out) })
+	return newDaemon(config{}, false)
+}
+
+// ask sends req to d as a client would and returns its response.
+func ask(t *testing.T, d *daemon, req request) response {
+	t.Helper()
+	client, server := net.Pipe()
+	defer client.Close()
+	go d.handle(server)
+	client.SetDeadline(time.Now().Add(5 * time.Second))
+	if err := json.NewEncoder(client).Encode(req); err != nil {
+		t.Errorf("%s: %v", req.Cmd, err)
+		return response{}
+	}
+	var resp response
+	if err := json.NewDecoder(client).Decode(&resp); err != nil {
+		t.Errorf("%s: %v", req.Cmd, err)
+	}
+	return resp
+}
+
+func TestConcurrentRequests(t *testing.T) {
+	d := testDaemon(t)
+	work := []phase{{Kind: "work", Duration: 25 * time.Minute}, {Kind: "break", Duration: 5 * time.Minute}}
+	if resp := ask(t, d, request{Cmd: "start", Name: "a", Phases: work}); !resp.OK {
+		t.Fatalf("start: %s", resp.Error)
+	}
+
+	// Polling goes through answer, the rest through apply, all while the
+	// timer loop ticks.
+	done := make(chan struct{})
+	var ticking sync.WaitGroup
+	ticking.Add(1)
+	go func() {
+		defer ticking.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			now := time.Now()
+			d.mu.Lock()
+			d.tick(now)
+			d.publish(now)
+			d.mu.Unlock()
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	var clients sync.WaitGroup
+	cmds := []string{"list", "statusline", "ping", "pause", "list", "resume"}
+	for i := range 8 {
+		clients.Add(1)
+		go func() {
+			defer clients.Done()
+			for j := range 30 {
+				cmd := cmds[(i+j)%len(cmds)]
+				if resp := ask(t, d, request{Cmd: cmd, Name: "a"}); !resp.OK {
+					t.Errorf("%s: %s", cmd, resp.Error)
+				}
+			}
+		}()
+	}
+	clients.Wait()
+
+	// A stop racing lists leaves them seeing the timer or nothing.
+	for range 4 {
+		clients.Add(1)
+		go func() {
+			defer clients.Done()
+			for range 20 {
+				resp := ask(t, d, request{Cmd: "list"})
+				if !resp.OK || len(resp.Timers) > 1 || len(resp.Timers) == 1 && resp.Timers[0].Name != "a" {
+					t.Errorf("list: %+v", resp)
+				}
+			}
+		}()
+	}
+	if resp := ask(t, d, request{Cmd: "stop", Name: "a", Outcome: "complete"}); !resp.OK {
+		t.Errorf("stop: %s", resp.Error)
+	}
+	clients.Wait()
+	close(done)
+	ticking.Wait()
+
+	if resp := ask(t, d, request{Cmd: "list"}); len(resp.Timers) != 0 {
+		t.Errorf("timers left after stop: %+v", resp.Timers)
+	}
+	sessions, err := loadSessions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || !sessions[0].Completed {
+		t.Errorf("recorded %+v, want one completed session", sessions)
+	}
+}
+
+func TestReadsDoNotWaitForTheLock(t *testing.T) {
+	d := testDaemon(t)
+	work := []phase{{Kind: "work", Duration: 25 * time.Minute}}
+	if resp := ask(t, d, request{Cmd: "start", Phases: work}); !resp.OK {
+		t.Fatalf("start: %s", resp.Error)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, cmd := range []string{"ping", "list", "statusline"} {
+		resp := ask(t, d, request{Cmd: cmd})
+		if !resp.OK {
+			t.Errorf("%s with the lock held: %+v", cmd, resp)
+		}
+	}
+	if h := d.health(time.Now()); h.Timers != 1 || !h.Healthy {
+		t.Errorf("health = %+v, want one timer and healthy", h)
+	}
+	if s := d.latest.Load(); s == nil || len(s.blocks) != 1 {
+		t.Errorf("calendar blocks not published: %+v", s)
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// displays are the places a status can be written to.
+var displays = []string{"status-right", "status-left", "window-name", "window", "option"}
+
+// windowOption is the window user option the "window" display writes, for
+// use in window-status-format.
+const windowOption = "@pomo"
+
+// statusOption is the user option the "option" display writes, for
+// status bar themes and plugins to place among their own modules.
+const statusOption = "@pomo_status"
+
+// destination is where a status is written: a tmux status option, globally
+// or for one session, the name of a window, a window's windowOption or
+// statusOption.
+type destination struct {
+	Display string `json:"display"`          // one of displays
+	Target  string `json:"target,omitempty"` // session, or session:window for a window; "" for global
+}
+
+// window reports whether d is shown in a single window.
+func (d destination) window() bool {
+	return d.Display == "window-name" || d.Display == "window"
+}
+
+// loadDestination reads status.display and status.target, where timers are
+// shown unless started with --display or --target.
+func loadDestination(cfg config) destination {
+	return destination{
+		Display: cfg.get("status.display", "status-right"),
+		Target:  cfg.get("status.target", ""),
+	}
+}
+
+// option returns the tmux option d writes, unless it is in a window.
+func (d destination) option() string {
+	if d.Display == "option" {
+		return statusOption
+	}
+	return d.Display
+}
+
+// String describes d for `pomo list`.
+func (d destination) String() string {
+	target := d.Target
+	if target == "" {
+		target = "global"
+	}
+	return d.Display + " (" + target + ")"
+}
+
+// command returns the tmux arguments that write value to d.
+func (d destination) command(value string) []string {
+	switch {
+	case d.Display == "window-name":
+		return []string{"rename-window", "-t", d.Target, value}
+	case d.Display == "window":
+		return []string{"set-option", "-w", "-t", d.Target, windowOption, value}
+	case d.Target == "":
+		return []string{"set-option", "-g", d.option(), value}
+	}
+	return []string{"set-option", "-t", d.Target, d.option(), value}
+}
+
+// scope returns the tmux flags that address the options of d's session,
+// window, or the global ones.
+func (d destination) scope() []string {
+	switch {
+	case d.window():
+		return []string{"-w", "-t", d.Target}
+	case d.Target == "":
+		return []string{"-g"}
+	}
+	return []string{"-t", d.Target}
+}
+
+// savedOption is the user option, set alongside d, that keeps what d
+// showed before pomo while a timer is shown there, so that it can still be
+// put back after a daemon that was killed outright. For the global
+// status-right it is savedStatusOption.
+func (d destination) savedOption() string {
+	return "@pomo-" + d.Display
+}
+
+// saved returns what d showed before pomo, saving it in savedOption
+// first. An earlier daemon's copy is kept, as what d shows now is likely
+// what that daemon wrote.
+func (d destination) saved() string {
+	option := func(flags string) []string {
+		return append(append([]string{"show-option", flags}, d.scope()...), d.savedOption())
+	}
+	if set, _ := tmuxCommand(option("-q")...).Output(); len(set) > 0 {
+		saved, _ := tmuxCommand(option("-qv")...).Output()
+		return strings.TrimRight(string(saved), "\n")
+	}
+	current := d.current()
+	save := append(append([]string{"set-option"}, d.scope()...), d.savedOption(), current)
+	tmuxCommand(tmuxBatch(save)...).Run()
+	return current
+}
+
+// forgetCommand returns the tmux arguments that drop the copy saved made.
+func (d destination) forgetCommand() []string {
+	return append(append([]string{"set-option", "-u"}, d.scope()...), d.savedOption())
+}
+
+// current returns what d shows before pomo writes to it. A window named
+// automatically shows "", as renaming it turns automatic-rename off.
+func (d destination) current() string {
+	var out []byte
+	switch {
+	case d.Display == "window-name":
+		out, _ = tmuxCommand("display-message", "-p", "-t", d.Target, "#{?automatic-rename,,#W}").Output()
+	case d.Display == "window":
+		out, _ = tmuxCommand("show-option", "-wqv", "-t", d.Target, windowOption).Output()
+	case d.Target == "":
+		out, _ = tmuxCommand("show-option", "-gqv", d.option()).Output()
+	default:
+		out, _ = tmuxCommand("show-option", "-qv", "-t", d.Target, d.option()).Output()
+	}
+	return strings.TrimRight(string(out), "\n")
+}
+
+// resetCommand returns the tmux arguments that put d back the way it was:
+// original for a window name, a global option or a session's or window's
+// option that was set, and unset for one that was not. A window that was
+// named automatically is again.
+func (d destination) resetCommand(original string) []string {
+	switch {
+	case original != "":
+	case d.Display == "window-name":
+		return []string{"set-option", "-wu", "-t", d.Target, "automatic-rename"}
+	case d.Display == "window":
+		return []string{"set-option", "-wu", "-t", d.Target, windowOption}
+	case d.Display != "window-name" && d.Target != "":
+		return []string{"set-option", "-u", "-t", d.Target, d.option()}
+	}
+	return d.command(original)
+}
+
+// resolveDestination checks a --display and --target given on the command
+// line, filling in what was left out from the config and, for a window,
+// the current one. Targets are normalised to a session name, or a
+// session:window for a window.
+func resolveDestination(cfg config, display, target string) (destination, error) {
+	d := loadDestination(cfg)
+	if display != "" {
+		d.Display = display
+	}
+	if target != "" {
+		d.Target = target
+	}
+	if !slices.Contains(displays, d.Display) {
+		return d, fmt.Errorf("unknown display %q: use %s", d.Display, strings.Join(displays, ", "))
+	}
+	format := "#{session_name}"
+	if d.window() {
+		format = "#{session_name}:#{window_index}"
+		if d.Target == "" {
+			d.Target = os.Getenv("TMUX_PANE")
+		}
+	}
+	switch {
+	case d.Target == "" && d.window():
+		return d, fmt.Errorf("%s needs a --target window outside tmux", d.Display)
+	case d.Target == "":
+		return d, nil
+	}
+	if err := tmuxCommand("has-session", "-t", d.Target).Run(); err != nil {
+		return d, fmt.Errorf("no tmux session or window %q", d.Target)
+	}
+	out, _ := tmuxCommand("display-message", "-p", "-t", d.Target, format).Output()
+	if target := strings.TrimSpace(string(out)); target != "" {
+		d.Target = target
+	}
+	return d, nil
+}
+
+// currentSession returns the tmux session pomo runs in, or "".
+func currentSession() string {
+	args := []string{"display-message", "-p"}
+	if pane := os.Getenv("TMUX_PANE"); pane != "" {
+		args = append(args, "-t", pane)
+	}
+	out, _ := tmuxCommand(append(args, "#{session_name}")...).Output()
+	return strings.TrimSpace(string(out))
+}
+
+// attachCommand implements `pomo attach [name]`, which moves running
+// timers, or the named one, to the current session's status (or the
+// --display and --target given) without restarting them, e.g. in a new
+// session that sets its own status-right.
+func attachCommand(args []string) {
+	fs := flag.NewFlagSet("attach", flag.ExitOnError)
+	display := fs.String("display", "", "show the timer in status-left, status-right, window-name or window")
+	target := fs.String("target", "", "show the timer in this session, or session:window")
+	args = parseFlags(fs, args)
+	name := ""
+	if len(args) > 0 {
+		name = args[0]
+	}
+	if *target == "" && os.Getenv("TMUX") == "" {
+		failf(codeNoTmux, "pomo attach needs a --target outside tmux")
+	}
+	dest, err := resolveDestination(loadConfig(), *display, *target)
+	if err != nil {
+		invalid(err)
+	}
+	if dest.Target == "" {
+		// Rather than the global status, which a session of its own
+		// may hide.
+		dest.Target = currentSession()
+	}
+	if _, err := send(request{Cmd: "attach", Name: name, Dest: &dest}); err != nil {
+		failSend(err)
+	}
+	succeed(nil)
+}
+
+// detachCommand implements `pomo detach [session]`, which keeps the
+// status out of a session, by default the current one, e.g. while it is
+// screen shared. Timers keep running and stay on show everywhere else;
+// those attached to the session go back to the usual display. `pomo
+// attach` in the session shows them there again.
+func detachCommand(args []string) {
+	session := ""
+	if len(args) > 0 {
+		session = args[0]
+	} else if os.Getenv("TMUX") != "" {
+		session = currentSession()
+	}
+	if session == "" {
+		failf(codeNoTmux, "pomo detach needs a session outside tmux")
+	}
+	if _, err := send(request{Cmd: "detach", Dest: &destination{Target: session}}); err != nil {
+		failSend(err)
+	}
+	succeed(nil)
+}
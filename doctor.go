@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// check is one finding of `pomo doctor`.
+type check struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail"`
+}
+
+// doctorCommand implements `pomo doctor`, which checks that tmux, the
+// daemon and the configured hooks work, and lists recent hook failures. It
+// exits non-zero if anything is wrong.
+func doctorCommand() {
+	cfg := loadConfig()
+	var checks []check
+	add := func(name string, err error, detail string) {
+		if err != nil {
+			detail = err.Error()
+		}
+		checks = append(checks, check{Name: name, OK: err == nil, Detail: detail})
+	}
+
+	version, err := tmuxCommand("display-message", "-p", "#{version}").Output()
+	add("tmux", err, strings.TrimSpace(string(version))+" at "+tmuxSocket())
+
+	resp, err := send(request{Cmd: "ping"})
+	switch {
+	case errors.Is(err, errNoDaemon):
+		add("daemon", nil, "not running")
+	case err != nil:
+		add("daemon", err, "")
+	case !resp.Health.Healthy:
+		add("daemon", fmt.Errorf("timer loop last ran %s ago", resp.Health.LastTick), "")
+	default:
+		add("daemon", nil, fmt.Sprintf("pid %d, %d timers", resp.Health.PID, resp.Health.Timers))
+	}
+
+	switch v, err := dataVersion(); {
+	case err != nil:
+		add("data", err, "")
+	case v > schemaVersion:
+		add("data", fmt.Errorf("schema version %d is newer than this pomo's, %d", v, schemaVersion), "")
+	case v < schemaVersion:
+		add("data", nil, fmt.Sprintf("schema version %d, migrated to %d when the daemon starts or history is read", v, schemaVersion))
+	default:
+		add("data", nil, fmt.Sprintf("schema version %d", v))
+	}
+
+	if args := workEndAction(cfg); args != nil {
+		_, err := exec.LookPath(args[0])
+		add("on_work_end", err, strings.Join(args, " "))
+	}
+	for _, step := range loadEscalation(cfg, nil).steps {
+		if step.action == "notify" {
+			tool := "notify-send"
+			if runtime.GOOS == "darwin" {
+				tool = "osascript"
+			}
+			_, err := exec.LookPath(tool)
+			add("escalation notify", err, tool)
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(cfg)) {
+		if value := cfg[key]; strings.HasPrefix(value, keyringPrefix) {
+			_, err := resolveSecret(value)
+			add(key, err, "keyring entry found")
+		}
+	}
+	for _, path := range recentCrashReports() {
+		add("crash", errors.New("the daemon crashed, see "+path), "")
+	}
+	if resp.Health != nil {
+		if resp.Health.Outbox > 0 {
+			add("outbox", nil, fmt.Sprintf("%d deliveries to integrations waiting", resp.Health.Outbox))
+		}
+		for _, f := range resp.Health.Hooks {
+			add("hook "+f.Hook, errors.New(f.At.Format("15:04:05")+" "+f.Error), "")
+		}
+	}
+
+	failed := false
+	for _, c := range checks {
+		failed = failed || !c.OK
+	}
+	if jsonOutput {
+		if failed {
+			writeResult(result{Error: &resultError{Code: codeFailed, Message: "some checks failed"}, Data: checks})
+			os.Exit(1)
+		}
+		succeed(checks)
+		return
+	}
+	for _, c := range checks {
+		state := "ok  "
+		if !c.OK {
+			state = "FAIL"
+		}
+		fmt.Printf("%s  %-20s %s\n", state, c.Name, c.Detail)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// simulationLimit is how much time a dry run simulates at most, as flow
+// and meetings never end by themselves.
+const simulationLimit = 12 * time.Hour
+
+// simulation is a dry run in progress. While one is set, pomo prints the
+// tmux commands, hooks, sounds and history writes it would make instead
+// of making them.
+type simulation struct {
+	mu         sync.Mutex // alerts are shown from goroutines
+	start, now time.Time
+}
+
+// dryRun is the dry run in progress, if any.
+var dryRun *simulation
+
+// logf prints a line of the dry run, stamped with the simulated time.
+func (s *simulation) logf(format string, args ...any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	elapsed := s.now.Sub(s.start)
+	fmt.Printf("[%02d:%02d:%02d] %s\n", int(elapsed.Hours()), int(elapsed.Minutes())%60, int(elapsed.Seconds())%60,
+		fmt.Sprintf(format, args...))
+}
+
+// simulated reports whether a dry run is in progress, printing what would
+// have been done if so.
+func simulated(format string, args ...any) bool {
+	if dryRun == nil {
+		return false
+	}
+	dryRun.logf(format, args...)
+	return true
+}
+
+// simulate runs req with a daemon of its own on a simulated clock, one
+// second at a time, until its timers and alerts are done. speed above zero
+// paces the simulation at that many times real time; otherwise it runs as
+// fast as it can.
+func simulate(req *request, speed float64) {
+	now := time.Now()
+	dryRun = &simulation{start: now, now: now}
+	d := newDaemon(loadConfig(), false)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if resp := d.apply(*req, now); resp.Error != "" {
+		failf(codeRejected, "%s", resp.Error)
+	}
+	shown := map[string]string{}
+	report := func() {
+		for _, name := range d.order {
+			t := d.timers[name]
+			if state := t.state() + "/" + t.phase().Kind; shown[name] != state {
+				shown[name] = state
+				dryRun.logf("status %s", strings.TrimSpace(t.status(dryRun.now, len(d.order) > 1, d.style)))
+			}
+		}
+	}
+	report()
+	for dryRun.now.Sub(now) < simulationLimit {
+		if len(d.timers) == 0 && !d.alerts.active() {
+			dryRun.logf("done")
+			return
+		}
+		if speed > 0 {
+			d.mu.Unlock()
+			time.Sleep(time.Duration(float64(time.Second) / speed))
+			d.mu.Lock()
+		}
+		dryRun.mu.Lock()
+		dryRun.now = dryRun.now.Add(time.Second)
+		dryRun.mu.Unlock()
+		d.tick(dryRun.now)
+		report()
+	}
+	dryRun.logf("stopped after %s", formatMinutes(simulationLimit))
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultAliases are the named durations accepted in place of a length,
+// e.g. `pomo start short`. Each may be overridden in the [durations]
+// section; breaks look up "<alias>_break" first.
+var defaultAliases = map[string]string{
+	"short":       "25m",
+	"long":        "50m",
+	"short_break": "5m",
+	"long_break":  "15m",
+}
+
+// hoursMinutes matches a compound such as "1h30", whose trailing number is
+// minutes.
+var hoursMinutes = regexp.MustCompile(`^\d+h\d+$`)
+
+// errNotPositive is wrapped by the error for a duration of zero or less.
+var errNotPositive = errors.New("must be positive")
+
+// parseMinutes parses a positive duration, treating a bare number as
+// minutes and a number after hours, as in "1h30", as minutes too.
+func parseMinutes(s string) (time.Duration, error) {
+	spec := strings.ToLower(strings.TrimSpace(s))
+	if _, err := strconv.Atoi(spec); err == nil || hoursMinutes.MatchString(spec) {
+		spec += "m"
+	}
+	d, err := time.ParseDuration(spec)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q: use minutes (25), 1h30 or 25m", s)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("invalid duration %q: %w", s, errNotPositive)
+	}
+	return d, nil
+}
+
+// parseDuration parses a duration given on the command line for a phase of
+// kind, which may also be an alias such as "short" or "long".
+func parseDuration(cfg config, kind, s string) (time.Duration, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	if isBreak(kind) {
+		if _, ok := defaultAliases[name+"_break"]; ok {
+			name += "_break"
+		}
+	}
+	if def, ok := defaultAliases[name]; ok {
+		d, err := parseMinutes(cfg.get("durations."+name, def))
+		if err != nil {
+			return 0, fmt.Errorf("durations.%s: %v", name, err)
+		}
+		return d, nil
+	}
+	d, err := parseMinutes(s)
+	if errors.Is(err, errNotPositive) {
+		return 0, err
+	}
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q: use minutes (25), 1h30, 25m, short or long", s)
+	}
+	return d, nil
+}
+
+// mustDuration parses s as parseDuration does, failing with a usage error
+// that explains what is accepted.
+func mustDuration(cfg config, kind, s string) time.Duration {
+	d, err := parseDuration(cfg, kind, s)
+	if err != nil {
+		invalid(err)
+	}
+	return d
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// uncolored returns s without tmux colours or escaping, for displays
+// other than tmux.
+func (s phaseStyle) uncolored() phaseStyle {
+	s.colors = map[string]string{}
+	s.literal = true
+	return s
+}
+
+// statusline returns the timers' status as plain text for an editor: the
+// timers the tmux status shows, or every timer when it shows none. It is
+// called with the lock held.
+func (d *daemon) statusline(now time.Time) string {
+	names := d.shown(now)
+	if len(names) == 0 {
+		names = d.order
+	}
+	style := d.style.uncolored()
+	sep := " · "
+	if style.plain {
+		sep = "; "
+	}
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, strings.TrimSpace(d.timers[name].status(now, len(d.order) > 1, style)))
+	}
+	return strings.Join(parts, sep)
+}
+
+// statuslineCommand implements `pomo statusline`, which prints the status
+// for an editor's statusline in one round trip to the daemon, without
+// tmux. It prints --idle, by default nothing, when no timer is running,
+// and never fails, as statuslines call it all the time.
+func statuslineCommand(args []string) {
+	fs := flag.NewFlagSet("statusline", flag.ExitOnError)
+	idle := fs.String("idle", "", "text to print when no timer is running")
+	parseFlags(fs, args)
+
+	resp, err := send(request{Cmd: "statusline"})
+	status := resp.Status
+	if err != nil || status == "" {
+		status = *idle
+	}
+	if jsonOutput {
+		succeed(map[string]any{"status": status, "timers": orEmpty(resp.Timers)})
+		return
+	}
+	fmt.Println(status)
+}
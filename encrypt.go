@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// encryptedPrefix marks a history field that is encrypted at rest.
+const encryptedPrefix = "enc:"
+
+// unreadable stands in for a field that cannot be decrypted, e.g. because
+// the passphrase is not available.
+const unreadable = "[encrypted]"
+
+// kdfIterations is the PBKDF2 work factor for deriving the history key.
+const kdfIterations = 200_000
+
+// keyCheck is sealed into keyCheckPath with the history key when it is
+// first used, so that a different passphrase can be told apart from the
+// right one rather than sealing new sessions under another key.
+const keyCheck = "pomo history key"
+
+// errWrongPassphrase is returned when the passphrase does not match the
+// one the history is encrypted with.
+var errWrongPassphrase = errors.New("the passphrase does not match the one the history is encrypted with")
+
+// historyCipher is the cipher for the task and note fields of the history
+// and of the timers the daemon saves, or nil when history.encrypt is off.
+// It fails when encryption is on but the key cannot be had, in which case
+// nothing may be written in the clear. As deriving the key is deliberately
+// slow, it is set up once per process; a failure, such as a keyring that
+// is locked for now, is tried again next time.
+var historyCipher = func() func() (cipher.AEAD, error) {
+	var (
+		mu    sync.Mutex
+		ready bool
+		aead  cipher.AEAD
+	)
+	return func() (cipher.AEAD, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if ready {
+			return aead, nil
+		}
+		cfg := loadConfig()
+		if cfg.get("history.encrypt", "false") == "true" {
+			var err error
+			if aead, err = loadHistoryCipher(cfg); err != nil {
+				log.Printf("Failed to set up history encryption: %v", err)
+				return nil, fmt.Errorf("history encryption: %w", err)
+			}
+		}
+		ready = true
+		return aead, nil
+	}
+}()
+
+func saltPath() string {
+	return filepath.Join(dataDir(), "history.salt")
+}
+
+func keyCheckPath() string {
+	return filepath.Join(dataDir(), "history.check")
+}
+
+// loadHistoryCipher derives the history key from the passphrase in
+// $POMO_PASSPHRASE or history.passphrase (by default the keyring entry
+// "history") and the salt kept in the data directory, creating the salt
+// the first time, and checks it against the key used before.
+func loadHistoryCipher(cfg config) (cipher.AEAD, error) {
+	passphrase := os.Getenv("POMO_PASSPHRASE")
+	if passphrase == "" {
+		var err error
+		if passphrase, err = resolveSecret(cfg.get("history.passphrase", keyringPrefix+"history")); err != nil {
+			return nil, err
+		}
+	}
+	if passphrase == "" {
+		return nil, fmt.Errorf("no passphrase")
+	}
+	salt, err := os.ReadFile(saltPath())
+	if os.IsNotExist(err) {
+		salt = make([]byte, 16)
+		rand.Read(salt)
+		if err = os.MkdirAll(dataDir(), 0755); err == nil {
+			err = os.WriteFile(saltPath(), salt, 0600)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, kdfIterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkKey(aead); err != nil {
+		return nil, err
+	}
+	return aead, nil
+}
+
+// checkKey makes sure aead has the key the history is encrypted with, by
+// opening keyCheckPath. Without one yet, it opens a field of the history
+// encrypted before, if any, and then writes keyCheckPath.
+func checkKey(aead cipher.AEAD) error {
+	check, err := os.ReadFile(keyCheckPath())
+	if err == nil {
+		if open(aead, strings.TrimSpace(string(check))) != keyCheck {
+			return errWrongPassphrase
+		}
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+	if sealed := firstSealed(); sealed != "" {
+		if _, ok := openOK(aead, sealed); !ok {
+			return errWrongPassphrase
+		}
+	}
+	return os.WriteFile(keyCheckPath(), []byte(sealWith(aead, keyCheck)+"\n"), 0600)
+}
+
+// firstSealed returns the first encrypted task or note in the history, or
+// "" when there is none.
+func firstSealed() string {
+	f, err := os.Open(historyPath())
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var s session
+		if json.Unmarshal(scanner.Bytes(), &s) != nil {
+			continue
+		}
+		for _, field := range []string{s.Task, s.Note} {
+			if strings.HasPrefix(field, encryptedPrefix) {
+				return field
+			}
+		}
+	}
+	return ""
+}
+
+// sealWith encrypts s with aead.
+func sealWith(aead cipher.AEAD, s string) string {
+	nonce := make([]byte, aead.NonceSize())
+	rand.Read(nonce)
+	return encryptedPrefix + base64.RawStdEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(s), nil))
+}
+
+// openOK decrypts s, sealed with aead, reporting whether it could.
+func openOK(aead cipher.AEAD, s string) (string, bool) {
+	data, ok := strings.CutPrefix(s, encryptedPrefix)
+	if !ok || aead == nil {
+		return "", false
+	}
+	raw, err := base64.RawStdEncoding.DecodeString(data)
+	if err != nil || len(raw) < aead.NonceSize() {
+		return "", false
+	}
+	plain, err := aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], nil)
+	if err != nil {
+		return "", false
+	}
+	return string(plain), true
+}
+
+// open decrypts s, sealed with aead, or returns unreadable when it cannot.
+func open(aead cipher.AEAD, s string) string {
+	if plain, ok := openOK(aead, s); ok {
+		return plain
+	}
+	return unreadable
+}
+
+// seal encrypts s when history encryption is on. It fails rather than
+// give s back unencrypted when the key is not available.
+func seal(s string) (string, error) {
+	aead, err := historyCipher()
+	if err != nil {
+		return "", err
+	}
+	if aead == nil || s == "" {
+		return s, nil
+	}
+	return sealWith(aead, s), nil
+}
+
+// unseal decrypts s if it was sealed, or returns unreadable when it
+// cannot.
+func unseal(s string) string {
+	if !strings.HasPrefix(s, encryptedPrefix) {
+		return s
+	}
+	aead, _ := historyCipher()
+	return open(aead, s)
+}
+
+// sealed returns s with its task, notes and commit messages encrypted.
+func (s session) sealed() (session, error) {
+	if _, err := historyCipher(); err != nil {
+		return s, err
+	}
+	// With the cipher set up, sealing cannot fail.
+	s.Task, _ = seal(s.Task)
+	s.Note, _ = seal(s.Note)
+	s.Checkpoints = sealCheckpoints(s.Checkpoints)
+	s.Git = sealGit(s.Git)
+	return s, nil
+}
+
+// unsealed returns s with its task, notes and commit messages decrypted.
+func (s session) unsealed() session {
+	s.Task, s.Note = unseal(s.Task), unseal(s.Note)
+	unsealCheckpoints(s.Checkpoints)
+	unsealGit(s.Git)
+	return s
+}
+
+// sealed returns st with its task, notes and commit messages encrypted,
+// for the state file and the shelf.
+func (st savedTimer) sealed() (savedTimer, error) {
+	if _, err := historyCipher(); err != nil {
+		return st, err
+	}
+	st.Task, _ = seal(st.Task)
+	st.Given, _ = seal(st.Given)
+	st.Inferred, _ = seal(st.Inferred)
+	st.Note, _ = seal(st.Note)
+	st.Checkpoints = sealCheckpoints(st.Checkpoints)
+	st.Git = sealGit(st.Git)
+	return st, nil
+}
+
+// unsealed returns st with its task, notes and commit messages decrypted.
+func (st savedTimer) unsealed() savedTimer {
+	st.Task, st.Given, st.Inferred = unseal(st.Task), unseal(st.Given), unseal(st.Inferred)
+	st.Note = unseal(st.Note)
+	unsealCheckpoints(st.Checkpoints)
+	unsealGit(st.Git)
+	return st
+}
+
+// sealCheckpoints returns a copy of checkpoints with their notes
+// encrypted. The cipher must be set up.
+func sealCheckpoints(checkpoints []checkpoint) []checkpoint {
+	checkpoints = append([]checkpoint(nil), checkpoints...)
+	for i := range checkpoints {
+		checkpoints[i].Note, _ = seal(checkpoints[i].Note)
+	}
+	return checkpoints
+}
+
+func unsealCheckpoints(checkpoints []checkpoint) {
+	for i := range checkpoints {
+		checkpoints[i].Note = unseal(checkpoints[i].Note)
+	}
+}
+
+// sealGit returns a copy of g with its commit messages encrypted. The
+// cipher must be set up.
+func sealGit(g *gitInfo) *gitInfo {
+	if g == nil {
+		return nil
+	}
+	sealed := *g
+	sealed.Commits = append([]string(nil), g.Commits...)
+	for i := range sealed.Commits {
+		sealed.Commits[i], _ = seal(sealed.Commits[i])
+	}
+	return &sealed
+}
+
+func unsealGit(g *gitInfo) {
+	if g == nil {
+		return
+	}
+	for i := range g.Commits {
+		g.Commits[i] = unseal(g.Commits[i])
+	}
+}
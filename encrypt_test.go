@@ -0,0 +1,113 @@
+package main
+
+import (
+	"crypto/cipher"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCipher derives the history key from passphrase, as the daemon would
+// with history.encrypt on.
+func testCipher(t *testing.T, passphrase string) (cipher.AEAD, error) {
+	t.Helper()
+	t.Setenv("POMO_PASSPHRASE", passphrase)
+	return loadHistoryCipher(config{})
+}
+
+// useCipher makes aead the history cipher for the rest of the test.
+func useCipher(t *testing.T, aead cipher.AEAD) {
+	t.Helper()
+	saved := historyCipher
+	historyCipher = func() (cipher.AEAD, error) { return aead, nil }
+	t.Cleanup(func() { historyCipher = saved })
+}
+
+func TestSealRoundTrip(t *testing.T) {
+	testData(t)
+	aead, err := testCipher(t, "right")
+	if err != nil {
+		t.Fatal(err)
+	}
+	useCipher(t, aead)
+
+	now := time.Now()
+	timer := savedTimer{
+		Name:        "a",
+		Task:        "write the report",
+		Given:       "report",
+		Inferred:    "write the report",
+		Note:        "mostly tables",
+		Checkpoints: []checkpoint{{At: now, Note: "first draft"}},
+		Git:         &gitInfo{Repo: "/src", Commits: []string{"abc123 Add tables"}},
+	}
+	sealed, err := timer.sealed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{sealed.Task, sealed.Given, sealed.Inferred, sealed.Note, sealed.Checkpoints[0].Note, sealed.Git.Commits[0]} {
+		if !strings.HasPrefix(field, encryptedPrefix) {
+			t.Errorf("saved in the clear: %q", field)
+		}
+	}
+	if timer.Checkpoints[0].Note != "first draft" || timer.Git.Commits[0] != "abc123 Add tables" {
+		t.Errorf("sealing changed the timer: %+v", timer)
+	}
+	got := sealed.unsealed()
+	if got.Task != timer.Task || got.Given != timer.Given || got.Inferred != timer.Inferred || got.Note != timer.Note ||
+		got.Checkpoints[0].Note != "first draft" || got.Git.Commits[0] != "abc123 Add tables" {
+		t.Errorf("unsealed %+v, want %+v", got, timer)
+	}
+
+	if err := savePlan(plan{Date: today(), Items: []planItem{{Task: "write the report", Estimate: 2}}}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(planPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "report") {
+		t.Errorf("plan saved in the clear:\n%s", data)
+	}
+	if p := loadPlan(); len(p.Items) != 1 || p.Items[0].Task != "write the report" {
+		t.Errorf("loaded plan %+v", p)
+	}
+}
+
+func TestWrongPassphraseRefused(t *testing.T) {
+	testData(t)
+	if _, err := testCipher(t, "right"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testCipher(t, "wrong"); !errors.Is(err, errWrongPassphrase) {
+		t.Errorf("wrong passphrase: err = %v, want %v", err, errWrongPassphrase)
+	}
+	aead, err := testCipher(t, "right")
+	if err != nil {
+		t.Fatalf("right passphrase after a wrong one: %v", err)
+	}
+
+	// History encrypted before the key was checked.
+	useCipher(t, aead)
+	sealed, err := session{Start: time.Now(), End: time.Now(), Task: "write the report"}.sealed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(historyPath(), []byte(storedLine(t, sealed)+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(keyCheckPath()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testCipher(t, "wrong"); !errors.Is(err, errWrongPassphrase) {
+		t.Errorf("wrong passphrase for the history: err = %v, want %v", err, errWrongPassphrase)
+	}
+	if _, err := os.Stat(keyCheckPath()); !os.IsNotExist(err) {
+		t.Error("checked the key against a wrong passphrase")
+	}
+	if _, err := testCipher(t, "right"); err != nil {
+		t.Errorf("right passphrase for the history: %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"log"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// escalationStep is one stage of the alert for a finished phase: action
+// runs once the phase has gone unacknowledged for after.
+type escalationStep struct {
+	after  time.Duration
+	action string // "bell", "notify", "message" or a shell command
+}
+
+// escalation alerts with increasing insistence when a phase ends, until
+// any pomo command acknowledges it.
+type escalation struct {
+	steps  []escalationStep
+	alarm  func() // plays the configured alarm for "bell"
+	policy hookPolicy
+
+	since   time.Time // when the unacknowledged phase ended; zero if none
+	next    int       // index of the next step to run
+	message string
+}
+
+// loadEscalation reads escalation.steps, a list of "<delay> <action>"
+// entries such as "30s notify". Without any, phase ends just ring the bell.
+func loadEscalation(cfg config, alarm func()) escalation {
+	e := escalation{alarm: alarm, policy: loadHookPolicy(cfg, "escalation")}
+	for _, step := range cfg.list("escalation.steps") {
+		delay, action, _ := strings.Cut(strings.TrimSpace(step), " ")
+		after, err := time.ParseDuration(delay)
+		if err != nil || strings.TrimSpace(action) == "" {
+			log.Printf("Ignoring escalation step %q", step)
+			continue
+		}
+		e.steps = append(e.steps, escalationStep{after: after, action: strings.TrimSpace(action)})
+	}
+	if len(e.steps) == 0 {
+		e.steps = []escalationStep{{action: "bell"}}
+	}
+	return e
+}
+
+// start begins escalating message from now, replacing any escalation
+// still in progress.
+func (e *escalation) start(now time.Time, message string) {
+	e.since, e.next, e.message = now, 0, message
+	e.run(now)
+}
+
+// run carries out every step that has come due.
+func (e *escalation) run(now time.Time) {
+	for e.active() && now.Sub(e.since) >= e.steps[e.next].after {
+		e.do(e.steps[e.next].action)
+		e.next++
+	}
+	if e.next == len(e.steps) {
+		e.since = time.Time{}
+	}
+}
+
+// active reports whether later steps are still waiting to run.
+func (e *escalation) active() bool {
+	return !e.since.IsZero() && e.next < len(e.steps)
+}
+
+// ack stops the escalation in progress.
+func (e *escalation) ack() {
+	e.since = time.Time{}
+}
+
+// notifyArgs returns the command showing message as a desktop
+// notification. On macOS it is read from POMO_MESSAGE, which must be set
+// to message.
+func notifyArgs(message string) []string {
+	if runtime.GOOS == "darwin" {
+		return []string{"osascript", "-e", `display notification (system attribute "POMO_MESSAGE") with title "pomo"`}
+	}
+	return []string{"notify-send", "pomo", message}
+}
+
+// do runs a single action in the background.
+func (e *escalation) do(action string) {
+	var args []string
+	switch action {
+	case "bell":
+		e.alarm()
+		return
+	case "message":
+		showMessage(e.message)
+		return
+	case "notify":
+		args = notifyArgs(e.message)
+	default:
+		args = []string{"sh", "-c", action}
+	}
+	runHook("escalation", e.policy, commandHook(args, "POMO_MESSAGE="+e.message), nil)
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limits on the outbox, so that an integration that is down for good
+// cannot make it grow without bound.
+const (
+	maxQueued   = 500
+	maxQueueAge = 24 * time.Hour
+	maxBackoff  = time.Hour
+)
+
+// event is something that happened to a timer, published to integrations.
+type event struct {
+	Type    string    `json:"type"` // started, paused, resumed, phase_ended, stopped or session
+	At      time.Time `json:"at"`
+	Timer   string    `json:"timer"`
+	Phase   string    `json:"phase,omitempty"`
+	Session *session  `json:"session,omitempty"` // the recorded session, for session events
+}
+
+// text describes ev in a sentence, for chat integrations.
+func (ev event) text() string {
+	if s := ev.Session; s != nil {
+		text := fmt.Sprintf("%s: %s of focus done", ev.Timer, formatMinutes(s.focused()))
+		if !s.Completed {
+			text = fmt.Sprintf("%s: work stopped after %s", ev.Timer, formatMinutes(s.End.Sub(s.Start)))
+		}
+		if s.Task != "" {
+			text += " on " + s.Task
+		}
+		return text
+	}
+	return fmt.Sprintf("%s: %s %s", ev.Timer, ev.Phase, strings.TrimPrefix(ev.Type, "phase_"))
+}
+
+// sink is an HTTP endpoint that integration payloads are posted to.
+type sink struct {
+	url     string
+	headers http.Header
+	timeout time.Duration
+	events  []string // event types sent to a webhook; all when empty
+	slack   bool     // post {"text": ...} rather than the event itself
+	caldav  bool     // PUT each caldavEvent into the calendar at url
+}
+
+// deliver posts payload to the sink, or for a CalDAV calendar puts the
+// event it holds.
+func (s *sink) deliver(payload []byte) error {
+	method, url, contentType := "POST", s.url, "application/json"
+	if s.caldav {
+		var ev caldavEvent
+		if err := json.Unmarshal(payload, &ev); err != nil {
+			return err
+		}
+		method, url, contentType = "PUT", s.url+ev.UID+".ics", "text/calendar; charset=utf-8"
+		payload = []byte(ev.ICS)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header = s.headers.Clone()
+	req.Header.Set("Content-Type", contentType)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s answered %s", url, resp.Status)
+	}
+	return nil
+}
+
+// loadHeaders reads a list of "Name: value" headers from cfg. Values may
+// name a keyring entry, e.g. "Authorization: keyring:otel".
+func loadHeaders(cfg config, key string) http.Header {
+	headers := http.Header{}
+	for _, h := range cfg.list(key) {
+		name, value, _ := strings.Cut(h, ":")
+		value, err := resolveSecret(strings.TrimSpace(value))
+		if err != nil {
+			log.Printf("Failed to read %s header %s: %v", key, name, err)
+			continue
+		}
+		headers.Set(strings.TrimSpace(name), value)
+	}
+	return headers
+}
+
+// delivery is a payload waiting in the outbox.
+type delivery struct {
+	Sink     string          `json:"sink"`
+	Payload  json.RawMessage `json:"payload"`
+	Queued   time.Time       `json:"queued"`
+	Attempts int             `json:"attempts"`
+	Next     time.Time       `json:"next"` // not retried before then
+}
+
+// eventBus publishes timer events to the configured integrations through
+// an outbox kept on disk, so that events are retried until they are
+// delivered rather than lost when an endpoint is unreachable or the daemon
+// exits. Deliveries to each sink are made in order.
+type eventBus struct {
+	mu     sync.Mutex
+	sinks  map[string]*sink
+	otel   *otelExporter
+	queue  []delivery
+	wake   chan struct{}
+	active string // sink being delivered to, if any
+	dirty  bool   // the queue has changed since it was saved
+
+	saving sync.Mutex // held while the outbox is written
+}
+
+func outboxPath() string {
+	return filepath.Join(dataDir(), "outbox.json")
+}
+
+// loadEventBus sets up the sinks in cfg and reads the outbox left by the
+// last daemon.
+func loadEventBus(cfg config) *eventBus {
+	b := &eventBus{sinks: map[string]*sink{}, otel: loadOtel(cfg), wake: make(chan struct{}, 1)}
+	if b.otel != nil {
+		b.sinks["otel"] = &sink{
+			url:     b.otel.endpoint + "/v1/traces",
+			headers: loadHeaders(cfg, "otel.headers"),
+			timeout: loadHookPolicy(cfg, "otel").timeout,
+		}
+	}
+	// Slack webhook URLs are secrets, so the URL may be a keyring entry.
+	if url, err := resolveSecret(cfg.get("webhook.url", "")); err != nil {
+		log.Printf("Failed to read webhook.url: %v", err)
+	} else if url != "" {
+		b.sinks["webhook"] = &sink{
+			url:     url,
+			headers: loadHeaders(cfg, "webhook.headers"),
+			timeout: loadHookPolicy(cfg, "webhook").timeout,
+			events:  cfg.list("webhook.events"),
+			slack:   cfg.get("webhook.format", "json") == "slack",
+		}
+	}
+	if s := loadCaldav(cfg); s != nil {
+		b.sinks["caldav"] = s
+	}
+	if data, err := os.ReadFile(outboxPath()); err == nil {
+		if err := json.Unmarshal(data, &b.queue); err != nil {
+			log.Printf("Failed to read the outbox: %v", err)
+		}
+	}
+	kept := b.queue[:0]
+	for _, d := range b.queue {
+		payload, ok := unsealPayload(d.Payload)
+		if !ok {
+			log.Printf("Dropping an encrypted %s payload from %s that cannot be decrypted", d.Sink, d.Queued.Format(time.DateTime))
+			continue
+		}
+		d.Payload = payload
+		kept = append(kept, d)
+	}
+	b.queue = kept
+	return b
+}
+
+// publish sends ev to the webhook, if it wants events of that type.
+func (b *eventBus) publish(ev event) {
+	if b == nil {
+		return
+	}
+	s := b.sinks["webhook"]
+	if s == nil || len(s.events) > 0 && !slices.Contains(s.events, ev.Type) {
+		return
+	}
+	var payload any = ev
+	if s.slack {
+		payload = map[string]string{"text": ev.text()}
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	b.enqueue("webhook", data, ev.Type+" event")
+}
+
+// recorded publishes a session that was added to the history, exporting
+// it as a span with events when OpenTelemetry is set up, and putting it in
+// the CalDAV calendar if it was completed.
+func (b *eventBus) recorded(timer string, s session, events []spanEvent) {
+	if b == nil {
+		return
+	}
+	b.publish(event{Type: "session", At: s.End, Timer: timer, Session: &s})
+	if b.otel != nil {
+		if data, err := b.otel.span(s, events); err == nil {
+			b.enqueue("otel", data, "span")
+		}
+	}
+	if b.sinks["caldav"] != nil && s.Completed {
+		if data, err := caldavPayload(s, time.Now()); err == nil {
+			b.enqueue("caldav", data, "calendar event")
+		}
+	}
+}
+
+// enqueue adds payload, described by what, to the outbox for the named
+// sink.
+func (b *eventBus) enqueue(name string, payload []byte, what string) {
+	if simulated("send %s to %s", what, name) {
+		return
+	}
+	b.mu.Lock()
+	now := time.Now()
+	b.queue = append(b.queue, delivery{Sink: name, Payload: payload, Queued: now, Next: now})
+	if len(b.queue) > maxQueued {
+		log.Printf("Outbox full, dropping a delivery to %s", b.queue[0].Sink)
+		b.queue = b.queue[1:]
+	}
+	// run saves it, so that publishing never waits for the disk.
+	b.dirty = true
+	b.mu.Unlock()
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+}
+
+// pending returns how many deliveries are waiting.
+func (b *eventBus) pending() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.queue)
+}
+
+// save writes the outbox to disk if it has changed. It is called without
+// the lock, which it only takes to copy the queue.
+func (b *eventBus) save() {
+	b.saving.Lock()
+	defer b.saving.Unlock()
+	b.mu.Lock()
+	queue := slices.Clone(b.queue)
+	dirty := b.dirty
+	b.dirty = false
+	b.mu.Unlock()
+	if !dirty {
+		return
+	}
+
+	// Payloads hold tasks and notes, so they are encrypted along with the
+	// history. Without the key they are only kept in memory.
+	stored := make([]delivery, 0, len(queue))
+	for _, d := range queue {
+		if payload, err := sealPayload(d.Payload); err == nil {
+			d.Payload = payload
+			stored = append(stored, d)
+		}
+	}
+	if len(stored) == 0 {
+		os.Remove(outboxPath())
+		return
+	}
+	data, err := json.Marshal(stored)
+	if err == nil {
+		err = os.MkdirAll(dataDir(), 0755)
+	}
+	if err == nil {
+		tmp := outboxPath() + ".tmp"
+		if err = os.WriteFile(tmp, data, 0600); err == nil {
+			err = os.Rename(tmp, outboxPath())
+		}
+	}
+	if err != nil {
+		log.Printf("Failed to save the outbox: %v", err)
+	}
+}
+
+// next returns the index of the next delivery to make and when it is due,
+// dropping deliveries that are too old. The first delivery of each sink
+// holds up the rest, so that they arrive in order. It is called with the
+// lock held.
+func (b *eventBus) next(now time.Time) (int, time.Time) {
+	queued := len(b.queue)
+	b.queue = slices.DeleteFunc(b.queue, func(d delivery) bool {
+		if now.Sub(d.Queued) < maxQueueAge && b.sinks[d.Sink] != nil {
+			return false
+		}
+		log.Printf("Dropping an undelivered %s payload from %s", d.Sink, d.Queued.Format(time.DateTime))
+		return true
+	})
+	if len(b.queue) != queued {
+		b.dirty = true
+	}
+	first, due := -1, time.Time{}
+	seen := map[string]bool{}
+	for i, d := range b.queue {
+		if seen[d.Sink] {
+			continue
+		}
+		seen[d.Sink] = true
+		if first == -1 || d.Next.Before(due) {
+			first, due = i, d.Next
+		}
+	}
+	return first, due
+}
+
+// run makes deliveries as they fall due, backing off exponentially from a
+// sink that fails. Failures are reported like those of hooks.
+func (b *eventBus) run() {
+	for {
+		b.save()
+		b.mu.Lock()
+		now := time.Now()
+		i, due := b.next(now)
+		if i == -1 || due.After(now) {
+			b.mu.Unlock()
+			wait := time.Hour
+			if i != -1 {
+				wait = due.Sub(now)
+			}
+			select {
+			case <-b.wake:
+			case <-time.After(wait):
+			}
+			continue
+		}
+		d := b.queue[i]
+		b.active = d.Sink
+		b.mu.Unlock()
+
+		err := b.sinks[d.Sink].deliver(d.Payload)
+
+		b.mu.Lock()
+		b.active = ""
+		// The queue may have been trimmed meanwhile; find d again.
+		i = slices.IndexFunc(b.queue, func(q delivery) bool { return q.Sink == d.Sink && q.Queued.Equal(d.Queued) })
+		switch {
+		case i == -1:
+		case err == nil:
+			b.queue = slices.Delete(b.queue, i, i+1)
+		default:
+			q := &b.queue[i]
+			q.Attempts++
+			q.Next = time.Now().Add(min(time.Duration(1<<min(q.Attempts, 20))*5*time.Second, maxBackoff))
+			log.Printf("Delivery to %s failed (attempt %d, retrying at %s): %v", d.Sink, q.Attempts, q.Next.Format(time.TimeOnly), err)
+			if q.Attempts == 1 {
+				recordHookFailure(d.Sink, err)
+			}
+		}
+		b.dirty = true
+		b.mu.Unlock()
+	}
+}
+
+// flush waits up to timeout for deliveries that are due now, so that the
+// last events of a daemon about to exit are not left for the next one.
+func (b *eventBus) flush(timeout time.Duration) {
+	defer b.save()
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		b.mu.Lock()
+		i, due := b.next(time.Now())
+		idle := b.active == "" && (i == -1 || due.After(time.Now()))
+		b.mu.Unlock()
+		if idle {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// sealPayload returns payload as it is kept in the outbox: sealed like the
+// history's fields, as a JSON string, when history.encrypt is on.
+func sealPayload(payload json.RawMessage) (json.RawMessage, error) {
+	sealed, err := seal(string(payload))
+	if err != nil || sealed == string(payload) {
+		return payload, err
+	}
+	return json.Marshal(sealed)
+}
+
+// unsealPayload undoes sealPayload. It reports false for a payload that
+// cannot be decrypted.
+func unsealPayload(payload json.RawMessage) (json.RawMessage, bool) {
+	var sealed string
+	if json.Unmarshal(payload, &sealed) != nil {
+		return payload, true
+	}
+	plain := unseal(sealed)
+	return json.RawMessage(plain), plain != unreadable
+}
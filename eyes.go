@@ -0,0 +1,71 @@
+package main
+
+import (
+	"time"
+)
+
+// eyeRestLength is how long the 20-20-20 reminder asks you to look away.
+const eyeRestLength = 20 * time.Second
+
+// eyeRest is the 20-20-20 reminder track: after every interval of work it
+// suggests looking at something 20 feet away for 20 seconds. It runs
+// independently of the timers' own phases.
+type eyeRest struct {
+	interval time.Duration // zero when disabled
+	style    string        // "message" or "status"
+	plain    bool          // words only, for screen readers
+
+	worked time.Duration // work time since the last reminder
+	last   time.Time     // previous call to track
+	until  time.Time     // end of the current reminder
+}
+
+// loadEyeRest reads the [eyes] section of cfg.
+func loadEyeRest(cfg config) eyeRest {
+	e := eyeRest{style: cfg.get("eyes.style", "message"), plain: accessible(cfg)}
+	if cfg.get("eyes.enabled", "false") != "true" {
+		return e
+	}
+	interval, err := time.ParseDuration(cfg.get("eyes.interval", "20m"))
+	if err != nil || interval <= 0 {
+		interval = 20 * time.Minute
+	}
+	e.interval = interval
+	return e
+}
+
+// track accounts for the time since the previous call, counting it when
+// working, and shows the reminder once a full interval has been worked.
+func (e *eyeRest) track(now time.Time, working bool) {
+	if e.interval == 0 {
+		return
+	}
+	if working && !e.last.IsZero() {
+		e.worked += now.Sub(e.last)
+	}
+	e.last = now
+	if e.worked < e.interval {
+		return
+	}
+	e.worked = 0
+	e.until = now.Add(eyeRestLength)
+	if e.style == "message" {
+		msg := "20-20-20: look at something 20 feet away for 20 seconds"
+		if !e.plain {
+			msg = "👀 " + msg
+		}
+		showMessage(msg)
+	}
+}
+
+// label returns the status prefix shown during a reminder, if any.
+func (e *eyeRest) label(now time.Time) string {
+	switch {
+	case e.style != "status" || !now.Before(e.until):
+	case e.plain:
+		return "look away for 20 seconds"
+	default:
+		return "👀 LOOK AWAY"
+	}
+	return ""
+}
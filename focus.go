@@ -0,0 +1,72 @@
+package main
+
+import (
+	"log"
+	"strings"
+)
+
+// breakFocus moves the tmux client to a designated window or pane when a
+// break begins and back to where it was when work begins again.
+type breakFocus struct {
+	target   string        // breaks.window, e.g. "music" or "notes:1.0"; "" disables
+	returnTo string        // where the client was before the break
+	pending  chan struct{} // closed once the last move is done
+}
+
+// began reacts to a phase of kind starting. It is called with the daemon
+// lock held, so it moves the client in the background, one move after
+// the other, rather than wait for tmux.
+func (f *breakFocus) began(kind string) {
+	if f.target == "" {
+		return
+	}
+	if dryRun != nil {
+		f.move(kind)
+		return
+	}
+	prev, done := f.pending, make(chan struct{})
+	f.pending = done
+	go func() {
+		defer close(done)
+		if prev != nil {
+			<-prev
+		}
+		f.move(kind)
+	}()
+}
+
+// move switches the client for a phase of kind starting.
+func (f *breakFocus) move(kind string) {
+	switch {
+	case isBreak(kind) && f.returnTo == "":
+		current, err := tmuxCommand("display-message", "-p", "#{session_name}:#{window_index}.#{pane_index}").Output()
+		if err != nil {
+			log.Printf("Failed to find the current tmux pane: %v", err)
+			return
+		}
+		f.returnTo = strings.TrimSpace(string(current))
+		switchClient(f.target)
+	case kind == "work" && f.returnTo != "":
+		switchClient(f.returnTo)
+		f.returnTo = ""
+	}
+}
+
+// restore moves the client back to where it was before a break, once the
+// moves under way are done, as the daemon exits.
+func (f *breakFocus) restore() {
+	if f.pending != nil {
+		<-f.pending
+	}
+	if f.returnTo != "" {
+		switchClient(f.returnTo)
+		f.returnTo = ""
+	}
+}
+
+// switchClient shows target in the most recently used tmux client.
+func switchClient(target string) {
+	if err := tmuxCommand("switch-client", "-t", target).Run(); err != nil {
+		log.Printf("Failed to switch to %s: %v", target, err)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxGitCommits bounds the commits recorded with one session.
+const maxGitCommits = 50
+
+// gitInfo is the git context of a session, recorded when git.record is on.
+type gitInfo struct {
+	Repo    string   `json:"repo"` // top level directory
+	Branch  string   `json:"branch,omitempty"`
+	Start   string   `json:"start"`             // HEAD when the session began
+	End     string   `json:"end,omitempty"`     // HEAD when it ended, if it moved
+	Commits []string `json:"commits,omitempty"` // made during the session, oldest first, as "<hash> <subject>"
+}
+
+// git runs git in dir and returns its trimmed output.
+func git(dir string, args ...string) (string, error) {
+	out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// currentGit returns the git context of dir, or nil outside a repository
+// or one without commits.
+func currentGit(dir string) *gitInfo {
+	repo, err := git(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil
+	}
+	head, err := git(repo, "rev-parse", "HEAD")
+	if err != nil {
+		return nil
+	}
+	branch, _ := git(repo, "symbolic-ref", "--short", "-q", "HEAD")
+	return &gitInfo{Repo: repo, Branch: branch, Start: head}
+}
+
+// restart returns the git context for a session beginning now, in the
+// same repository as g.
+func (g *gitInfo) restart() *gitInfo {
+	if g == nil {
+		return nil
+	}
+	if next := currentGit(g.Repo); next != nil {
+		return next
+	}
+	return g
+}
+
+// finish returns g completed with the commits made in its repository since
+// start, as a session beginning then ends.
+func (g *gitInfo) finish(start time.Time) *gitInfo {
+	if g == nil {
+		return nil
+	}
+	done := *g
+	head, err := git(g.Repo, "rev-parse", "HEAD")
+	if err != nil || head == g.Start {
+		return &done
+	}
+	done.End = head
+	// Commits pulled in from elsewhere were made before the session.
+	log, err := git(g.Repo, "log", "--reverse", "--format=%h %s", "--since="+start.Format(time.RFC3339), "-n", strconv.Itoa(maxGitCommits), g.Start+".."+head)
+	if err == nil && log != "" {
+		done.Commits = strings.Split(log, "\n")
+	}
+	return &done
+}
+
+// nudgeCommit reminds the user to commit or stash before the break when
+// repo has uncommitted changes, as git.nudge says: with a tmux "message"
+// or a desktop notification for "notify".
+func nudgeCommit(how, timer, repo string) {
+	if simulated("check %s for uncommitted changes", repo) {
+		return
+	}
+	go func() {
+		status, err := git(repo, "status", "--porcelain")
+		if err != nil || status == "" {
+			return
+		}
+		msg := fmt.Sprintf("%s: uncommitted changes in %s, commit or stash before the break", timer, filepath.Base(repo))
+		if how == "notify" {
+			runHook("git_nudge", loadHookPolicy(loadConfig(), "git_nudge"), commandHook(notifyArgs(msg), "POMO_MESSAGE="+msg), nil)
+			return
+		}
+		tmuxMessage(msg)
+	}()
+}
@@ -1,3 +1,3 @@
 module github.com/thakurnishu/pomo
 
-go 1.23.6
+go 1.24
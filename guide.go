@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"os"
+	"strings"
+	"time"
+)
+
+// defaultStretches are suggested when breaks.stretches is not configured.
+var defaultStretches = []string{
+	"Stand up and reach for the ceiling",
+	"Roll your shoulders backwards ten times",
+	"Look out of a window at something far away",
+	"Stretch your wrists and fingers",
+	"Walk around and get a glass of water",
+}
+
+// breathingSteps is one cycle of box breathing.
+var breathingSteps = []struct {
+	label string
+	secs  int
+}{
+	{"Breathe in", 4},
+	{"Hold", 4},
+	{"Breathe out", 4},
+	{"Hold", 4},
+}
+
+// openGuide shows the break guide for the named timer in a tmux popup.
+func openGuide(mode, name string) {
+	self, err := os.Executable()
+	if err != nil {
+		self = os.Args[0]
+	}
+	cmd := tmuxCommand("display-popup", "-E", "-w", "50", "-h", "9", "-T", " break ",
+		fmt.Sprintf("%s guide %s %s", self, mode, name))
+	if err := cmd.Start(); err != nil {
+		log.Printf("Failed to open break popup: %v", err)
+		return
+	}
+	go cmd.Wait()
+}
+
+// guideCommand implements the hidden `pomo guide <mode> <name>` run inside
+// the break popup. It exits, closing the popup, once the named timer is no
+// longer on a break.
+func guideCommand(args []string) {
+	if len(args) < 2 {
+		os.Exit(1)
+	}
+	mode, name := args[0], args[1]
+
+	stretch := ""
+	if mode == "stretch" {
+		stretches := loadConfig().list("breaks.stretches")
+		if len(stretches) == 0 {
+			stretches = defaultStretches
+		}
+		stretch = stretches[rand.IntN(len(stretches))]
+	}
+
+	os.Stdout.WriteString("\033[?25l")
+	defer os.Stdout.WriteString("\033[?25h")
+	for second := 0; ; second++ {
+		resp, err := send(request{Cmd: "list", Name: name})
+		if err != nil || len(resp.Timers) == 0 || !strings.HasSuffix(resp.Timers[0].Phase, "break") {
+			return
+		}
+		rem := resp.Timers[0].Remaining.Truncate(time.Second)
+
+		fmt.Print("\033[H\033[2J\n")
+		if mode == "breathing" {
+			step, left := breathingStep(second)
+			fmt.Printf("  %s... %d\n\n", step, left)
+		} else {
+			fmt.Printf("  %s\n\n", stretch)
+		}
+		fmt.Printf("  Break ends in %02d:%02d\n", int(rem.Minutes()), int(rem.Seconds())%60)
+		time.Sleep(time.Second)
+	}
+}
+
+// breathingStep returns the breathing instruction and the seconds left in
+// it at the given second of the exercise.
+func breathingStep(second int) (string, int) {
+	cycle := 0
+	for _, s := range breathingSteps {
+		cycle += s.secs
+	}
+	second %= cycle
+	for _, s := range breathingSteps {
+		if second < s.secs {
+			return s.label, s.secs - second
+		}
+		second -= s.secs
+	}
+	return "", 0
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// session is one work interval as recorded in the history file.
+type session struct {
+	ID        string        `json:"id,omitempty"` // a UUID, for integrations to tell sessions apart
+	Start     time.Time     `json:"start"`        // stored in UTC, read back in local time
+	End       time.Time     `json:"end"`
+	Offset    int           `json:"utc_offset,omitempty"` // seconds east of UTC where the session started
+	Duration  time.Duration `json:"duration"`
+	Task      string        `json:"task,omitempty"`
+	Note      string        `json:"note,omitempty"`
+	Project   string        `json:"project,omitempty"`
+	Tags      []string      `json:"tags,omitempty"`
+	Completed bool          `json:"completed"`
+	Break     time.Duration `json:"break,omitempty"` // flowtime break earned
+
+	Pauses        int           `json:"pauses,omitempty"`
+	Paused        time.Duration `json:"paused,omitempty"`        // total time paused, part of End - Start
+	Interruptions int           `json:"interruptions,omitempty"` // logged with `pomo interrupt`
+	Checkpoints   []checkpoint  `json:"checkpoints,omitempty"`
+
+	Git     *gitInfo `json:"git,omitempty"`
+	Command string   `json:"command,omitempty"`     // run with `pomo run`
+	Exit    *int     `json:"exit_status,omitempty"` // of Command, unless it outlived the session
+
+	pruned bool // stands in for a session folded into a rollup, see loadSummary
+}
+
+// focused returns the time spent on s, not counting pauses.
+func (s session) focused() time.Duration {
+	return s.End.Sub(s.Start) - s.Paused
+}
+
+// checkpoint is a milestone noted during a session with `pomo checkpoint`.
+type checkpoint struct {
+	At   time.Time `json:"at"`
+	Note string    `json:"note"`
+}
+
+// dataDir returns the directory pomo keeps its data in, honouring
+// XDG_DATA_HOME.
+func dataDir() string {
+	dir := os.Getenv("XDG_DATA_HOME")
+	if dir == "" {
+		home, _ := os.UserHomeDir()
+		dir = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dir, "pomo")
+}
+
+func historyPath() string {
+	return filepath.Join(dataDir(), "history.jsonl")
+}
+
+// lockHistory takes the lock that appendSession holds while it appends,
+// for anything that rewrites the history file to hold while it does, so
+// that a session recorded meanwhile is not lost. Closing the file lets go
+// of it.
+func lockHistory() (*os.File, error) {
+	if err := os.MkdirAll(dataDir(), 0755); err != nil {
+		return nil, err
+	}
+	return lockFile(filepath.Join(dataDir(), "history.lock"))
+}
+
+// appendSession adds s to the end of the history file.
+func appendSession(s session) error {
+	if simulated("record session: %s, completed %t, task %q", formatMinutes(s.End.Sub(s.Start)), s.Completed, s.Task) {
+		return nil
+	}
+	sealed, err := s.inUTC().sealed()
+	if err != nil {
+		return err
+	}
+	lock, err := lockHistory()
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	f, err := os.OpenFile(historyPath(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewEncoder(f).Encode(sealed)
+}
+
+// inUTC returns s with its times in UTC, as they are stored, noting the
+// offset of the zone it started in.
+func (s session) inUTC() session {
+	_, s.Offset = s.Start.Zone()
+	s.Start, s.End = s.Start.UTC(), s.End.UTC()
+	s.Checkpoints = append([]checkpoint(nil), s.Checkpoints...)
+	for i := range s.Checkpoints {
+		s.Checkpoints[i].At = s.Checkpoints[i].At.UTC()
+	}
+	return s
+}
+
+// local returns s with its times in local time, so that days and hours
+// are those of wherever pomo runs now.
+func (s session) local() session {
+	s.Start, s.End = s.Start.Local(), s.End.Local()
+	for i := range s.Checkpoints {
+		s.Checkpoints[i].At = s.Checkpoints[i].At.Local()
+	}
+	return s
+}
+
+// loadSessions reads every session in the history file, decrypting any
+// encrypted fields and giving times in local time. A missing file yields
+// no sessions.
+func loadSessions() ([]session, error) {
+	sessions, err := readSessions()
+	for i := range sessions {
+		sessions[i] = sessions[i].unsealed().local()
+	}
+	return sessions, err
+}
+
+// readSessions reads every session in the history file as stored.
+func readSessions() ([]session, error) {
+	f, err := os.Open(historyPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var sessions []session
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var s session
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			continue
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, scanner.Err()
+}
+
+// startOfDay returns local midnight of the day t falls on locally. Days
+// are counted by the calendar rather than in 24 hours, so that those a
+// DST change makes longer or shorter keep their sessions.
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Local().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+}
+
+// sessionsSince returns the sessions that started at or after since.
+func sessionsSince(sessions []session, since time.Time) []session {
+	var out []session
+	for _, s := range sessions {
+		if !s.Start.Before(since) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// startOfWeek returns local midnight of the Monday of the week t falls in.
+func startOfWeek(t time.Time) time.Time {
+	offset := (int(t.Local().Weekday()) + 6) % 7
+	return startOfDay(t).AddDate(0, 0, -offset)
+}
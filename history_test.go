@@ -0,0 +1,22 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadSessionsReadsLongLines(t *testing.T) {
+	start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.Local)
+	long := session{Start: start, End: start.Add(25 * time.Minute), Task: "a", Note: strings.Repeat("n", 100<<10), Completed: true}
+	after := session{Start: start.Add(time.Hour), End: start.Add(85 * time.Minute), Task: "b", Completed: true}
+	testData(t, storedLine(t, long), storedLine(t, after))
+
+	sessions, err := loadSessions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 || sessions[1].Task != "b" {
+		t.Errorf("loaded %d sessions, want both, the one after the long line too", len(sessions))
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxHookFailures is how many recent hook failures the daemon remembers
+// for `pomo doctor`.
+const maxHookFailures = 20
+
+// hookPolicy says how long a hook may run, how often it is retried, and
+// whether the timer waits for it.
+type hookPolicy struct {
+	timeout time.Duration
+	retries int
+	block   bool // hold the next phase until the hook succeeds
+}
+
+// loadHookPolicy reads hooks.<name>.timeout, .retries and .policy
+// ("block" or "background"), each falling back to the [hooks] section and
+// then to 30 seconds, no retries and running in the background. Only hooks
+// run when a phase ends can block.
+func loadHookPolicy(cfg config, name string) hookPolicy {
+	get := func(key, def string) string {
+		return cfg.get("hooks."+name+"."+key, cfg.get("hooks."+key, def))
+	}
+	p := hookPolicy{timeout: 30 * time.Second, block: get("policy", "background") == "block"}
+	if d, err := time.ParseDuration(get("timeout", "")); err == nil && d > 0 {
+		p.timeout = d
+	}
+	if n, err := strconv.Atoi(get("retries", "")); err == nil && n >= 0 {
+		p.retries = n
+	}
+	return p
+}
+
+// hookFailure is a hook that failed on every attempt.
+type hookFailure struct {
+	Hook  string    `json:"hook"`
+	At    time.Time `json:"at"`
+	Error string    `json:"error"`
+}
+
+// hookFailures holds the daemon's recent hook failures, newest last.
+var hookFailures struct {
+	mu   sync.Mutex
+	list []hookFailure
+}
+
+// recentHookFailures returns a copy of the recent hook failures.
+func recentHookFailures() []hookFailure {
+	hookFailures.mu.Lock()
+	defer hookFailures.mu.Unlock()
+	return append([]hookFailure(nil), hookFailures.list...)
+}
+
+// recordHookFailure remembers that the named hook failed with err.
+func recordHookFailure(name string, err error) {
+	hookFailures.mu.Lock()
+	defer hookFailures.mu.Unlock()
+	hookFailures.list = append(hookFailures.list, hookFailure{Hook: name, At: time.Now(), Error: err.Error()})
+	if len(hookFailures.list) > maxHookFailures {
+		hookFailures.list = hookFailures.list[1:]
+	}
+}
+
+// runHook runs fn in the background under policy p, retrying it when it
+// fails and giving each attempt p.timeout. done, if not nil, is called with
+// the final error.
+func runHook(name string, p hookPolicy, fn func(ctx context.Context) error, done func(error)) {
+	if dryRun != nil {
+		// Hooks only print what they would do, and always succeed.
+		fn(context.Background())
+		return
+	}
+	go func() {
+		var err error
+		for attempt := 1; attempt <= p.retries+1; attempt++ {
+			ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+			err = fn(ctx)
+			cancel()
+			if err == nil {
+				break
+			}
+			log.Printf("Hook %s failed (attempt %d of %d): %v", name, attempt, p.retries+1, err)
+		}
+		if err != nil {
+			recordHookFailure(name, err)
+		}
+		if done != nil {
+			done(err)
+		}
+	}()
+}
+
+// commandHook returns a hook function running args with extra environment
+// variables.
+func commandHook(args []string, env ...string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if simulated("run %q", args) {
+			return nil
+		}
+		cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+		cmd.Env = append(os.Environ(), env...)
+		// Don't wait on children that outlive a timed out command.
+		cmd.WaitDelay = time.Second
+		if out, err := cmd.CombinedOutput(); err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("timed out")
+			}
+			if msg := strings.TrimSpace(string(out)); msg != "" {
+				return fmt.Errorf("%v: %s", err, truncate(msg, 200))
+			}
+			return err
+		}
+		return nil
+	}
+}
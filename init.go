@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// prompter asks questions on the terminal.
+type prompter struct {
+	in *bufio.Reader // nil to take every default without asking
+}
+
+// ask prints question with its default and returns the answer, or def
+// when the answer is blank.
+func (p prompter) ask(question, def string) string {
+	if p.in == nil {
+		return def
+	}
+	fmt.Printf("%s [%s] ", question, def)
+	line, err := p.in.ReadString('\n')
+	if err != nil && line == "" {
+		// Input ended: take the defaults from here on.
+		fmt.Println()
+		return def
+	}
+	if answer := strings.TrimSpace(line); answer != "" {
+		return answer
+	}
+	return def
+}
+
+// choose asks until the answer is one of choices.
+func (p prompter) choose(question string, choices []string, def string) string {
+	for {
+		answer := p.ask(question+" ("+strings.Join(choices, ", ")+")", def)
+		if slices.Contains(choices, answer) {
+			return answer
+		}
+		fmt.Printf("Please answer one of %s.\n", strings.Join(choices, ", "))
+	}
+}
+
+// duration asks until the answer is a duration pomo understands.
+func (p prompter) duration(question, kind, def string) string {
+	for {
+		answer := p.ask(question, def)
+		if _, err := parseDuration(config{}, kind, answer); err == nil {
+			return answer
+		}
+		fmt.Println("Please give a duration such as 25m, 1h30 or 45.")
+	}
+}
+
+// confirm asks a yes or no question.
+func (p prompter) confirm(question string, def bool) bool {
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+	for {
+		switch strings.ToLower(p.ask(question, hint)) {
+		case strings.ToLower(hint):
+			return def
+		case "y", "yes":
+			return true
+		case "n", "no":
+			return false
+		}
+	}
+}
+
+// initCommand implements `pomo init`, which asks about durations, the
+// display, sounds and keybindings, writes the config file and offers to
+// add the keybindings, and the snippet a status bar plugin needs, to
+// tmux.conf. With --output json it asks nothing: it writes the defaults,
+// unless there is a config already, and gives what to add to tmux.conf.
+func initCommand() {
+	p := prompter{in: bufio.NewReader(os.Stdin)}
+	switch {
+	case jsonOutput:
+		p.in = nil
+	case !interactive():
+		invalid(fmt.Errorf("pomo init asks questions, so needs a terminal"))
+	}
+	path := configPath()
+	if _, err := os.Stat(path); err == nil {
+		if jsonOutput {
+			fail(codeRejected, path+" exists")
+		}
+		if !p.confirm(path+" exists. Replace it?", false) {
+			return
+		}
+	}
+
+	if !jsonOutput {
+		fmt.Println("Press enter to keep the suggestion in brackets.")
+	}
+	work := p.duration("How long is a work session?", "work", "45m")
+	brk := p.duration("And a break?", "break", defaultAliases["short_break"])
+	long := p.duration("And a long break?", "break", defaultAliases["long_break"])
+
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	theme := p.choose("Which theme?", names, defaultTheme)
+	display := p.choose("Where should the timer go?", []string{"status-right", "status-left", "plugin"}, "status-right")
+	framework := ""
+	if display == "plugin" {
+		frameworks := make([]string, 0, len(snippets))
+		for name := range snippets {
+			frameworks = append(frameworks, name)
+		}
+		slices.Sort(frameworks)
+		framework = p.choose("Which status bar plugin?", frameworks, "catppuccin")
+		display = "option"
+	}
+
+	alarm := p.ask("Sound file to play as phases end, or bell for the terminal bell?", "bell")
+	if alarm == "bell" {
+		alarm = ""
+	} else if _, err := os.Stat(expandHome(alarm)); err != nil {
+		fmt.Printf("Note: %s does not exist yet.\n", alarm)
+	}
+	tick := p.choose("Tick during work?", []string{"off", "second", "minute"}, "off")
+
+	var b strings.Builder
+	b.WriteString("# Written by pomo init.\n")
+	if theme != defaultTheme {
+		fmt.Fprintf(&b, "theme = %q\n", theme)
+	}
+	fmt.Fprintf(&b, "\n[start]\nduration = %q\n", work)
+	fmt.Fprintf(&b, "\n[durations]\nshort_break = %q\nlong_break = %q\n", brk, long)
+	if display != "status-right" {
+		fmt.Fprintf(&b, "\n[status]\ndisplay = %q\n", display)
+	}
+	if alarm != "" || tick != "off" {
+		b.WriteString("\n[sound]\n")
+		if alarm != "" {
+			fmt.Fprintf(&b, "alarm = %q\n", alarm)
+		}
+		if tick != "off" {
+			fmt.Fprintf(&b, "tick = %q\n", tick)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		failf(codeFailed, "Failed to write config: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		failf(codeFailed, "Failed to write config: %v", err)
+	}
+	if !jsonOutput {
+		fmt.Printf("Wrote %s.\n", path)
+	}
+
+	var tmux strings.Builder
+	if p.confirm("Bind prefix+P to the pomo menu and prefix+N to pomo next?", true) {
+		tmux.WriteString("bind-key P run-shell \"pomo menu\"\nbind-key N run-shell \"pomo next\"\n")
+	}
+	if framework != "" {
+		tmux.WriteString(snippets[framework](false))
+	}
+	if jsonOutput {
+		succeed(map[string]any{"config": path, "tmux": tmux.String()})
+		return
+	}
+	if tmux.Len() == 0 {
+		return
+	}
+	conf := tmuxConfPath()
+	fmt.Printf("\n%s\n", tmux.String())
+	if !p.confirm("Add this to "+conf+"?", true) {
+		return
+	}
+	f, err := os.OpenFile(conf, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		failf(codeFailed, "Failed to update %s: %v", conf, err)
+	}
+	defer f.Close()
+	if _, err := fmt.Fprintf(f, "\n# pomo\n%s", tmux.String()); err != nil {
+		failf(codeFailed, "Failed to update %s: %v", conf, err)
+	}
+	fmt.Printf("Updated %s; reload it with: tmux source-file %s\n", conf, conf)
+}
+
+// tmuxConfPath returns the tmux config file in use: the XDG one when it
+// exists, or else ~/.tmux.conf.
+func tmuxConfPath() string {
+	home, _ := os.UserHomeDir()
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		dir = filepath.Join(home, ".config")
+	}
+	xdg := filepath.Join(dir, "tmux", "tmux.conf")
+	if _, err := os.Stat(xdg); err == nil {
+		return xdg
+	}
+	return filepath.Join(home, ".tmux.conf")
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"log"
+	"strconv"
+	"strings"
+)
+
+// intervalOption is the tmux user option holding the user's own
+// status-interval while pomo manages it, so that it can still be restored
+// after a daemon that was killed outright.
+const intervalOption = "@pomo-status-interval"
+
+// manageInterval sets tmux's status-interval to status.interval seconds,
+// if set, so that the countdown is redrawn as often as pomo updates it. It
+// returns whether it did; restoreInterval puts the user's value back.
+func manageInterval(cfg config) bool {
+	interval := cfg.get("status.interval", "")
+	if interval == "" {
+		return false
+	}
+	if n, err := strconv.Atoi(interval); err != nil || n < 1 {
+		log.Printf("Ignoring status.interval %q: not a number of seconds", interval)
+		return false
+	}
+	// Keep the value saved by an earlier daemon: the current one is
+	// likely what that daemon set. Saving and setting go together, so
+	// that the user's value is never lost in between.
+	commands := [][]string{{"set-option", "-g", "status-interval", interval}}
+	saved, _ := tmuxCommand("show-option", "-gqv", intervalOption).Output()
+	if strings.TrimSpace(string(saved)) == "" {
+		current, err := tmuxCommand("show-option", "-gv", "status-interval").Output()
+		if err != nil {
+			log.Printf("Failed to read status-interval: %v", err)
+			return false
+		}
+		save := []string{"set-option", "-g", intervalOption, strings.TrimSpace(string(current))}
+		commands = append([][]string{save}, commands...)
+	}
+	if err := tmuxCommand(tmuxBatch(commands...)...).Run(); err != nil {
+		log.Printf("Failed to set status-interval: %v", err)
+	}
+	return true
+}
+
+// restoreInterval puts back the status-interval saved by manageInterval.
+func restoreInterval() {
+	saved, _ := tmuxCommand("show-option", "-gqv", intervalOption).Output()
+	commands := [][]string{{"set-option", "-gu", intervalOption}}
+	if value := strings.TrimSpace(string(saved)); value != "" {
+		commands = append([][]string{{"set-option", "-g", "status-interval", value}}, commands...)
+	}
+	tmuxCommand(tmuxBatch(commands...)...).Run()
+}
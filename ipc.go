@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+const socketPath = "/tmp/tmuxstatus.sock"
+
+// startLock is held by a daemon while it claims the control socket and
+// while it gives it up, so that one starting as another shuts down waits
+// for it rather than having its socket removed from under it.
+const startLock = "/tmp/tmuxstatus.lock"
+
+// request is sent by the CLI to the daemon over the control socket, one
+// JSON object per connection.
+type request struct {
+	Cmd      string        `json:"cmd"`
+	Name     string        `json:"name,omitempty"`
+	Phases   []phase       `json:"phases,omitempty"`
+	Duration time.Duration `json:"duration,omitempty"`
+	Project  string        `json:"project,omitempty"`
+	Task     string        `json:"task,omitempty"`     // given for a started timer, ahead of the plan
+	Inferred string        `json:"inferred,omitempty"` // task for work neither names, see inferTask
+	Tags     []string      `json:"tags,omitempty"`
+	Theme    string        `json:"theme,omitempty"`
+	Pair     *pairing      `json:"pair,omitempty"`
+	Note     string        `json:"note,omitempty"`    // for a checkpoint, or the sessions of a started timer
+	Force    bool          `json:"force,omitempty"`   // replace a running timer of the same name
+	Outcome  string        `json:"outcome,omitempty"` // how "stop" records the session
+	Exit     *int          `json:"exit,omitempty"`    // exit status of a `pomo run` command, for "stop"
+	Dest     *destination  `json:"dest,omitempty"`    // where to show a started timer
+	Git      *gitInfo      `json:"git,omitempty"`     // repository a started timer works in
+	Repo     string        `json:"repo,omitempty"`    // repository to check for uncommitted work, see nudgeCommit
+	Tab      *terminalTab  `json:"tab,omitempty"`     // terminal tab to show the status in too
+	Profile  *profile      `json:"profile,omitempty"` // session setup a timer is started with
+
+	DryRun  bool          `json:"-"` // simulate instead of starting, see dryrun.go
+	Speed   float64       `json:"-"` // pace of a dry run relative to real time
+	Outside string        `json:"-"` // what to do when started outside tmux, see outsideTmux
+	Delay   time.Duration `json:"-"` // wait before the first phase, see startTimer
+
+	State *savedState `json:"state,omitempty"` // timers for "restore"
+}
+
+// response is the daemon's reply to a request.
+type response struct {
+	OK     bool        `json:"ok"`
+	Error  string      `json:"error,omitempty"`
+	Code   string      `json:"code,omitempty"` // kind of error, see output.go
+	Timers []timerInfo `json:"timers,omitempty"`
+	Status string      `json:"status,omitempty"` // plain status text, for "statusline"
+	Health *health     `json:"health,omitempty"`
+}
+
+// health is the daemon's answer to a ping.
+type health struct {
+	PID      int           `json:"pid"`
+	Uptime   time.Duration `json:"uptime"`
+	Timers   int           `json:"timers"`
+	LastTick time.Duration `json:"last_tick"` // time since the timer loop last ran
+	Healthy  bool          `json:"healthy"`
+	Hooks    []hookFailure `json:"hook_failures,omitempty"` // recent, newest last
+	Outbox   int           `json:"outbox"`                  // integration deliveries waiting
+}
+
+// timerInfo describes a running timer in a list response.
+type timerInfo struct {
+	Name      string        `json:"name"`
+	Phase     string        `json:"phase"`
+	Remaining time.Duration `json:"remaining"`
+	Ends      time.Time     `json:"ends,omitempty"` // when the phase is due to end, unless paused
+	Paused    bool          `json:"paused"`
+	Held      bool          `json:"held,omitempty"` // paused until a blocking on_work_end hook succeeds
+	Pauses    int           `json:"pauses"`
+	PausedFor time.Duration `json:"paused_for"` // in the current phase, so far
+	Task      string        `json:"task,omitempty"`
+	Project   string        `json:"project,omitempty"`
+	Target    string        `json:"target"`
+}
+
+// errNoDaemon is returned by send when no daemon is listening.
+var errNoDaemon = errors.New("pomo daemon is not running")
+
+// errNoStart is wrapped by the error sendStarting returns when no daemon
+// could be started.
+var errNoStart = errors.New("failed to start the pomo daemon")
+
+// daemonError is returned by send when the daemon refuses a request.
+type daemonError struct {
+	code, message string
+}
+
+func (e *daemonError) Error() string {
+	return e.message
+}
+
+// send delivers req to the daemon and waits for its response.
+func send(req request) (response, error) {
+	var resp response
+	conn, err := net.DialTimeout("unix", socketPath, time.Second)
+	if err != nil {
+		return resp, errNoDaemon
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return resp, err
+	}
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		if errors.Is(err, io.EOF) {
+			// The daemon shut down before it got to the request.
+			return resp, errNoDaemon
+		}
+		return resp, err
+	}
+	if !resp.OK {
+		code := resp.Code
+		if code == "" {
+			code = codeRejected
+		}
+		return resp, &daemonError{code, resp.Error}
+	}
+	return resp, nil
+}
+
+// ensureDaemon starts the background daemon unless one is already
+// listening, and waits for its socket to come up.
+func ensureDaemon() error {
+	if conn, err := net.Dial("unix", socketPath); err == nil {
+		conn.Close()
+		return nil
+	}
+
+	cmd := exec.Command(os.Args[0], "daemon")
+	cmd.Env = append(os.Environ(), "TMUXSTATUS_DAEMON=1")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	cmd.Process.Release()
+
+	for i := 0; i < 50; i++ {
+		time.Sleep(50 * time.Millisecond)
+		if conn, err := net.Dial("unix", socketPath); err == nil {
+			conn.Close()
+			return nil
+		}
+	}
+	return errNoDaemon
+}
+
+// sendStarting delivers req to the daemon, starting one if need be. A
+// daemon that was shutting down as req arrived is replaced and req sent
+// again. It returns an error wrapping errNoStart when no daemon starts.
+func sendStarting(req request) (response, error) {
+	for try := 0; ; try++ {
+		if err := ensureDaemon(); err != nil {
+			return response{}, fmt.Errorf("%w: %v", errNoStart, err)
+		}
+		resp, err := send(req)
+		if !errors.Is(err, errNoDaemon) || try == 1 {
+			return resp, err
+		}
+	}
+}
+
+// claimSocket listens on the control socket at path, holding the start
+// lock at lock meanwhile. Another daemon may have won the race to start, in
+// which case it returns a nil listener, or be on its way out, in which case
+// it waits for it to go.
+func claimSocket(path, lock string) (net.Listener, error) {
+	f, err := lockFile(lock)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return nil, nil
+	}
+	os.Remove(path)
+	return net.Listen("unix", path)
+}
+
+// releaseSocket closes ln and removes the control socket at path. It takes
+// the start lock at lock first, which the caller keeps until it exits, so
+// that a daemon started meanwhile waits until this one is gone.
+func releaseSocket(ln net.Listener, path, lock string) (*os.File, error) {
+	f, err := lockFile(lock)
+	ln.Close()
+	os.Remove(path)
+	return f, err
+}
+
+// lockFile takes an exclusive lock on the file at path, creating it and
+// waiting for any other holder to let go.
+func lockFile(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
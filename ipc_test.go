@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClaimSocketRace(t *testing.T) {
+	dir := t.TempDir()
+	sock, lock := filepath.Join(dir, "sock"), filepath.Join(dir, "lock")
+
+	var wg sync.WaitGroup
+	listeners := make([]net.Listener, 2)
+	for i := range listeners {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ln, err := claimSocket(sock, lock)
+			if err != nil {
+				t.Errorf("claimSocket: %v", err)
+			}
+			listeners[i] = ln
+		}()
+	}
+	wg.Wait()
+	won := 0
+	for _, ln := range listeners {
+		if ln != nil {
+			won++
+			defer ln.Close()
+		}
+	}
+	if won != 1 {
+		t.Fatalf("%d daemons claimed the socket, want 1", won)
+	}
+	if conn, err := net.Dial("unix", sock); err != nil {
+		t.Errorf("socket of the winner gone: %v", err)
+	} else {
+		conn.Close()
+	}
+}
+
+func TestClaimSocketWaitsForShutdown(t *testing.T) {
+	dir := t.TempDir()
+	sock, lock := filepath.Join(dir, "sock"), filepath.Join(dir, "lock")
+	ln, err := claimSocket(sock, lock)
+	if err != nil || ln == nil {
+		t.Fatalf("claimSocket = %v, %v", ln, err)
+	}
+	held, err := releaseSocket(ln, sock, lock)
+	if err != nil {
+		t.Fatalf("releaseSocket: %v", err)
+	}
+
+	claimed := make(chan net.Listener)
+	go func() {
+		next, err := claimSocket(sock, lock)
+		if err != nil {
+			t.Errorf("claimSocket: %v", err)
+		}
+		claimed <- next
+	}()
+	select {
+	case <-claimed:
+		t.Fatal("claimed the socket while the old daemon was shutting down")
+	case <-time.After(100 * time.Millisecond):
+	}
+	held.Close()
+	select {
+	case next := <-claimed:
+		if next == nil {
+			t.Fatal("found the old daemon still listening")
+		}
+		next.Close()
+	case <-time.After(5 * time.Second):
+		t.Fatal("still waiting once the old daemon was gone")
+	}
+}
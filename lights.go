@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// lightColors names the colours lights.<state> may be set to, besides
+// "#rrggbb" and "off".
+var lightColors = map[string][3]int{
+	"red":     {255, 0, 0},
+	"orange":  {255, 128, 0},
+	"yellow":  {255, 220, 0},
+	"green":   {0, 255, 0},
+	"cyan":    {0, 255, 255},
+	"blue":    {0, 0, 255},
+	"purple":  {128, 0, 255},
+	"magenta": {255, 0, 255},
+	"pink":    {255, 105, 180},
+	"white":   {255, 255, 255},
+}
+
+// parseLightColor parses a colour name or "#rrggbb".
+func parseLightColor(s string) ([3]int, error) {
+	if rgb, ok := lightColors[s]; ok {
+		return rgb, nil
+	}
+	if len(s) == 7 && s[0] == '#' {
+		if n, err := strconv.ParseUint(s[1:], 16, 32); err == nil {
+			return [3]int{int(n >> 16), int(n >> 8 & 0xff), int(n & 0xff)}, nil
+		}
+	}
+	return [3]int{}, fmt.Errorf("unknown colour %q", s)
+}
+
+// hueXY converts rgb to the CIE xy coordinates the Hue API takes.
+func hueXY(rgb [3]int) [2]float64 {
+	var c [3]float64
+	for i, v := range rgb {
+		f := float64(v) / 255
+		if f > 0.04045 {
+			f = math.Pow((f+0.055)/1.055, 2.4)
+		} else {
+			f /= 12.92
+		}
+		c[i] = f
+	}
+	x := c[0]*0.664511 + c[1]*0.154324 + c[2]*0.162028
+	y := c[0]*0.283881 + c[1]*0.668433 + c[2]*0.047685
+	z := c[0]*0.000088 + c[1]*0.072310 + c[2]*0.986039
+	if x+y+z == 0 {
+		return [2]float64{0.3227, 0.329} // white
+	}
+	return [2]float64{x / (x + y + z), y / (x + y + z)}
+}
+
+// lights colours smart lights after the timer: Home Assistant light
+// entities or Philips Hue lights, from the [lights] section.
+type lights struct {
+	provider string // "homeassistant" or "hue"
+	url      string // Home Assistant's base URL, or the Hue bridge's
+	token    string // Home Assistant access token, or Hue application key
+	entities []string
+	colors   map[string]string // by timer state, as in themes, or "idle"
+
+	mu      sync.Mutex
+	last    string // colour last set
+	sending bool
+	failing bool
+}
+
+// loadLights reads the [lights] section of cfg. It returns nil unless
+// lights.provider and lights.entities are set.
+func loadLights(cfg config) *lights {
+	provider := cfg.get("lights.provider", "")
+	entities := cfg.list("lights.entities")
+	if provider == "" || len(entities) == 0 {
+		return nil
+	}
+	if provider != "homeassistant" && provider != "hue" {
+		log.Printf("Ignoring lights.provider %q", provider)
+		return nil
+	}
+	token, err := resolveSecret(cfg.get("lights.token", ""))
+	if err != nil {
+		log.Printf("Failed to read lights.token: %v", err)
+		return nil
+	}
+	l := &lights{
+		provider: provider,
+		url:      strings.TrimSuffix(cfg.get("lights.url", ""), "/"),
+		token:    token,
+		entities: entities,
+		colors:   map[string]string{},
+	}
+	for _, state := range append(states, "idle") {
+		color := cfg.get("lights."+state, "")
+		if color == "" {
+			continue
+		}
+		if _, err := parseLightColor(color); err != nil && color != "off" {
+			log.Printf("Ignoring lights.%s: %v", state, err)
+			continue
+		}
+		l.colors[state] = color
+	}
+	return l
+}
+
+// lightState returns the state the lights follow: that of the first timer
+// on show, or "idle". It is called with the lock held.
+func (d *daemon) lightState(now time.Time) string {
+	shown := d.shown(now)
+	if len(shown) == 0 {
+		shown = d.order
+	}
+	if len(shown) == 0 {
+		return "idle"
+	}
+	return d.timers[shown[0]].state()
+}
+
+// update sets the lights to the colour of state in the background when it
+// changes. States without a colour leave the lights as they are.
+func (l *lights) update(state string) {
+	if l == nil {
+		return
+	}
+	color, ok := l.colors[state]
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !ok || color == l.last || l.sending {
+		return
+	}
+	l.last = color
+	if simulated("set lights to %s", color) {
+		return
+	}
+	l.sending = true
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), tmuxTimeout)
+		defer cancel()
+		err := l.set(ctx, color)
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.sending = false
+		switch {
+		case err != nil && !l.failing:
+			log.Printf("Failed to set lights: %v", err)
+			l.failing = true
+			// Try again on the next tick.
+			l.last = ""
+		case err != nil:
+			l.last = ""
+		case l.failing:
+			log.Printf("Setting lights recovered")
+			l.failing = false
+		}
+	}()
+}
+
+// reset sets the lights to the idle colour, if any, as the daemon exits.
+func (l *lights) reset() {
+	if l == nil || dryRun != nil {
+		return
+	}
+	color, ok := l.colors["idle"]
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := l.set(ctx, color); err != nil {
+		log.Printf("Failed to set lights: %v", err)
+	}
+}
+
+// set turns every light to color, or off.
+func (l *lights) set(ctx context.Context, color string) error {
+	rgb, _ := parseLightColor(color)
+	if l.provider == "homeassistant" {
+		service, body := "turn_on", map[string]any{"entity_id": l.entities, "rgb_color": rgb}
+		if color == "off" {
+			service, body = "turn_off", map[string]any{"entity_id": l.entities}
+		}
+		return l.call(ctx, "POST", "/api/services/light/"+service, body)
+	}
+	body := map[string]any{"on": true, "xy": hueXY(rgb), "bri": 254}
+	if color == "off" {
+		body = map[string]any{"on": false}
+	}
+	for _, id := range l.entities {
+		path := "/api/" + url.PathEscape(l.token) + "/lights/" + url.PathEscape(id) + "/state"
+		if err := l.call(ctx, "PUT", path, body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// call makes a request to Home Assistant or the Hue bridge.
+func (l *lights) call(ctx context.Context, method, path string, body any) error {
+	data, _ := json.Marshal(body)
+	req, err := http.NewRequestWithContext(ctx, method, l.url+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if l.provider == "homeassistant" {
+		req.Header.Set("Authorization", "Bearer "+l.token)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s answered %s", l.provider, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+// listCommand prints every running timer, followed by the shelved ones.
+// Nothing is printed when the daemon is not running and nothing is
+// shelved.
+func listCommand() {
+	resp, err := send(request{Cmd: "list"})
+	if err == errNoDaemon {
+		if jsonOutput {
+			succeed([]timerInfo{})
+			return
+		}
+	} else if err != nil {
+		failSend(err)
+	}
+	if jsonOutput {
+		succeed(resp.Timers)
+		return
+	}
+	shelf, _ := loadShelf()
+	if err == errNoDaemon && len(shelf) == 0 {
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tPHASE\tREMAINING\tPAUSED\tTASK\tDISPLAY")
+	for _, t := range resp.Timers {
+		phase := t.Phase
+		if t.Paused {
+			phase += " (paused)"
+		}
+		paused := "-"
+		if t.Pauses > 0 {
+			paused = fmt.Sprintf("%s (%d)", formatClock(t.PausedFor), t.Pauses)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", t.Name, phase, formatClock(t.Remaining), paused, t.Task, t.Target)
+	}
+	for _, s := range shelf {
+		t := s.Timer
+		paused := "-"
+		if t.Pauses > 0 {
+			paused = fmt.Sprintf("%s (%d)", formatClock(t.PausedFor), t.Pauses)
+		}
+		fmt.Fprintf(w, "%s\t%s (shelved)\t%s\t%s\t%s\t-\n", t.Name, t.Phases[t.Current].Kind, formatClock(t.Remaining), paused, t.Task)
+	}
+	w.Flush()
+}
+
+// pingCommand checks that the daemon is responsive, printing its uptime
+// and state. It exits non-zero when the daemon is down or stalled.
+func pingCommand() {
+	resp, err := send(request{Cmd: "ping"})
+	if err != nil {
+		if jsonOutput {
+			failSend(err)
+		}
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	h := resp.Health
+	if jsonOutput {
+		if !h.Healthy {
+			writeResult(result{Error: &resultError{Code: "stalled", Message: "timer loop has stalled"}, Data: h})
+			os.Exit(1)
+		}
+		succeed(h)
+		return
+	}
+	state := "ok"
+	if !h.Healthy {
+		state = fmt.Sprintf("stalled (timer loop last ran %s ago)", h.LastTick)
+	}
+	fmt.Printf("%s: pid %d, up %s, %d timers\n", state, h.PID, h.Uptime, h.Timers)
+	if !h.Healthy {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// logCommand implements `pomo log [--days n]`, listing recent sessions in
+// detail with the checkpoints noted during them.
+func logCommand(args []string) {
+	fs := flag.NewFlagSet("log", flag.ExitOnError)
+	days := fs.Int("days", 1, "number of days to show")
+	parseFlags(fs, args)
+
+	sessions, err := loadSessions()
+	if err != nil {
+		failf(codeFailed, "Failed to read history: %v", err)
+	}
+	since := startOfDay(time.Now()).AddDate(0, 0, 1-max(*days, 1))
+	if jsonOutput {
+		succeed(orEmpty(sessionsSince(sessions, since)))
+		return
+	}
+	for _, s := range sessionsSince(sessions, since) {
+		status := "done"
+		if !s.Completed {
+			status = "abandoned"
+		}
+		line := fmt.Sprintf("%s  %-6s %-9s %s", s.Start.Format("2006-01-02 15:04"), formatMinutes(s.End.Sub(s.Start)), status, s.Task)
+		if s.Project != "" {
+			line += " (" + s.Project + ")"
+		}
+		if len(s.Tags) > 0 {
+			line += " #" + strings.Join(s.Tags, " #")
+		}
+		fmt.Println(strings.TrimSpace(line))
+		if s.Note != "" {
+			fmt.Printf("    %s\n", s.Note)
+		}
+		for _, c := range s.Checkpoints {
+			fmt.Printf("    %s  +%s  %s\n", c.At.Format("15:04"), formatClock(c.At.Sub(s.Start)), c.Note)
+		}
+	}
+}
+
+// checkpointCommand implements `pomo checkpoint <note> [--name timer]`.
+func checkpointCommand(args []string) {
+	fs := flag.NewFlagSet("checkpoint", flag.ExitOnError)
+	name := fs.String("name", "", "timer to note the checkpoint in (default: every timer)")
+	args = parseFlags(fs, args)
+	if len(args) < 1 {
+		usage("pomo checkpoint <note> [--name timer]")
+	}
+	if _, err := send(request{Cmd: "checkpoint", Name: *name, Note: strings.Join(args, " ")}); err != nil {
+		failSend(err)
+	}
+	succeed(nil)
+}
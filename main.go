@@ -1,13 +1,11 @@
 package main
 
 import (
-	"fmt"
+	"errors"
+	"flag"
 	"log"
 	"os"
-	"os/exec"
-	"os/signal"
-	"strconv"
-	"syscall"
+	"strings"
 	"time"
 )
 
@@ -23,207 +21,440 @@ func beep() {
 	tty.WriteString("\a")
 }
 
-// cleanup resets tmux's status-right option and removes the PID file.
-func cleanup() {
-	exec.Command("tmux", "set-option", "-g", "status-right", "").Run()
-	os.Remove(pidFile)
+// tmuxMessage shows msg with tmux display-message for 20 seconds. msg is
+// escaped, as display-message expands formats.
+func tmuxMessage(msg string) {
+	if err := tmuxCommand("display-message", "-d", "20000", tmuxEscape(msg)).Run(); err != nil {
+		log.Printf("Failed to show tmux message: %v", err)
+	}
 }
 
-// startPomodoro runs the pomodoro timer loop for the given duration.
-// It now supports pausing (via SIGUSR1) and resuming (via SIGUSR2).
-func startPomodoro(duration time.Duration) {
-	// Ensure we're inside a tmux session.
-	if os.Getenv("TMUX") == "" {
-		os.Exit(1)
+// showMessage shows msg without waiting for tmux, except in a dry run,
+// which prints it in order.
+func showMessage(msg string) {
+	if dryRun != nil {
+		tmuxMessage(msg)
+		return
 	}
+	go tmuxMessage(msg)
+}
 
-	// Write our PID to the PID file.
-	pid := os.Getpid()
-	err := os.WriteFile(pidFile, []byte(strconv.Itoa(pid)), 0644)
-	if err != nil {
-		log.Fatalf("Failed to write PID file: %v", err)
-	}
+// cleanup removes the PID file.
+func cleanup() {
+	os.Remove(pidFile)
+}
 
-	// Set up a signal channel to handle termination, pause, and resume.
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGUSR1, syscall.SIGUSR2)
+// parseFlags parses args with fs, allowing flags to appear after
+// positional arguments (e.g. `start 25m --name writing`). It returns the
+// positional arguments.
+func parseFlags(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		fs.Parse(args)
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
 
-	startTime := time.Now()
-	endTime := startTime.Add(duration)
+// timerFlags registers the flags shared by every command that starts a
+// timer and returns the start request they fill in.
+func timerFlags(fs *flag.FlagSet) *request {
+	req := &request{Cmd: "start", Dest: &destination{}}
+	fs.StringVar(&req.Name, "name", "", "name of the timer")
+	fs.StringVar(&req.Project, "project", "", "project the session belongs to (inferred by default)")
+	fs.StringVar(&req.Task, "task", "", "task worked on, ahead of the plan (inferred by default)")
+	fs.StringVar(&req.Note, "note", "", "note recorded with the sessions")
+	fs.Var((*tagList)(&req.Tags), "tag", "tag the session (repeatable, or comma separated)")
+	fs.StringVar(&req.Theme, "theme", "", "switch the status to a built-in theme")
+	fs.BoolVar(&req.Force, "force", false, "stop and log a running timer of the same name first")
+	fs.StringVar(&req.Dest.Display, "display", "", "show the timer in status-left, status-right, window-name or window")
+	fs.StringVar(&req.Dest.Target, "target", "", "show the timer in this session, or session:window")
+	fs.BoolVar(&req.DryRun, "dry-run", false, "print what would happen, without touching tmux or the history")
+	fs.Float64Var(&req.Speed, "speed", 0, "pace a dry run at this many times real time (default: instantly)")
+	fs.Func("in", "begin after this long, e.g. 10m", func(v string) (err error) {
+		req.Delay, err = parseDuration(loadConfig(), "delay", v)
+		return err
+	})
+	fs.StringVar(&req.Outside, "outside", "", "outside tmux: error, terminal or wait for a session (default tmux.outside)")
+	fs.Func("profile", "set up the session as [profiles.<name>] says", func(name string) (err error) {
+		req.Profile, err = loadProfile(loadConfig(), name)
+		return err
+	})
+	return req
+}
 
-	// Variables to handle pause/resume.
-	paused := false
-	var remaining time.Duration // remaining time when paused
+// tagList is a flag.Value collecting tags from repeated or comma separated
+// --tag flags.
+type tagList []string
 
-	ticker := time.NewTicker(1 * time.Second)
-	defer ticker.Stop()
+func (t *tagList) String() string {
+	return strings.Join(*t, ",")
+}
 
-	for {
-		select {
-		case s := <-sigChan:
-			switch s {
-			// Termination signals: cleanup and exit.
-			case syscall.SIGINT, syscall.SIGTERM:
-				cleanup()
-				os.Exit(0)
-			// SIGUSR1 pauses the timer.
-			case syscall.SIGUSR1:
-				if !paused {
-					remaining = endTime.Sub(time.Now())
-					paused = true
-					status := fmt.Sprintf("🍅 PAUSED %02d:%02d", int(remaining.Minutes()), int(remaining.Seconds())%60)
-					exec.Command("tmux", "set-option", "-g", "status-right", status).Run()
-				}
-			// SIGUSR2 resumes the timer.
-			case syscall.SIGUSR2:
-				if paused {
-					endTime = time.Now().Add(remaining)
-					paused = false
-				}
-			}
-		case <-ticker.C:
-			if paused {
-				// When paused, keep showing the same remaining time.
-				status := fmt.Sprintf("🍅 PAUSED %02d:%02d", int(remaining.Minutes()), int(remaining.Seconds())%60)
-				exec.Command("tmux", "set-option", "-g", "status-right", status).Run()
-			} else {
-				now := time.Now()
-				if now.Before(endTime) {
-					rem := endTime.Sub(now).Truncate(time.Second)
-					minutes := int(rem.Minutes())
-					seconds := int(rem.Seconds()) % 60
-					status := fmt.Sprintf("🍅 %02d:%02d", minutes, seconds)
-					cmd := exec.Command("tmux", "set-option", "-g", "status-right", status)
-					if err := cmd.Run(); err != nil {
-						log.Printf("Error updating tmux status-right: %v", err)
-					}
-				} else {
-					// Timer has expired.
-					elapsed := time.Since(startTime).Truncate(time.Second)
-					minutes := int(elapsed.Minutes())
-					seconds := int(elapsed.Seconds()) % 60
-					status := fmt.Sprintf("🍅 %02d:%02d passed", minutes, seconds)
-					exec.Command("tmux", "set-option", "-g", "status-right", status).Run()
-
-					// Emit a beep.
-					beep()
-
-					// Leave the finished status visible briefly.
-					time.Sleep(5 * time.Second)
-					cleanup()
-					os.Exit(0)
-				}
-			}
+func (t *tagList) Set(v string) error {
+	for _, tag := range strings.Split(v, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			*t = append(*t, tag)
 		}
 	}
+	return nil
 }
 
-// stopPomodoro stops a running pomodoro daemon by reading its PID file.
-func stopPomodoro() {
-	data, err := os.ReadFile(pidFile)
-	if err != nil {
-		os.Exit(1)
+// startTimer asks the daemon, starting it if necessary, to run phases for
+// req.
+func startTimer(req *request, phases []phase) {
+	if req.Delay > 0 {
+		// The timer counts down to its start in a phase of its own.
+		phases = append([]phase{{Kind: "delay", Duration: req.Delay}}, phases...)
 	}
-	pid, err := strconv.Atoi(string(data))
-	if err != nil {
-		os.Exit(1)
+	if req.DryRun {
+		req.Phases, req.Dest = phases, nil
+		textOnly("--dry-run")
+		simulate(req, req.Speed)
+		return
 	}
-
-	proc, err := os.FindProcess(pid)
-	if err != nil {
-		os.Exit(1)
+	watch := launchTimer(req, phases)
+	succeed(nil)
+	if watch {
+		watchTerminal(req.Name)
 	}
-
-	// Send SIGTERM to the process.
-	proc.Signal(syscall.SIGTERM)
-	os.Remove(pidFile)
 }
 
-// pausePomodoro sends the SIGUSR1 signal to the running pomodoro process.
-func pausePomodoro() {
-	data, err := os.ReadFile(pidFile)
-	if err != nil {
-		os.Exit(1)
+// launchTimer has the daemon, starting it if necessary, run phases for
+// req. It reports whether to show the timer in the terminal, having been
+// started outside tmux.
+func launchTimer(req *request, phases []phase) bool {
+	// Outside tmux, do as configured.
+	watch := false
+	if os.Getenv("TMUX") == "" {
+		watch = outsideTmux(req.Outside)
 	}
-	pid, err := strconv.Atoi(string(data))
-	if err != nil {
-		os.Exit(1)
+	req.Phases = phases
+	if req.Profile != nil {
+		req.Profile.apply(req)
 	}
-
-	proc, err := os.FindProcess(pid)
-	if err != nil {
-		os.Exit(1)
+	if req.Theme != "" {
+		if _, err := lookupTheme(req.Theme); err != nil {
+			failf(codeUsage, "Failed to load theme: %v", err)
+		}
 	}
-
-	// Send SIGUSR1 to pause the timer.
-	proc.Signal(syscall.SIGUSR1)
+	// A timer shown in a window needs to know which one, even when that
+	// comes from the config.
+	if *req.Dest == (destination{}) && !loadDestination(loadConfig()).window() {
+		req.Dest = nil
+	} else {
+		dest, err := resolveDestination(loadConfig(), req.Dest.Display, req.Dest.Target)
+		if err != nil {
+			invalid(err)
+		}
+		req.Dest = &dest
+		// Timers in windows are named after their window by default, so
+		// that each window can have its own.
+		if req.Name == "" && dest.Display == "window" {
+			req.Name = dest.Target
+		}
+	}
+	if req.Project == "" {
+		req.Project = inferProject(loadConfig())
+	}
+	if req.Task == "" {
+		req.Inferred = inferTask(loadConfig())
+	}
+	if loadConfig().get("git.record", "false") == "true" {
+		req.Git = currentGit(".")
+	}
+	if loadConfig().get("git.nudge", "") != "" {
+		req.Repo, _ = git(".", "rev-parse", "--show-toplevel")
+	}
+	req.Tab = currentTerminalTab(loadConfig())
+	// If a timer with this name is already running, exit silently unless
+	// --force replaces it.
+	if _, err := sendStarting(*req); err != nil {
+		failSend(err)
+	}
+	welcomeBack()
+	return watch
 }
 
-// resumePomodoro sends the SIGUSR2 signal to the running pomodoro process.
-func resumePomodoro() {
-	data, err := os.ReadFile(pidFile)
-	if err != nil {
-		os.Exit(1)
+// control sends cmd to the daemon for the timer named in args, or for
+// every timer when no name is given.
+func control(cmd string, args []string) {
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	var resumeAfter time.Duration
+	var complete, discard bool
+	switch cmd {
+	case "pause":
+		fs.Func("for", "resume automatically after this long, e.g. 10m", func(v string) (err error) {
+			resumeAfter, err = parseDuration(loadConfig(), "pause", v)
+			return err
+		})
+	case "stop":
+		fs.BoolVar(&complete, "complete", false, "count the session as done")
+		fs.BoolVar(&discard, "discard", false, "leave the session out of the history")
 	}
-	pid, err := strconv.Atoi(string(data))
-	if err != nil {
-		os.Exit(1)
+	args = parseFlags(fs, args)
+	name := ""
+	if len(args) > 0 {
+		name = args[0]
 	}
-
-	proc, err := os.FindProcess(pid)
-	if err != nil {
-		os.Exit(1)
+	outcome := ""
+	switch {
+	case complete && discard:
+		usage("pomo stop [--complete|--discard] [name]")
+	case complete:
+		outcome = "complete"
+	case discard:
+		outcome = "discard"
 	}
-
-	// Send SIGUSR2 to resume the timer.
-	proc.Signal(syscall.SIGUSR2)
+	if _, err := send(request{Cmd: cmd, Name: name, Duration: resumeAfter, Outcome: outcome}); err != nil {
+		failSend(err)
+	}
+	succeed(nil)
 }
 
 func main() {
-	if len(os.Args) < 2 {
-		os.Exit(1)
+	args := parseOutput(os.Args[1:])
+	if len(args) < 1 {
+		usage("pomo <command> [arguments]")
+	}
+
+	switch args[0] {
+	case "report", "stats", "suggest", "review", "history", "log", "prune":
+		upgradeData()
 	}
 
-	switch os.Args[1] {
+	switch args[0] {
 	case "start":
-		// If already running, exit silently.
-		if _, err := os.Stat(pidFile); err == nil {
-			os.Exit(1)
+		fs := flag.NewFlagSet("start", flag.ExitOnError)
+		req := timerFlags(fs)
+		presetName := fs.String("preset", "", "built-in work/break cycle, e.g. 52-17")
+		args := parseFlags(fs, args[1:])
+
+		if *presetName != "" {
+			phases, err := preset(*presetName)
+			if err != nil {
+				failf(codeUsage, "Failed to load preset: %v", err)
+			}
+			startTimer(req, phases)
+			return
 		}
 
-		// Use provided duration or default to 45 minutes.
-		durationStr := "45m"
-		if len(os.Args) >= 3 {
-			durationStr = os.Args[2]
+		// Use provided duration or the configured default. A profile's
+		// duration may also be a sequence or routine.
+		cfg := loadConfig()
+		if len(args) >= 1 {
+			startTimer(req, []phase{{Kind: "work", Duration: mustDuration(cfg, "work", args[0])}})
+			return
 		}
-		duration, err := time.ParseDuration(durationStr)
+		durationStr := mustDefaultStart(cfg, req, time.Now())
+		if req.Profile != nil && req.Profile.duration != "" {
+			phases, _, err := profilePhases(cfg, durationStr, map[string]bool{"profiles." + req.Profile.Name: true})
+			if err != nil {
+				failf(codeUsage, "Failed to load profile: profiles.%s.duration: %v", req.Profile.Name, err)
+			}
+			startTimer(req, phases)
+			return
+		}
+		startTimer(req, []phase{{Kind: "work", Duration: mustDuration(cfg, "work", durationStr)}})
+
+	case "flow":
+		fs := flag.NewFlagSet("flow", flag.ExitOnError)
+		req := timerFlags(fs)
+		parseFlags(fs, args[1:])
+		ratio, err := parseRatio(loadConfig().get("flow.ratio", "1/5"))
 		if err != nil {
-			os.Exit(1)
+			failf(codeFailed, "Failed to parse flow.ratio: %v", err)
 		}
+		startTimer(req, []phase{{Kind: "work", Ratio: ratio}})
 
-		// If not in daemon mode, spawn a detached background process.
-		if os.Getenv("TMUXSTATUS_DAEMON") == "" {
-			cmd := exec.Command(os.Args[0], "start", durationStr)
-			cmd.Env = append(os.Environ(), "TMUXSTATUS_DAEMON=1")
-			cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
-			if err := cmd.Start(); err != nil {
-				log.Fatalf("Failed to start tmuxstatus in background: %v", err)
+	case "routine":
+		fs := flag.NewFlagSet("routine", flag.ExitOnError)
+		req := timerFlags(fs)
+		args := parseFlags(fs, args[1:])
+		if len(args) < 1 {
+			usage("pomo routine <name>")
+		}
+		cfg := loadConfig()
+		phases, prof, err := resolveRoutine(cfg, args[0], map[string]bool{})
+		if err != nil {
+			failf(codeUsage, "Failed to load routine: %v", err)
+		}
+		if req.Profile == nil && prof != "" {
+			if req.Profile, err = loadProfile(cfg, prof); err != nil {
+				failf(codeFailed, "Failed to load profile: %v", err)
 			}
-			os.Exit(0)
 		}
-		// Daemon mode: run the pomodoro timer.
-		startPomodoro(duration)
+		startTimer(req, phases)
 
-	case "stop":
-		stopPomodoro()
+	case "meeting":
+		meetingCommand(args[1:])
 
-	case "pause":
-		pausePomodoro()
+	case "pair":
+		pairCommand(args[1:])
+
+	case "daemon":
+		// Started in the background by ensureDaemon, or run in the
+		// foreground with --persist under systemd or launchd.
+		fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+		persist := fs.Bool("persist", false, "keep running when no timers are left")
+		parseFlags(fs, args[1:])
+		if os.Getenv("TMUXSTATUS_DAEMON") == "" && !*persist {
+			invalid(errors.New("pomo daemon runs in the foreground only with --persist; timers start it themselves"))
+		}
+		runDaemon(*persist)
+
+	case "recover":
+		recoverCommand(args[1:])
+
+	case "shelve", "unshelve":
+		shelfCommand(args[0], args[1:])
+
+	case "away":
+		awayCommand(args[1:])
+
+	case "checkin":
+		checkinCommand(args[1:])
+
+	case "serve":
+		serveCommand(args[1:])
+
+	case "stop", "pause", "resume", "skip", "interrupt":
+		control(args[0], args[1:])
+
+	case "toggle":
+		toggle(args[1:])
+
+	case "next":
+		nextCommand(args[1:])
+
+	case "add":
+		// pomo add <duration> [name]
+		if len(args) < 2 {
+			usage("pomo add <duration> [name]")
+		}
+		d := mustDuration(loadConfig(), "", args[1])
+		name := ""
+		if len(args) >= 3 {
+			name = args[2]
+		}
+		if _, err := send(request{Cmd: "add", Name: name, Duration: d}); err != nil {
+			failSend(err)
+		}
+		succeed(nil)
+
+	case "break":
+		fs := flag.NewFlagSet("break", flag.ExitOnError)
+		req := timerFlags(fs)
+		args := parseFlags(fs, args[1:])
+
+		// A short break, 5 minutes unless durations.short_break says
+		// otherwise.
+		durationStr := "short"
+		if len(args) >= 1 {
+			durationStr = args[0]
+		}
+		duration := mustDuration(loadConfig(), "break", durationStr)
+		startTimer(req, []phase{{Kind: "break", Duration: duration}})
+
+	case "menu":
+		menuCommand()
+
+	case "guide":
+		// Run inside the break popup opened by the daemon.
+		textOnly("guide")
+		guideCommand(args[1:])
+
+	case "list":
+		listCommand()
+
+	case "ping":
+		pingCommand()
+
+	case "doctor":
+		doctorCommand()
+
+	case "themes":
+		themesCommand()
+
+	case "display":
+		// pomo display <name|all|rotate>
+		if len(args) < 2 {
+			usage("pomo display <name|all|rotate>")
+		}
+		if _, err := send(request{Cmd: "display", Name: args[1]}); err != nil {
+			failSend(err)
+		}
+		succeed(nil)
+
+	case "attach":
+		attachCommand(args[1:])
+
+	case "detach":
+		detachCommand(args[1:])
+
+	case "init":
+		initCommand()
+
+	case "tpm-snippet":
+		tpmSnippetCommand(args[1:])
+
+	case "big":
+		textOnly("big")
+		bigCommand(args[1:])
+
+	case "suggest":
+		suggestCommand(args[1:])
+
+	case "plan":
+		planCommand(args[1:])
+
+	case "report":
+		reportCommand(args[1:])
+
+	case "review":
+		reviewCommand(args[1:])
+
+	case "overlay":
+		overlayCommand(args[1:])
+
+	case "stats":
+		statsCommand(args[1:])
+
+	case "history":
+		historyCommand(args[1:])
+
+	case "log":
+		logCommand(args[1:])
+
+	case "checkpoint":
+		checkpointCommand(args[1:])
+
+	case "prune":
+		pruneCommand(args[1:])
+
+	case "secret":
+		secretCommand(args[1:])
+
+	case "board":
+		boardCommand(args[1:])
+
+	case "run":
+		runCommand(args[1:])
+
+	case "statusline":
+		statuslineCommand(args[1:])
+
+	case "backup":
+		backupCommand(args[1:])
 
-	case "resume":
-		resumePomodoro()
+	case "restore":
+		restoreCommand(args[1:])
 
 	default:
-		os.Exit(1)
+		usage("unknown command " + args[0])
 	}
 }
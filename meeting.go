@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// meeting is a finished meeting as recorded in the meetings file. Meetings
+// are kept apart from the history so they never count towards pomodoro
+// statistics or goals.
+type meeting struct {
+	Start   time.Time     `json:"start"` // stored in UTC, as sessions are
+	End     time.Time     `json:"end"`
+	Offset  int           `json:"utc_offset,omitempty"`
+	Planned time.Duration `json:"planned"`
+	Overrun time.Duration `json:"overrun,omitempty"`
+	Name    string        `json:"name"`
+	Project string        `json:"project,omitempty"`
+}
+
+func meetingsPath() string {
+	return filepath.Join(dataDir(), "meetings.jsonl")
+}
+
+// appendMeeting adds m to the end of the meetings file.
+func appendMeeting(m meeting) error {
+	if simulated("record meeting %q overrun %s", m.Name, formatMinutes(m.Overrun)) {
+		return nil
+	}
+	if err := os.MkdirAll(dataDir(), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(meetingsPath(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, m.Offset = m.Start.Zone()
+	m.Start, m.End = m.Start.UTC(), m.End.UTC()
+	return json.NewEncoder(f).Encode(m)
+}
+
+// meetingCommand implements `pomo meeting <duration>`: a countdown that
+// keeps counting into overtime until the meeting is stopped.
+func meetingCommand(args []string) {
+	fs := flag.NewFlagSet("meeting", flag.ExitOnError)
+	req := timerFlags(fs)
+	args = parseFlags(fs, args)
+	if len(args) < 1 {
+		usage("pomo meeting <duration>")
+	}
+	d := mustDuration(loadConfig(), "meeting", args[0])
+	if req.Name == "" {
+		req.Name = "meeting"
+	}
+	startTimer(req, []phase{{Kind: "meeting", Duration: d}})
+}
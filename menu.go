@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"slices"
+)
+
+// menuItem is an entry of the pomo menu, running pomo with args.
+type menuItem struct {
+	Label string `json:"label"`
+	Key   string `json:"key"`
+	Args  string `json:"args"`
+}
+
+// menuCommand implements `pomo menu`: it opens a tmux display-menu whose
+// entries depend on whether a timer is running and paused. With --output
+// json it lists them instead.
+func menuCommand() {
+	self, err := os.Executable()
+	if err != nil {
+		self = os.Args[0]
+	}
+
+	var items []menuItem
+	item := func(label, key, args string) {
+		items = append(items, menuItem{label, key, args})
+	}
+
+	resp, err := send(request{Cmd: "list"})
+	if err != nil || len(resp.Timers) == 0 {
+		item("Start 25m", "s", "start 25m")
+		item("Start 45m", "S", "start 45m")
+		item("Start break", "b", "break 5m")
+	} else {
+		paused := true
+		for _, t := range resp.Timers {
+			paused = paused && t.Paused
+		}
+		if paused {
+			item("Resume", "r", "resume")
+		} else {
+			item("Pause", "p", "pause")
+		}
+		item("Add 5m", "a", "add 5m")
+		item("Skip", "n", "skip")
+		item("Stop", "x", "stop")
+		// A separator.
+		item("", "", "")
+		item("Start break", "b", "stop; "+shellQuote(self)+" break 5m")
+	}
+	if jsonOutput {
+		succeed(slices.DeleteFunc(items, func(i menuItem) bool { return i.Label == "" }))
+		return
+	}
+
+	args := []string{"display-menu", "-T", "#[align=centre]pomo"}
+	for _, i := range items {
+		if i.Label == "" {
+			args = append(args, "")
+			continue
+		}
+		args = append(args, i.Label, i.Key, runShell(shellQuote(self)+" "+i.Args))
+	}
+	if err := tmuxCommand(args...).Run(); err != nil {
+		failf(codeFailed, "Failed to open tmux menu: %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"strings"
+	"time"
+)
+
+// microBreakMargin is how much of the work interval must be left for a
+// micro-break to be worth taking before the real break.
+const microBreakMargin = 5 * time.Minute
+
+// microBreaks suggest short pauses at randomized points within long work
+// intervals, shown subtly in the status, varying the rhythm of work as
+// interval research suggests. They never stop the timers.
+type microBreaks struct {
+	every  [2]time.Duration // range the work between micro-breaks is drawn from; zero when disabled
+	length [2]time.Duration // range their length is drawn from
+	plain  bool             // words only, for screen readers
+
+	worked time.Duration // continuous work since the last micro-break or real break
+	next   time.Duration // work after which the next micro-break comes
+	last   time.Time     // previous call to track
+	until  time.Time     // end of the current micro-break
+}
+
+// loadMicroBreaks reads the [microbreaks] section of cfg: every and
+// length are ranges such as "15m-25m" and "20s-60s", the defaults.
+func loadMicroBreaks(cfg config) microBreaks {
+	m := microBreaks{plain: accessible(cfg)}
+	if cfg.get("microbreaks.enabled", "false") != "true" {
+		return m
+	}
+	m.every = parseRange(cfg, "microbreaks.every", "15m-25m")
+	m.length = parseRange(cfg, "microbreaks.length", "20s-60s")
+	m.next = m.draw(m.every)
+	return m
+}
+
+// parseRange reads key as a range of durations such as "15m-25m", or a
+// single duration, falling back to def when it is neither.
+func parseRange(cfg config, key, def string) [2]time.Duration {
+	parse := func(v string) ([2]time.Duration, bool) {
+		lo, hi, ok := strings.Cut(v, "-")
+		if !ok {
+			hi = lo
+		}
+		a, err1 := time.ParseDuration(strings.TrimSpace(lo))
+		b, err2 := time.ParseDuration(strings.TrimSpace(hi))
+		return [2]time.Duration{a, b}, err1 == nil && err2 == nil && a > 0 && b >= a
+	}
+	v := cfg.get(key, def)
+	if r, ok := parse(v); ok {
+		return r
+	}
+	log.Printf("Ignoring %s %q: use a range such as %s", key, v, def)
+	r, _ := parse(def)
+	return r
+}
+
+// draw picks a duration in r at random, to the second.
+func (m *microBreaks) draw(r [2]time.Duration) time.Duration {
+	spread := int64((r[1] - r[0]) / time.Second)
+	return r[0] + time.Duration(rand.Int64N(spread+1))*time.Second
+}
+
+// track accounts for the time since the previous call. Work counts
+// towards the next micro-break, which comes once enough has been done
+// with at least microBreakMargin of the work interval, workLeft, to go.
+// Anything but work starts the count again.
+func (m *microBreaks) track(now time.Time, workLeft time.Duration) {
+	if m.every[0] == 0 {
+		return
+	}
+	switch {
+	case workLeft == 0:
+		m.worked = 0
+	case !m.last.IsZero() && !now.Before(m.until):
+		m.worked += now.Sub(m.last)
+	}
+	m.last = now
+	if m.worked < m.next || workLeft < microBreakMargin {
+		return
+	}
+	m.worked, m.next = 0, m.draw(m.every)
+	m.until = now.Add(m.draw(m.length))
+}
+
+// label returns the status prefix shown during a micro-break, if any,
+// e.g. "🌿 0:42".
+func (m *microBreaks) label(now time.Time) string {
+	if !now.Before(m.until) {
+		return ""
+	}
+	left := m.until.Sub(now).Round(time.Second)
+	if m.plain {
+		return fmt.Sprintf("micro-break, %d seconds", int(left.Seconds()))
+	}
+	return fmt.Sprintf("🌿 %d:%02d", int(left.Minutes()), int(left.Seconds())%60)
+}
+
+// workLeft returns the most work left in the interval of a timer at
+// work, with no end to open-ended work, or zero when none is at work.
+func (d *daemon) workLeft(now time.Time) time.Duration {
+	var left time.Duration
+	for _, t := range d.timers {
+		switch {
+		case !t.working():
+		case t.openEnded():
+			return microBreakMargin
+		default:
+			left = max(left, t.left(now))
+		}
+	}
+	return left
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// mouseRange names the status range around pomo's segment, for its key
+// bindings to tell clicks on it from others.
+const mouseRange = "pomo"
+
+// onSegment is true when a mouse event happened on pomo's segment.
+const onSegment = "#{==:#{mouse_status_range}," + mouseRange + "}"
+
+// mouseKeys are the clicks on pomo's segment it answers, with the pomo
+// command each runs. Clicks elsewhere do what they did before.
+var mouseKeys = []struct{ key, command string }{
+	{"MouseDown1Status", "toggle"},
+	{"MouseDown3Status", "menu"},
+}
+
+// mouseOption is the tmux user option holding the user's own binding of
+// key while pomo manages it, so that it can still be restored after a
+// daemon that was killed outright.
+func mouseOption(key string) string {
+	return "@pomo-" + key
+}
+
+// userRanges reports whether a tmux version, e.g. "3.4" or "next-3.5",
+// has clickable user ranges in the status line.
+func userRanges(version string) bool {
+	major, minor, _ := strings.Cut(strings.TrimPrefix(version, "next-"), ".")
+	m, err := strconv.Atoi(major)
+	if err != nil {
+		return false
+	}
+	n, _ := strconv.Atoi(strings.TrimRight(minor, "abcdefghijklmnopqrstuvwxyz"))
+	return m > 3 || m == 3 && n >= 4
+}
+
+// manageMouse makes pomo's segment clickable when tmux supports it and
+// status.mouse is not "off": a left click pauses or resumes the timers,
+// and a right click opens `pomo menu`. It returns whether it did;
+// restoreMouse puts the user's bindings back.
+func manageMouse(cfg config) bool {
+	switch v := cfg.get("status.mouse", "auto"); v {
+	case "off":
+		return false
+	case "auto":
+	default:
+		log.Printf("Ignoring status.mouse %q: use auto or off", v)
+	}
+	version, _ := tmuxCommand("display-message", "-p", "#{version}").Output()
+	if !userRanges(strings.TrimSpace(string(version))) {
+		return false
+	}
+	self, err := os.Executable()
+	if err != nil {
+		self = os.Args[0]
+	}
+	var commands [][]string
+	for _, m := range mouseKeys {
+		// As with status-interval, keep the binding saved by an earlier
+		// daemon, and never save pomo's own.
+		saved, _ := tmuxCommand("show-option", "-gqv", mouseOption(m.key)).Output()
+		original := strings.TrimRight(string(saved), "\n")
+		if current := boundCommand(m.key); original == "" && !strings.Contains(current, onSegment) {
+			original = current
+			commands = append(commands, []string{"set-option", "-g", mouseOption(m.key), original})
+		}
+		bind := []string{"bind-key", "-n", m.key, "if-shell", "-F", onSegment, runShell(shellQuote(self) + " " + m.command)}
+		if original != "" {
+			bind = append(bind, original)
+		}
+		commands = append(commands, bind)
+	}
+	if err := tmuxCommand(tmuxBatch(commands...)...).Run(); err != nil {
+		log.Printf("Failed to bind status clicks: %v", err)
+	}
+	return true
+}
+
+// boundCommand returns the command key is bound to in the root table, or
+// "".
+func boundCommand(key string) string {
+	out, err := tmuxCommand("list-keys", "-T", "root", key).Output()
+	if err != nil {
+		return ""
+	}
+	line := strings.TrimSpace(string(out))
+	if i := strings.Index(line, " "+key+" "); i >= 0 {
+		return strings.TrimSpace(line[i+len(key)+2:])
+	}
+	return ""
+}
+
+// restoreMouse puts back the bindings saved by manageMouse.
+func restoreMouse() {
+	var commands [][]string
+	for _, m := range mouseKeys {
+		saved, _ := tmuxCommand("show-option", "-gqv", mouseOption(m.key)).Output()
+		if original := strings.TrimRight(string(saved), "\n"); original != "" {
+			commands = append(commands, []string{"bind-key", "-n", m.key, original})
+		} else {
+			commands = append(commands, []string{"unbind-key", "-n", m.key})
+		}
+		commands = append(commands, []string{"set-option", "-gu", mouseOption(m.key)})
+	}
+	tmuxCommand(tmuxBatch(commands...)...).Run()
+}
+
+// clickable wraps status in pomo's status range.
+func clickable(status string) string {
+	return "#[range=user|" + mouseRange + "]" + status + "#[norange]"
+}
+
+// toggle implements `pomo toggle [name]`, which pauses the timers, or the
+// named one, unless they are all paused already, in which case it resumes
+// them.
+func toggle(args []string) {
+	name := ""
+	if len(args) > 0 {
+		name = args[0]
+	}
+	resp, err := send(request{Cmd: "list"})
+	if err != nil {
+		failSend(err)
+	}
+	paused := true
+	for _, t := range resp.Timers {
+		if name == "" || t.Name == name {
+			paused = paused && t.Paused
+		}
+	}
+	if paused {
+		control("resume", args)
+	} else {
+		control("pause", args)
+	}
+}
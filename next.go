@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// nextCommand implements `pomo next [name]`, which moves on from wherever
+// the timers are, so that a single keybinding can drive them: it starts a
+// default session when none is running, lets a timer held at the end of
+// an interval or a meeting run over carry on to the next phase, and
+// otherwise skips to the next interval once confirmed.
+func nextCommand(args []string) {
+	fs := flag.NewFlagSet("next", flag.ExitOnError)
+	yes := fs.Bool("yes", false, "skip the current interval without asking")
+	args = parseFlags(fs, args)
+	name := ""
+	if len(args) > 0 {
+		name = args[0]
+	}
+
+	resp, err := send(request{Cmd: "list"})
+	if err != nil && !errors.Is(err, errNoDaemon) {
+		failSend(err)
+	}
+	var running []timerInfo
+	for _, t := range resp.Timers {
+		if (name == "" || t.Name == name) && t.Phase != "done" {
+			running = append(running, t)
+		}
+	}
+	if len(running) == 0 {
+		cfg := loadConfig()
+		req := &request{Cmd: "start", Name: name, Dest: &destination{}}
+		startTimer(req, []phase{{Kind: "work", Duration: mustDuration(cfg, "work", mustDefaultStart(cfg, req, time.Now()))}})
+		return
+	}
+
+	// Timers waiting at a boundary move on without asking.
+	waiting := false
+	for _, t := range running {
+		cmd := ""
+		switch {
+		case t.Held:
+			cmd = "resume"
+		case t.Phase == "meeting" && t.Remaining < 0:
+			cmd = "skip"
+		default:
+			continue
+		}
+		waiting = true
+		if _, err := send(request{Cmd: cmd, Name: t.Name}); err != nil {
+			failSend(err)
+		}
+	}
+	if waiting {
+		succeed(nil)
+		return
+	}
+
+	if !*yes && !confirmSkip(name) {
+		return
+	}
+	if _, err := send(request{Cmd: "skip", Name: name}); err != nil {
+		failSend(err)
+	}
+	succeed(nil)
+}
+
+// confirmSkip asks whether to skip the current interval: on the terminal
+// when there is one, or else in tmux, which runs `pomo next --yes` once
+// the user agrees and so returns false here.
+func confirmSkip(name string) bool {
+	switch {
+	case jsonOutput:
+		fail(codeUsage, "pomo next needs --yes to skip an interval with --output json")
+	case interactive():
+		fmt.Print("Skip to the next interval? [y/N] ")
+		line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		answer := strings.ToLower(strings.TrimSpace(line))
+		return answer == "y" || answer == "yes"
+	case os.Getenv("TMUX") != "":
+		self, err := os.Executable()
+		if err != nil {
+			self = os.Args[0]
+		}
+		cmd := shellQuote(self) + " next --yes"
+		if name != "" {
+			cmd += " -- " + shellQuote(name)
+		}
+		tmuxCommand("confirm-before", "-p", "Skip to the next interval? (y/n)", runShell(cmd)).Run()
+	default:
+		fail(codeUsage, "pomo next needs --yes to skip an interval without a terminal")
+	}
+	return false
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// spanEvent is something that happened during a session, exported as an
+// event on its span.
+type spanEvent struct {
+	name string
+	at   time.Time
+}
+
+// otelExporter turns each recorded session into an OTLP/HTTP span for an
+// OpenTelemetry collector.
+type otelExporter struct {
+	endpoint string // e.g. http://localhost:4318
+	service  string
+}
+
+// loadOtel reads the [otel] section of cfg. It returns nil when no
+// endpoint is configured.
+func loadOtel(cfg config) *otelExporter {
+	endpoint := cfg.get("otel.endpoint", "")
+	if endpoint == "" {
+		return nil
+	}
+	return &otelExporter{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		service:  cfg.get("otel.service", "pomo"),
+	}
+}
+
+// otlpValue and friends mirror the OTLP/JSON encoding.
+type otlpValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+}
+
+type otlpAttr struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string `json:"timeUnixNano"`
+	Name         string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string      `json:"traceId"`
+	SpanID            string      `json:"spanId"`
+	Name              string      `json:"name"`
+	Kind              int         `json:"kind"`
+	StartTimeUnixNano string      `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string      `json:"endTimeUnixNano"`
+	Attributes        []otlpAttr  `json:"attributes"`
+	Events            []otlpEvent `json:"events,omitempty"`
+}
+
+func strAttr(key, v string) otlpAttr {
+	return otlpAttr{Key: key, Value: otlpValue{StringValue: &v}}
+}
+
+func intAttr(key string, v int64) otlpAttr {
+	s := strconv.FormatInt(v, 10)
+	return otlpAttr{Key: key, Value: otlpValue{IntValue: &s}}
+}
+
+func boolAttr(key string, v bool) otlpAttr {
+	return otlpAttr{Key: key, Value: otlpValue{BoolValue: &v}}
+}
+
+func nanos(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// randomHex returns n random bytes hex encoded.
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	h := hex.EncodeToString(b)
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+// span encodes s and its events as an OTLP/JSON export request.
+func (e *otelExporter) span(s session, events []spanEvent) ([]byte, error) {
+	span := otlpSpan{
+		TraceID:           randomHex(16),
+		SpanID:            randomHex(8),
+		Name:              "pomodoro",
+		Kind:              1, // SPAN_KIND_INTERNAL
+		StartTimeUnixNano: nanos(s.Start),
+		EndTimeUnixNano:   nanos(s.End),
+		Attributes: []otlpAttr{
+			intAttr("pomo.planned_ms", s.Duration.Milliseconds()),
+			boolAttr("pomo.completed", s.Completed),
+			intAttr("pomo.pauses", int64(s.Pauses)),
+			intAttr("pomo.interruptions", int64(s.Interruptions)),
+		},
+	}
+	if s.Task != "" {
+		span.Attributes = append(span.Attributes, strAttr("pomo.task", s.Task))
+	}
+	if s.Project != "" {
+		span.Attributes = append(span.Attributes, strAttr("pomo.project", s.Project))
+	}
+	if len(s.Tags) > 0 {
+		span.Attributes = append(span.Attributes, strAttr("pomo.tags", strings.Join(s.Tags, ",")))
+	}
+	for _, ev := range events {
+		span.Events = append(span.Events, otlpEvent{TimeUnixNano: nanos(ev.at), Name: ev.name})
+	}
+
+	body := map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{"attributes": []otlpAttr{strAttr("service.name", e.service)}},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]any{"name": "pomo"},
+				"spans": []otlpSpan{span},
+			}},
+		}},
+	}
+	return json.Marshal(body)
+}
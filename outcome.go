@@ -0,0 +1,55 @@
+package main
+
+import (
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// stopRule decides how a work interval stopped early is recorded, from how
+// much of it had elapsed.
+type stopRule struct {
+	completeAfter float64 // fraction after which it counts as done; 0 never
+	discardBefore float64 // fraction before which it is not recorded at all
+}
+
+// loadStopRule reads stop.complete_after and stop.discard_before, given
+// as percentages such as "90%".
+func loadStopRule(cfg config) stopRule {
+	return stopRule{
+		completeAfter: parsePercent(cfg, "stop.complete_after"),
+		discardBefore: parsePercent(cfg, "stop.discard_before"),
+	}
+}
+
+// parsePercent reads key as a percentage, returning it as a fraction.
+func parsePercent(cfg config, key string) float64 {
+	v := cfg.get(key, "")
+	if v == "" {
+		return 0
+	}
+	n, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(v), "%"), 64)
+	if err != nil || n < 0 || n > 100 {
+		log.Printf("Ignoring %s %q", key, v)
+		return 0
+	}
+	return n / 100
+}
+
+// outcome returns how stopping t at now should be recorded: "complete",
+// "discard" or "abandon".
+func (r stopRule) outcome(t *timer, now time.Time) string {
+	p := t.phase()
+	if p.Kind != "work" || p.Duration == 0 {
+		return "abandon"
+	}
+	elapsed := 1 - float64(t.left(now))/float64(p.Duration)
+	switch {
+	case r.completeAfter > 0 && elapsed >= r.completeAfter:
+		return "complete"
+	case elapsed < r.discardBefore:
+		return "discard"
+	}
+	return "abandon"
+}
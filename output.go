@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// jsonOutput is set by the global --output json flag. Results and errors
+// are then written to stdout as a single JSON object, for editors and
+// other programs driving pomo.
+var jsonOutput bool
+
+// Error codes reported with --output json.
+const (
+	codeUsage       = "usage"           // bad arguments
+	codeNoTmux      = "no_tmux"         // the command needs to run inside tmux
+	codeNotRunning  = "not_running"     // the daemon is not running
+	codeExists      = "already_running" // a timer of that name is running
+	codeNotFound    = "not_found"       // no such timer
+	codeRejected    = "rejected"        // the daemon refused the request
+	codeFailed      = "failed"          // reading or writing data failed
+	codeUnsupported = "unsupported"     // the command has no JSON output
+)
+
+// result is what a command prints with --output json.
+type result struct {
+	OK    bool         `json:"ok"`
+	Error *resultError `json:"error,omitempty"`
+	Data  any          `json:"data,omitempty"`
+}
+
+type resultError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// parseOutput strips the global --output flag from args, which may appear
+// anywhere before a "--", and returns the remaining arguments. What
+// follows "--" belongs to another command, see runCommand.
+func parseOutput(args []string) []string {
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--":
+			rest = append(rest, args[i:]...)
+			i = len(args)
+		case args[i] == "--output" && i+1 < len(args):
+			jsonOutput = args[i+1] == "json"
+			i++
+		case strings.HasPrefix(args[i], "--output="):
+			jsonOutput = strings.TrimPrefix(args[i], "--output=") == "json"
+		default:
+			rest = append(rest, args[i])
+		}
+	}
+	return rest
+}
+
+func writeResult(r result) {
+	json.NewEncoder(os.Stdout).Encode(r)
+}
+
+// fail exits with status 1, first reporting code and message when
+// --output json is set. Otherwise it exits silently.
+func fail(code, message string) {
+	if jsonOutput {
+		writeResult(result{Error: &resultError{Code: code, Message: message}})
+	}
+	os.Exit(1)
+}
+
+// failf fails with code and the formatted message, which is logged to
+// stderr, as log.Fatalf would, without --output json.
+func failf(code, format string, args ...any) {
+	message := fmt.Sprintf(format, args...)
+	if !jsonOutput {
+		log.Print(message)
+	}
+	fail(code, message)
+}
+
+// usage fails with a usage error.
+func usage(message string) {
+	fail(codeUsage, "usage: "+message)
+}
+
+// invalid fails with a usage error explaining what was wrong with the
+// arguments, printed to stderr without --output json.
+func invalid(err error) {
+	if !jsonOutput {
+		fmt.Fprintf(os.Stderr, "pomo: %v\n", err)
+	}
+	fail(codeUsage, err.Error())
+}
+
+// failSend fails with the error send returned. Failing to start a daemon
+// is reported without --output json too.
+func failSend(err error) {
+	code, message := sendError(err)
+	if errors.Is(err, errNoStart) {
+		failf(code, "%s", message)
+	}
+	fail(code, message)
+}
+
+// sendError returns the error code and message describing err from send.
+func sendError(err error) (string, string) {
+	var de *daemonError
+	switch {
+	case errors.Is(err, errNoDaemon), errors.Is(err, errNoStart):
+		return codeNotRunning, err.Error()
+	case errors.As(err, &de):
+		return de.code, de.message
+	}
+	return codeRejected, err.Error()
+}
+
+// succeed reports a successful command with --output json, along with its
+// data if any.
+func succeed(data any) {
+	if jsonOutput {
+		writeResult(result{OK: true, Data: data})
+	}
+}
+
+// orEmpty returns s, or an empty slice instead of nil so that it is
+// encoded as [] rather than left out.
+func orEmpty[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
+
+// textOnly fails for commands that have no JSON form.
+func textOnly(cmd string) {
+	if jsonOutput {
+		fail(codeUnsupported, cmd+" has no JSON output")
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// outsideTmux decides what a timer started outside tmux does, from
+// --outside or else tmux.outside: "error", the default, fails; "wait"
+// waits for a tmux session to show the timer in; and "terminal" runs it
+// anyway, for the caller to show on the terminal, and returns true.
+func outsideTmux(mode string) bool {
+	if mode == "" {
+		mode = loadConfig().get("tmux.outside", "error")
+	}
+	switch mode {
+	case "terminal":
+		textOnly("--outside terminal")
+		return true
+	case "wait":
+		waitForTmux()
+		return false
+	case "error":
+	default:
+		invalid(fmt.Errorf("--outside must be error, terminal or wait, not %q", mode))
+	}
+	message := "pomo must be run inside tmux, or with --outside terminal or wait"
+	if !jsonOutput {
+		fmt.Fprintln(os.Stderr, message)
+	}
+	fail(codeNoTmux, message)
+	return false
+}
+
+// waitForTmux returns once the tmux server pomo talks to has a session.
+func waitForTmux() {
+	if tmuxCommand("has-session").Run() == nil {
+		return
+	}
+	if !jsonOutput {
+		fmt.Fprintln(os.Stderr, "Waiting for a tmux session...")
+	}
+	for tmuxCommand("has-session").Run() != nil {
+		time.Sleep(time.Second)
+	}
+}
+
+// watchTerminal shows the status of the timers on the terminal, a line
+// redrawn every second, until the named timer is done. Interrupting it
+// leaves the timer running.
+func watchTerminal(name string) {
+	if name == "" {
+		name = defaultTimer
+	}
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, syscall.SIGINT, syscall.SIGTERM)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	defer fmt.Print("\r\033[K")
+	for {
+		resp, err := send(request{Cmd: "statusline"})
+		if err != nil {
+			log.Printf("Failed to read the status: %v", err)
+			return
+		}
+		running := false
+		for _, t := range resp.Timers {
+			running = running || t.Name == name && t.Phase != "done"
+		}
+		if !running {
+			return
+		}
+		fmt.Print("\r\033[K" + resp.Status)
+		select {
+		case <-ticker.C:
+		case <-interrupted:
+			fmt.Printf("\r\033[K%s keeps running: see pomo list\n", name)
+			return
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// overlayCookie keeps a guest's overlay token once the link has been
+// opened, so that it need not stay in the URL.
+const overlayCookie = "pomo_overlay"
+
+// overlayColors are the overlay's default colours by timer state, as in
+// themes.
+var overlayColors = map[string]string{
+	"work":       "#e74c3c",
+	"flow":       "#9b59b6",
+	"break":      "#2ecc71",
+	"long_break": "#1abc9c",
+	"meeting":    "#3498db",
+	"delay":      "#bdc3c7",
+	"overtime":   "#e67e22",
+	"paused":     "#95a5a6",
+	"done":       "#f1c40f",
+}
+
+// overlayState is what the overlay page is sent each second.
+type overlayState struct {
+	State     string        `json:"state"` // as in themes, or "idle"
+	Name      string        `json:"name,omitempty"`
+	Task      string        `json:"task,omitempty"`
+	Remaining time.Duration `json:"remaining"`
+	Ends      int64         `json:"ends,omitempty"` // Unix milliseconds, while counting down
+	Color     string        `json:"color,omitempty"`
+}
+
+// overlayFor returns the state of the named timer, or of the first one on
+// show, or idle.
+func overlayFor(timers []timerInfo, name string, colors map[string]string) overlayState {
+	var t *timerInfo
+	for i := range timers {
+		if name != "" && timers[i].Name == name || name == "" && timers[i].Target != "-" {
+			t = &timers[i]
+			break
+		}
+	}
+	if t == nil && name == "" && len(timers) > 0 {
+		t = &timers[0]
+	}
+	if t == nil {
+		return overlayState{State: "idle"}
+	}
+	state := strings.ReplaceAll(t.Phase, " ", "_")
+	switch {
+	case t.Paused:
+		state = "paused"
+	case t.Phase == "meeting" && t.Remaining < 0:
+		state = "overtime"
+	}
+	s := overlayState{State: state, Name: t.Name, Task: t.Task, Remaining: t.Remaining, Color: colors[state]}
+	if !t.Ends.IsZero() {
+		s.Ends = t.Ends.UnixMilli()
+	}
+	return s
+}
+
+// overlayCommand implements `pomo overlay`, which serves a page showing a
+// timer for streaming software such as OBS to add as a browser source. The
+// page has a transparent background and follows the timer over a stream
+// of server-sent events. It cannot control the timer, so with
+// overlay.token set its link can be shared with guests who may watch: the
+// token goes in the URL, /?token=..., and is then kept in a cookie. With
+// overlay.cert and overlay.key it is served over HTTPS so that the token
+// can't be read on the way.
+func overlayCommand(args []string) {
+	cfg := loadConfig()
+	fs := flag.NewFlagSet("overlay", flag.ExitOnError)
+	listen := fs.String("listen", cfg.get("overlay.listen", "127.0.0.1:7780"), "address to serve the overlay on")
+	args = parseFlags(fs, args)
+	name := ""
+	if len(args) > 0 {
+		name = args[0]
+	}
+	token, err := resolveSecret(cfg.get("overlay.token", ""))
+	if err != nil {
+		failf(codeFailed, "Failed to read overlay.token: %v", err)
+	}
+	cert, key := expandHome(cfg.get("overlay.cert", "")), expandHome(cfg.get("overlay.key", ""))
+	secure := cert != "" && key != ""
+	if token != "" && !secure && !loopback(*listen) {
+		log.Printf("The overlay token is sent in the clear; set overlay.cert and overlay.key to serve it over HTTPS")
+	}
+	colors := map[string]string{}
+	for state, color := range overlayColors {
+		colors[state] = cfg.get("overlay."+state, color)
+	}
+
+	mux := http.NewServeMux()
+	guest := func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if token != "" {
+				got := r.URL.Query().Get("token")
+				if c, err := r.Cookie(overlayCookie); err == nil && got == "" {
+					got = c.Value
+				}
+				if !tokenMatches(got, token) {
+					http.Error(w, "unauthorized", http.StatusUnauthorized)
+					return
+				}
+				http.SetCookie(w, &http.Cookie{Name: overlayCookie, Value: token, Path: "/", HttpOnly: true, Secure: secure, SameSite: http.SameSiteStrictMode})
+			}
+			w.Header().Set("Referrer-Policy", "no-referrer")
+			next(w, r)
+		}
+	}
+	mux.HandleFunc("GET /{$}", guest(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		overlayPage.Execute(w, cfg.get("overlay.font", "sans-serif"))
+	}))
+	mux.HandleFunc("GET /events", guest(func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			resp, _ := send(request{Cmd: "list"})
+			data, _ := json.Marshal(overlayFor(resp.Timers, name, colors))
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+			select {
+			case <-r.Context().Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}))
+	link := "http://" + *listen + "/"
+	if secure {
+		link = "https://" + *listen + "/"
+	}
+	if token != "" {
+		link += "?token=" + url.QueryEscape(token)
+	}
+	ln, err := net.Listen("tcp", *listen)
+	if err != nil {
+		failf(codeFailed, "Failed to serve the overlay: %v", err)
+	}
+	if jsonOutput {
+		succeed(map[string]any{"url": link})
+	} else {
+		fmt.Printf("Serving the overlay on %s\n", link)
+	}
+	if secure {
+		log.Fatal(http.ServeTLS(ln, mux, cert, key))
+	}
+	log.Fatal(http.Serve(ln, mux))
+}
+
+// overlayPage is the overlay, given the font to use. It counts down
+// between events so that the clock stays smooth.
+var overlayPage = template.Must(template.New("overlay").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>pomo</title>
+<style>
+  html, body { margin: 0; background: transparent; overflow: hidden; }
+  body { font-family: {{.}}; color: #fff; text-align: center; text-shadow: 0 0 12px rgba(0, 0, 0, .6); }
+  #clock { font-size: 22vw; font-weight: bold; line-height: 1; font-variant-numeric: tabular-nums; }
+  #label { font-size: 6vw; text-transform: uppercase; letter-spacing: .1em; }
+  #task { font-size: 4vw; opacity: .85; }
+</style>
+</head>
+<body>
+<div id="label"></div>
+<div id="clock"></div>
+<div id="task"></div>
+<script>
+  let state = { state: "idle" };
+  const pad = (n) => String(n).padStart(2, "0");
+  function clock(ms) {
+    const sign = ms < 0 ? "+" : "";
+    const s = Math.floor(Math.abs(ms) / 1000);
+    return sign + pad(Math.floor(s / 60)) + ":" + pad(s % 60);
+  }
+  function draw() {
+    const idle = state.state === "idle";
+    document.body.style.visibility = idle ? "hidden" : "visible";
+    if (idle) return;
+    const ms = state.ends ? Math.max(state.ends - Date.now(), 0) : state.remaining / 1e6;
+    document.getElementById("clock").textContent = state.state === "done" ? "" : clock(ms);
+    document.getElementById("clock").style.color = state.color || "";
+    document.getElementById("label").textContent = state.state.replace("_", " ");
+    document.getElementById("task").textContent = state.task || "";
+  }
+  new EventSource("events" + location.search).onmessage = (e) => { state = JSON.parse(e.data); draw(); };
+  history.replaceState(null, "", location.pathname);
+  setInterval(draw, 250);
+</script>
+</body>
+</html>
+`))
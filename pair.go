@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"time"
+)
+
+// pairing rotates who drives in a pair-programming session. Turns only
+// count down while work is being timed.
+type pairing struct {
+	Driver    string        `json:"driver"`
+	Navigator string        `json:"navigator"`
+	Rotate    time.Duration `json:"rotate"`
+	Left      time.Duration `json:"left"` // of the current turn
+}
+
+// advance counts elapsed against the current turn and swaps roles when
+// it runs out. It reports whether they swapped.
+func (p *pairing) advance(elapsed time.Duration) bool {
+	p.Left -= elapsed
+	if p.Left > 0 {
+		return false
+	}
+	p.Driver, p.Navigator = p.Navigator, p.Driver
+	p.Left = p.Rotate
+	return true
+}
+
+// label renders the driver for the status, in words when plain.
+func (p *pairing) label(plain bool) string {
+	if plain {
+		return p.Driver + " driving"
+	}
+	return "⌨ " + p.Driver
+}
+
+// pairCommand implements `pomo pair [duration] --driver a --navigator b
+// --rotate 10m`. Without a duration the pair works through the pomodoro
+// cycle.
+func pairCommand(args []string) {
+	fs := flag.NewFlagSet("pair", flag.ExitOnError)
+	req := timerFlags(fs)
+	driver := fs.String("driver", "", "who drives first")
+	navigator := fs.String("navigator", "", "who navigates first")
+	rotate := fs.Duration("rotate", 10*time.Minute, "how long each turn at the keyboard lasts")
+	args = parseFlags(fs, args)
+	if *driver == "" || *navigator == "" || *rotate <= 0 {
+		usage("pomo pair [duration] --driver <name> --navigator <name> [--rotate 10m]")
+	}
+	req.Pair = &pairing{Driver: *driver, Navigator: *navigator, Rotate: *rotate, Left: *rotate}
+
+	phases, err := preset("pomodoro")
+	if err != nil {
+		failf(codeFailed, "Failed to load preset: %v", err)
+	}
+	if len(args) >= 1 {
+		phases = []phase{{Kind: "work", Duration: mustDuration(loadConfig(), "work", args[0])}}
+	}
+	startTimer(req, phases)
+}
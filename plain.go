@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// accessible reports whether the config asks for plain output for screen
+// readers: words instead of emoji, symbols and bar charts.
+func accessible(cfg config) bool {
+	return cfg.get("accessible", "false") == "true"
+}
+
+// spokenDuration renders d in words, e.g. "1 hour 5 minutes". Seconds are
+// left out so the text does not change every second.
+func spokenDuration(d time.Duration) string {
+	if d < time.Minute {
+		return "less than a minute"
+	}
+	h, m := int(d.Hours()), int(d.Minutes())%60
+	var parts []string
+	if h > 0 {
+		parts = append(parts, plural(h, "hour"))
+	}
+	if m > 0 {
+		parts = append(parts, plural(m, "minute"))
+	}
+	return strings.Join(parts, " ")
+}
+
+// plural renders n of unit, e.g. "1 minute" or "25 minutes".
+func plural(n int, unit string) string {
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
+
+// describe renders a timer in state for plain output, e.g. "writing: work
+// phase, 25 minutes remaining".
+func describe(state, name string, clock time.Duration) string {
+	prefix := ""
+	if name = strings.TrimSpace(name); name != "" {
+		prefix = name + ": "
+	}
+	switch state {
+	case "done":
+		return prefix + "finished after " + spokenDuration(clock)
+	case "flow":
+		return prefix + "flow, " + spokenDuration(-clock) + " worked"
+	case "overtime":
+		return prefix + "meeting, " + spokenDuration(-clock) + " over time"
+	case "delay":
+		return prefix + "starts in " + spokenDuration((clock + time.Minute - time.Second).Truncate(time.Minute))
+	case "work":
+		state = "work phase"
+	}
+	// Count started minutes, so that 24:30 left is "25 minutes".
+	left := (clock + time.Minute - time.Second).Truncate(time.Minute)
+	return prefix + strings.ReplaceAll(state, "_", " ") + ", " + spokenDuration(left) + " remaining"
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// planItem is a task planned for today with its pomodoro estimate.
+type planItem struct {
+	Task     string `json:"task"`
+	Estimate int    `json:"estimate"`
+}
+
+// plan is the list of tasks declared for a single day.
+type plan struct {
+	Date  string     `json:"date"`
+	Items []planItem `json:"items"`
+}
+
+func planPath() string {
+	return filepath.Join(dataDir(), "plan.json")
+}
+
+// today returns the current local date as YYYY-MM-DD.
+func today() string {
+	return time.Now().Format("2006-01-02")
+}
+
+// loadPlan reads today's plan. A missing file or a plan from another day
+// yields an empty plan.
+func loadPlan() plan {
+	p := plan{Date: today()}
+	data, err := os.ReadFile(planPath())
+	if err != nil {
+		return p
+	}
+	var stored plan
+	if json.Unmarshal(data, &stored) != nil || stored.Date != p.Date {
+		return p
+	}
+	for i := range stored.Items {
+		stored.Items[i].Task = unseal(stored.Items[i].Task)
+	}
+	return stored
+}
+
+// savePlan writes p to the plan file.
+func savePlan(p plan) error {
+	items := make([]planItem, len(p.Items))
+	for i, item := range p.Items {
+		task, err := seal(item.Task)
+		if err != nil {
+			return err
+		}
+		items[i] = planItem{Task: task, Estimate: item.Estimate}
+	}
+	p.Items = items
+	if err := os.MkdirAll(dataDir(), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(planPath(), data, 0644)
+}
+
+// completedByTask counts the completed pomodoros per task.
+func completedByTask(sessions []session) map[string]int {
+	done := map[string]int{}
+	for _, s := range sessions {
+		if s.Completed {
+			done[s.Task]++
+		}
+	}
+	return done
+}
+
+// nextPlanned returns the first planned task that has not yet used up its
+// estimate today, or "" when the plan is finished or empty.
+func nextPlanned() string {
+	p := loadPlan()
+	if len(p.Items) == 0 {
+		return ""
+	}
+	sessions, _ := loadSessions()
+	done := completedByTask(sessionsSince(sessions, startOfDay(time.Now())))
+	for _, item := range p.Items {
+		if done[item.Task] < item.Estimate {
+			return item.Task
+		}
+	}
+	return ""
+}
+
+// planCommand implements `pomo plan`:
+//
+//	pomo plan                 list today's plan
+//	pomo plan <task> [count]  add a task with an estimate (default 1)
+//	pomo plan clear           drop today's plan
+func planCommand(args []string) {
+	p := loadPlan()
+	switch {
+	case len(args) == 0:
+		sessions, _ := loadSessions()
+		done := completedByTask(sessionsSince(sessions, startOfDay(time.Now())))
+		if jsonOutput {
+			type progress struct {
+				planItem
+				Done int `json:"done"`
+			}
+			items := []progress{}
+			for _, item := range p.Items {
+				items = append(items, progress{item, done[item.Task]})
+			}
+			succeed(items)
+			return
+		}
+		for _, item := range p.Items {
+			fmt.Printf("%-30s %d/%d\n", item.Task, done[item.Task], item.Estimate)
+		}
+		return
+	case len(args) == 1 && args[0] == "clear":
+		p.Items = nil
+	default:
+		estimate := 1
+		if len(args) >= 2 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				usage("pomo plan <task> [estimate]")
+			}
+			estimate = n
+		}
+		p.Items = append(p.Items, planItem{Task: args[0], Estimate: estimate})
+	}
+	if err := savePlan(p); err != nil {
+		failf(codeFailed, "Failed to save plan: %v", err)
+	}
+	succeed(nil)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// snippets are the tmux config `pomo tpm-snippet` prints for each status
+// framework, given where pomo's segment goes among the others.
+var snippets = map[string]func(first bool) string{
+	"tmux":       plainSnippet,
+	"catppuccin": catppuccinSnippet,
+	"powerline":  powerlineSnippet,
+}
+
+// plainSnippet appends pomo's segment to status-right as left by other
+// plugins, or puts it in front of them.
+func plainSnippet(first bool) string {
+	if first {
+		return `# After tpm has run, so that pomo comes before every other plugin:
+set -gF status-right "##{?` + statusOption + `,##{` + statusOption + `} ,}#{status-right}"
+`
+	}
+	return `# After tpm has run, so that pomo comes after every other plugin:
+set -ag status-right "#{?` + statusOption + `, #{` + statusOption + `},}"
+`
+}
+
+// catppuccinSnippet defines a catppuccin module showing pomo's segment.
+func catppuccinSnippet(first bool) string {
+	add := `set -agF status-right "#{E:@catppuccin_status_pomo}"`
+	if first {
+		add = `set -gF status-right "#{E:@catppuccin_status_pomo}#{status-right}"`
+	}
+	return `# After catppuccin has been loaded:
+%hidden MODULE_NAME="pomo"
+set -ogq "@catppuccin_${MODULE_NAME}_icon" "🍅 "
+set -ogq "@catppuccin_${MODULE_NAME}_color" "#{E:@thm_red}"
+set -ogq "@catppuccin_${MODULE_NAME}_text" " #{` + statusOption + `}"
+source -F "#{d:current_file}/plugins/catppuccin/tmux/utils/status_module.conf"
+` + add + "\n"
+}
+
+// powerlineSnippet is a tmux-powerline segment showing pomo's segment, to
+// list in the theme's TMUX_POWERLINE_RIGHT_STATUS_SEGMENTS.
+func powerlineSnippet(first bool) string {
+	where := "last"
+	if first {
+		where = "first"
+	}
+	return `# Save as ~/.config/tmux-powerline/segments/pomo.sh and list "pomo" ` + where + `
+# in TMUX_POWERLINE_RIGHT_STATUS_SEGMENTS.
+run_segment() {
+	tmux show-option -gqv ` + statusOption + `
+}
+`
+}
+
+// tpmSnippetCommand implements `pomo tpm-snippet [framework]`, which
+// prints the config a status bar framework needs to show pomo among its
+// own modules, along with the pomo setting that leaves status-right to it.
+func tpmSnippetCommand(args []string) {
+	fs := flag.NewFlagSet("tpm-snippet", flag.ExitOnError)
+	first := fs.Bool("first", false, "put pomo before the other modules rather than after them")
+	args = parseFlags(fs, args)
+	framework := "tmux"
+	if len(args) > 0 {
+		framework = args[0]
+	}
+	snippet, ok := snippets[framework]
+	if !ok {
+		names := make([]string, 0, len(snippets))
+		for name := range snippets {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		usage("pomo tpm-snippet [" + strings.Join(names, "|") + "] [--first]")
+	}
+	if jsonOutput {
+		succeed(map[string]any{"framework": framework, "snippet": snippet(*first), "config": map[string]string{"status.display": "option"}})
+		return
+	}
+	fmt.Printf("# In ~/.config/pomo/config.toml, so that pomo leaves status-right alone:\n")
+	fmt.Printf("#   [status]\n#   display = \"option\"\n\n")
+	fmt.Print(snippet(*first))
+}
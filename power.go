@@ -0,0 +1,112 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// powerCheckEvery is how often low_power = "auto" looks at the power
+// supply again.
+const powerCheckEvery = time.Minute
+
+// powerSaver decides when the daemon runs in low power mode: updating
+// the status once a minute, with the clock in minutes, rather than every
+// second, without tick sounds, and ringing the terminal bell rather than
+// playing sound files.
+type powerSaver struct {
+	mode    string // low_power: "auto", "on" or "off"
+	on      bool
+	checked time.Time
+}
+
+// loadPowerSaver reads low_power: "auto", the default, saves power while
+// on battery, and "on" and "off" always and never do.
+func loadPowerSaver(cfg config) *powerSaver {
+	mode := cfg.get("low_power", "auto")
+	switch mode {
+	case "true":
+		mode = "on"
+	case "false":
+		mode = "off"
+	case "auto", "on", "off":
+	default:
+		log.Printf("Ignoring low_power %q: use auto, on or off", mode)
+		mode = "auto"
+	}
+	return &powerSaver{mode: mode, on: mode == "on"}
+}
+
+// low reports whether to save power at now.
+func (p *powerSaver) low(now time.Time) bool {
+	if p.mode == "auto" && now.Sub(p.checked) >= powerCheckEvery {
+		p.on, p.checked = onBattery(), now
+	}
+	return p.on
+}
+
+// onBattery reports whether the machine runs on battery: on Linux a
+// battery is discharging and no mains supply is online, and on macOS
+// pmset says so. It reports false when it cannot tell.
+func onBattery() bool {
+	if runtime.GOOS == "darwin" {
+		out, err := exec.Command("pmset", "-g", "batt").Output()
+		return err == nil && strings.Contains(string(out), "'Battery Power'")
+	}
+	supplies, _ := filepath.Glob("/sys/class/power_supply/*")
+	discharging := false
+	for _, dir := range supplies {
+		read := func(name string) string {
+			data, _ := os.ReadFile(filepath.Join(dir, name))
+			return strings.TrimSpace(string(data))
+		}
+		switch read("type") {
+		case "Mains", "USB":
+			if read("online") == "1" {
+				return false
+			}
+		case "Battery":
+			discharging = discharging || read("status") == "Discharging"
+		}
+	}
+	return discharging
+}
+
+// nextTick returns how long the timer loop may sleep after now: a second,
+// or in low power mode until the clock of a running timer shows another
+// minute, at most a minute later. Alerts still repeat every second.
+func (d *daemon) nextTick(now time.Time) time.Duration {
+	if !d.power.low(now) || d.alerts.active() {
+		return time.Second
+	}
+	wait := time.Minute
+	for _, until := range []time.Time{d.cleanup.until, d.micro.until} {
+		if until.After(now) {
+			wait = min(wait, until.Sub(now))
+		}
+	}
+	for _, t := range d.timers {
+		if !t.finished.IsZero() {
+			continue
+		}
+		if t.paused {
+			if !t.resumeAt.IsZero() {
+				wait = min(wait, max(t.resumeAt.Sub(now), 0))
+			}
+			continue
+		}
+		// Clocks count minutes to, or for flowtime and overtime from,
+		// the end time.
+		left := t.endTime.Sub(now) % time.Minute
+		if left <= 0 {
+			left += time.Minute
+		}
+		wait = min(wait, left)
+	}
+	// Land just past the minute rather than just before it.
+	return wait + 10*time.Millisecond
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// profile is a session setup from [profiles.<name>], chosen with
+// `pomo start --profile <name>`: defaults for the timer, and shell
+// commands that prepare the environment as it starts and tidy up once it
+// stops or runs out.
+type profile struct {
+	Name  string `json:"name"`
+	Dir   string `json:"dir"`             // where the timer was started, and the commands run
+	Start string `json:"start,omitempty"` // on_start, one command per line
+	End   string `json:"end,omitempty"`   // on_end, one command per line
+
+	duration string
+	project  string
+	tags     []string
+	theme    string
+}
+
+// loadProfile reads profiles.<name>.duration, .project, .tags, .theme,
+// .on_start and .on_end. on_start and on_end are a command or a list of
+// commands, run in turn.
+func loadProfile(cfg config, name string) (*profile, error) {
+	if !hasProfile(cfg, name) {
+		return nil, fmt.Errorf("unknown profile %q", name)
+	}
+	prefix := "profiles." + name + "."
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	return &profile{
+		Name:     name,
+		Dir:      dir,
+		Start:    strings.Join(cfg.list(prefix+"on_start"), "\n"),
+		End:      strings.Join(cfg.list(prefix+"on_end"), "\n"),
+		duration: cfg.get(prefix+"duration", ""),
+		project:  cfg.get(prefix+"project", ""),
+		tags:     cfg.list(prefix + "tags"),
+		theme:    cfg.get(prefix+"theme", ""),
+	}, nil
+}
+
+// hasProfile reports whether the config has a [profiles.<name>] section.
+func hasProfile(cfg config, name string) bool {
+	for key := range cfg {
+		if strings.HasPrefix(key, "profiles."+name+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// dayDefault returns what [days] sets for now's date, a profile name or a
+// duration: that of the narrowest date range holding it, e.g.
+// "2026-12-21..2027-01-01" or a single "2026-12-24", or else that of its
+// weekday, e.g. "monday". It returns "" when none is set.
+func dayDefault(cfg config, now time.Time) string {
+	today := now.Format("2006-01-02")
+	value, width := "", time.Duration(-1)
+	for key, v := range cfg {
+		days, ok := strings.CutPrefix(key, "days.")
+		if !ok {
+			continue
+		}
+		from, to, isRange := strings.Cut(days, "..")
+		if !isRange {
+			to = from
+		}
+		first, err1 := time.Parse("2006-01-02", from)
+		last, err2 := time.Parse("2006-01-02", to)
+		if err1 != nil || err2 != nil || today < from || today > to {
+			continue
+		}
+		if w := last.Sub(first); width < 0 || w < width {
+			value, width = v, w
+		}
+	}
+	if value != "" {
+		return value
+	}
+	return cfg.get("days."+strings.ToLower(now.Weekday().String()), "")
+}
+
+// defaultStart returns the duration a timer started without one runs
+// for, first giving req the profile [days] names for today unless it has
+// one already.
+func defaultStart(cfg config, req *request, now time.Time) (string, error) {
+	if name := dayDefault(cfg, now); req.Profile == nil && hasProfile(cfg, name) {
+		p, err := loadProfile(cfg, name)
+		if err != nil {
+			return "", fmt.Errorf("profile %s: %w", name, err)
+		}
+		req.Profile = p
+	}
+	if req.Profile != nil && req.Profile.duration != "" {
+		return req.Profile.duration, nil
+	}
+	return defaultDuration(cfg, now), nil
+}
+
+// mustDefaultStart returns defaultStart's duration, failing when today's
+// profile cannot be loaded.
+func mustDefaultStart(cfg config, req *request, now time.Time) string {
+	d, err := defaultStart(cfg, req, now)
+	if err != nil {
+		failf(codeFailed, "Failed to load profile: %v", err)
+	}
+	return d
+}
+
+// apply fills in what req leaves out from the profile.
+func (p *profile) apply(req *request) {
+	if req.Project == "" {
+		req.Project = p.project
+	}
+	if len(req.Tags) == 0 {
+		req.Tags = p.tags
+	}
+	if req.Theme == "" {
+		req.Theme = p.theme
+	}
+}
+
+// runProfile runs t's profile's on_start or on_end commands in the
+// background, from the directory the timer was started in.
+func runProfile(t *timer, hook string) {
+	script := t.profile.Start
+	if hook == "on_end" {
+		script = t.profile.End
+	}
+	if script == "" {
+		return
+	}
+	args := []string{"sh", "-c", "cd \"$POMO_DIR\" || exit\n" + script}
+	runHook(hook, loadHookPolicy(loadConfig(), hook), commandHook(args,
+		"POMO_DIR="+t.profile.Dir, "POMO_PROFILE="+t.profile.Name, "POMO_TIMER="+t.name), nil)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// inferProject works out the project of a session being started, trying
+// each source in project.infer ("git,tmux" by default) in turn: the name
+// of the git repository of the working directory, then the name of the
+// tmux session.
+func inferProject(cfg config) string {
+	for _, source := range strings.Split(cfg.get("project.infer", "git,tmux"), ",") {
+		var out []byte
+		var err error
+		switch strings.TrimSpace(source) {
+		case "git":
+			out, err = exec.Command("git", "rev-parse", "--show-toplevel").Output()
+			if err == nil {
+				out = []byte(filepath.Base(strings.TrimSpace(string(out))))
+			}
+		case "tmux":
+			out, err = tmuxCommand("display-message", "-p", "#S").Output()
+		default:
+			continue
+		}
+		if project := strings.TrimSpace(string(out)); err == nil && project != "" {
+			return project
+		}
+	}
+	return ""
+}
+
+// inferTask works out a task for a session being started from the pane
+// it is started in, trying each source in task.infer in turn: the pane's
+// title, unless it is left at the host name, the command running in it,
+// unless that is a shell or pomo itself, and the name of its directory.
+// It returns "" when task.infer is unset.
+func inferTask(cfg config) string {
+	for _, source := range strings.Split(cfg.get("task.infer", ""), ",") {
+		source = strings.TrimSpace(source)
+		format := ""
+		switch source {
+		case "title":
+			format = "#{?#{==:#{pane_title},#{host}},,#{pane_title}}"
+		case "command":
+			format = "#{pane_current_command}"
+		case "directory":
+			format = "#{b:pane_current_path}"
+		default:
+			continue
+		}
+		args := []string{"display-message", "-p"}
+		if pane := os.Getenv("TMUX_PANE"); pane != "" {
+			args = append(args, "-t", pane)
+		}
+		out, err := tmuxCommand(append(args, format)...).Output()
+		task := strings.TrimSpace(string(out))
+		if source == "command" && (task == filepath.Base(os.Getenv("SHELL")) || task == filepath.Base(os.Args[0])) {
+			continue
+		}
+		if err == nil && task != "" {
+			return task
+		}
+	}
+	return ""
+}
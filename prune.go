@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// rollup summarises the sessions of a single day once the raw sessions
+// have been pruned from the history.
+type rollup struct {
+	Date      string        `json:"date"`
+	Sessions  int           `json:"sessions"`
+	Completed int           `json:"completed"`
+	Focus     time.Duration `json:"focus"`
+}
+
+func rollupsPath() string {
+	return filepath.Join(dataDir(), "rollups.jsonl")
+}
+
+// parseRetention parses a retention period such as "90d", "6w", "18m"
+// (months) or "2y" and returns the cutoff it implies relative to now.
+func parseRetention(s string, now time.Time) (time.Time, error) {
+	if len(s) < 2 {
+		return time.Time{}, fmt.Errorf("invalid retention %q", s)
+	}
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil || n < 0 {
+		return time.Time{}, fmt.Errorf("invalid retention %q", s)
+	}
+	switch s[len(s)-1] {
+	case 'd':
+		return now.AddDate(0, 0, -n), nil
+	case 'w':
+		return now.AddDate(0, 0, -7*n), nil
+	case 'm':
+		return now.AddDate(0, -n, 0), nil
+	case 'y':
+		return now.AddDate(-n, 0, 0), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid retention %q", s)
+}
+
+// loadRollups reads the daily rollups keyed by date.
+func loadRollups() (map[string]rollup, error) {
+	rollups := map[string]rollup{}
+	f, err := os.Open(rollupsPath())
+	if os.IsNotExist(err) {
+		return rollups, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	for dec.More() {
+		var r rollup
+		if err := dec.Decode(&r); err != nil {
+			return nil, err
+		}
+		rollups[r.Date] = r
+	}
+	return rollups, nil
+}
+
+// writeJSONLines atomically replaces path with one JSON document per value.
+func writeJSONLines[T any](path string, values []T) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".pomo-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	enc := json.NewEncoder(tmp)
+	for _, v := range values {
+		if err := enc.Encode(v); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// prune folds every session that started before cutoff into the daily
+// rollups and removes it from the history. Lines that are not sessions
+// this pomo can read are kept as they are. It returns the number of
+// sessions removed.
+func prune(cutoff time.Time) (int, error) {
+	lock, err := lockHistory()
+	if err != nil {
+		return 0, err
+	}
+	defer lock.Close()
+
+	// Sessions are kept as stored, so encrypted fields stay encrypted.
+	data, err := os.ReadFile(historyPath())
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	rollups, err := loadRollups()
+	if err != nil {
+		return 0, err
+	}
+
+	var keep bytes.Buffer
+	removed := 0
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		var s session
+		if json.Unmarshal(line, &s) != nil || !s.Start.Before(cutoff) {
+			keep.Write(line)
+			keep.WriteByte('\n')
+			continue
+		}
+		date := s.Start.Local().Format("2006-01-02")
+		r := rollups[date]
+		r.Date = date
+		r.Sessions++
+		if s.Completed {
+			r.Completed++
+		}
+		r.Focus += s.focused()
+		rollups[date] = r
+		removed++
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+
+	// Write the rollups first so that a failure in between can only leave
+	// sessions counted twice, never lost.
+	if err := writeJSONLines(rollupsPath(), sortedRollups(rollups)); err != nil {
+		return 0, err
+	}
+	if err := replaceFile(historyPath(), keep.Bytes()); err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
+
+// sortedRollups returns rollups oldest first.
+func sortedRollups(rollups map[string]rollup) []rollup {
+	days := make([]rollup, 0, len(rollups))
+	for _, r := range rollups {
+		days = append(days, r)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Date < days[j].Date })
+	return days
+}
+
+// rolledUpStart is when the stand-ins for a pruned day begin, as the time
+// of day of the sessions is not kept.
+const rolledUpStart = 9 * time.Hour
+
+// standIns returns sessions standing in for those r summarises: as many,
+// as many of them completed, and sharing its focus time, back to back from
+// rolledUpStart on its day.
+func (r rollup) standIns() []session {
+	day, err := time.ParseInLocation("2006-01-02", r.Date, time.Local)
+	if err != nil || r.Sessions <= 0 {
+		return nil
+	}
+	each := r.Focus / time.Duration(r.Sessions)
+	start := day.Add(rolledUpStart)
+	out := make([]session, r.Sessions)
+	for i := range out {
+		out[i] = session{Start: start, End: start.Add(each), Duration: each, Completed: i < r.Completed, pruned: true}
+		start = start.Add(each)
+	}
+	return out
+}
+
+// loadSummary returns the history for reports and statistics: stand-ins
+// for the sessions pruned into daily rollups, followed by every session
+// still in the history, as loadSessions gives them.
+func loadSummary() ([]session, error) {
+	rollups, err := loadRollups()
+	if err != nil {
+		return nil, err
+	}
+	var all []session
+	for _, r := range sortedRollups(rollups) {
+		all = append(all, r.standIns()...)
+	}
+	sessions, err := loadSessions()
+	return append(all, sessions...), err
+}
+
+// keepHistory prunes the history to the retention period keep, as
+// history.keep says, now and then once a day.
+func keepHistory(keep string) {
+	for {
+		cutoff, err := parseRetention(keep, startOfDay(time.Now()))
+		if err != nil {
+			log.Printf("Failed to parse history.keep: %v", err)
+			return
+		}
+		if n, err := prune(cutoff); err != nil {
+			log.Printf("Failed to prune history: %v", err)
+		} else if n > 0 {
+			log.Printf("Pruned %d sessions older than %s", n, cutoff.Format("2006-01-02"))
+		}
+		time.Sleep(24 * time.Hour)
+	}
+}
+
+// pruneCommand implements `pomo prune [period]`. The period defaults to
+// history.keep from the config; without either nothing is pruned.
+func pruneCommand(args []string) {
+	keep := loadConfig().get("history.keep", "")
+	if len(args) > 0 {
+		keep = args[0]
+	}
+	if keep == "" {
+		usage("pomo prune <period>, or set history.keep")
+	}
+	cutoff, err := parseRetention(keep, startOfDay(time.Now()))
+	if err != nil {
+		failf(codeUsage, "Failed to parse retention: %v", err)
+	}
+	n, err := prune(cutoff)
+	if err != nil {
+		failf(codeFailed, "Failed to prune history: %v", err)
+	}
+	if jsonOutput {
+		succeed(map[string]any{"pruned": n, "before": cutoff.Format(time.DateOnly)})
+		return
+	}
+	fmt.Printf("Pruned %d sessions older than %s\n", n, cutoff.Format("2006-01-02"))
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testData points the data and config directories at a temporary
+// directory and writes lines to the history there.
+func testData(t *testing.T, lines ...string) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", dir+"/data")
+	t.Setenv("XDG_CONFIG_HOME", dir+"/config")
+	if err := os.MkdirAll(dataDir(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(historyPath(), []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// storedLine returns s as a line of the history.
+func storedLine(t *testing.T, s session) string {
+	t.Helper()
+	data, err := json.Marshal(s.inUTC())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestPruneKeepsLinesItCannotRead(t *testing.T) {
+	old := time.Now().AddDate(0, 0, -30)
+	recent := time.Now().Add(-time.Hour)
+	kept := storedLine(t, session{Start: recent, End: recent.Add(25 * time.Minute), Completed: true})
+	// As a later schema might store times.
+	newer := `{"start":1577836800,"end":1577838300,"completed":true}`
+	testData(t,
+		storedLine(t, session{Start: old, End: old.Add(25 * time.Minute), Completed: true}),
+		"not json",
+		newer,
+		kept,
+	)
+
+	n, err := prune(startOfDay(time.Now()).AddDate(0, 0, -7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("pruned %d sessions, want 1", n)
+	}
+	data, err := os.ReadFile(historyPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "not json\n" + newer + "\n" + kept + "\n"; string(data) != want {
+		t.Errorf("history after pruning:\n%s\nwant:\n%s", data, want)
+	}
+}
+
+func TestSummaryCountsPrunedSessions(t *testing.T) {
+	day := startOfDay(time.Now()).AddDate(0, 0, -30).Add(14 * time.Hour)
+	var lines []string
+	for i, completed := range []bool{true, true, false} {
+		start := day.Add(time.Duration(i) * time.Hour)
+		lines = append(lines, storedLine(t, session{Start: start, End: start.Add(25 * time.Minute), Paused: 5 * time.Minute, Completed: completed}))
+	}
+	testData(t, lines...)
+	before, err := loadSummary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := prune(startOfDay(time.Now())); err != nil {
+		t.Fatal(err)
+	}
+	after, err := loadSummary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := func(sessions []session) (n, completed int, focus time.Duration) {
+		for _, s := range sessionsSince(sessions, startOfDay(day)) {
+			n++
+			if s.Completed {
+				completed++
+			}
+			focus += s.focused()
+		}
+		return n, completed, focus
+	}
+	n, completed, focus := count(after)
+	wantN, wantCompleted, wantFocus := count(before)
+	if n != wantN || completed != wantCompleted || focus != wantFocus {
+		t.Errorf("after pruning %d sessions, %d completed, %s focused; want %d, %d, %s", n, completed, focus, wantN, wantCompleted, wantFocus)
+	}
+	if q := focusQuality(after); q.sessions != 3 || q.completed != 2 || q.streak != 0 {
+		t.Errorf("quality of pruned sessions = %+v, want 3 sessions, 2 completed and no streak", q)
+	}
+}
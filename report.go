@@ -0,0 +1,263 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// reportCommand prints a summary of today's sessions and, when a plan
+// exists, compares it against what was actually done. --days widens the
+// focus quality statistics to the last n days.
+func reportCommand(args []string) {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	days := fs.Int("days", 1, "number of days to compute statistics over")
+	parseFlags(fs, args)
+
+	all, err := loadSummary()
+	if err != nil {
+		failf(codeFailed, "Failed to read history: %v", err)
+	}
+	if jsonOutput {
+		succeed(reportData(all, max(*days, 1)))
+		return
+	}
+	midnight := startOfDay(time.Now())
+	sessions := sessionsSince(all, midnight)
+
+	fmt.Printf("Today: %s\n", todaySummary())
+	period := sessionsSince(all, midnight.AddDate(0, 0, 1-max(*days, 1)))
+	printQuality(period, *days)
+	printScore(all, loadScoreTarget(loadConfig()), accessible(loadConfig()))
+	printCompliance(midnight.AddDate(0, 0, 1-max(*days, 1)), *days)
+	printProjects(period)
+	printBudgets(loadConfig())
+	printGoal(loadConfig())
+
+	p := loadPlan()
+	if len(p.Items) == 0 {
+		return
+	}
+	done := completedByTask(sessions)
+	planned := map[string]bool{}
+	fmt.Println("\nPlan vs done:")
+	for _, item := range p.Items {
+		planned[item.Task] = true
+		fmt.Printf("  %-30s %d/%d\n", item.Task, done[item.Task], item.Estimate)
+	}
+	for task, n := range done {
+		if !planned[task] {
+			if task == "" {
+				task = "(unplanned)"
+			}
+			fmt.Printf("  %-30s %d/-\n", task, n)
+		}
+	}
+	printBurndown(p, sessions, accessible(loadConfig()))
+}
+
+// reportData gathers what `pomo report` shows for --output json: today's
+// totals, score and plan, statistics over the last days days, and the
+// week's budgets and goal.
+func reportData(all []session, days int) map[string]any {
+	cfg := loadConfig()
+	now := time.Now()
+	midnight := startOfDay(now)
+	since := midnight.AddDate(0, 0, 1-days)
+	today := sessionsSince(all, midnight)
+	period := sessionsSince(all, since)
+
+	completed, focus := 0, time.Duration(0)
+	for _, s := range today {
+		if s.Completed {
+			completed++
+		}
+		focus += s.focused()
+	}
+	q := focusQuality(period)
+	breaks, err := loadBreaks(since)
+	if err != nil {
+		log.Printf("Failed to read breaks: %v", err)
+	}
+	c := breakCompliance(breaks)
+	data := map[string]any{
+		"today": map[string]any{"completed": completed, "focus": focus},
+		"days":  days,
+		"quality": map[string]any{
+			"sessions": q.sessions, "completed": q.completed, "pauses": q.pauses,
+			"paused": q.paused, "interruptions": q.interruptions, "streak": q.streak,
+		},
+		"breaks": map[string]any{
+			"breaks": c.breaks, "taken": c.taken, "short": c.short, "skipped": c.skipped,
+			"paused": c.paused, "extended": c.extended,
+		},
+		// Days without sessions score -1, and days away -2.
+		"scores":   scoreTrend(all, now, loadScoreTarget(cfg)),
+		"projects": orEmpty(projectTotals(period)),
+	}
+
+	var budgets []map[string]any
+	done := completedByTag(thisWeek())
+	for _, b := range loadBudgets(cfg) {
+		budgets = append(budgets, map[string]any{"tag": b.tag, "done": done[b.tag], "count": b.count, "max": b.max, "broken": b.broken(done[b.tag])})
+	}
+	data["budgets"] = orEmpty(budgets)
+
+	monday := startOfWeek(now)
+	if goal, away, ok := weekGoal(cfg, monday); ok {
+		r := reviewWeek(thisWeek(), monday)
+		data["goal"] = map[string]any{"goal": goal.String(), "attained": goal.attained(r.Pomodoros, r.Focus), "away": away}
+	}
+
+	var items []map[string]any
+	byTask := completedByTask(today)
+	planned := map[string]bool{}
+	for _, item := range loadPlan().Items {
+		planned[item.Task] = true
+		items = append(items, map[string]any{"task": item.Task, "estimate": item.Estimate, "done": byTask[item.Task]})
+	}
+	for task, n := range byTask {
+		if !planned[task] {
+			items = append(items, map[string]any{"task": task, "done": n})
+		}
+	}
+	data["plan"] = orEmpty(items)
+	return data
+}
+
+// printBurndown charts the planned pomodoros remaining after each
+// completed session of the day. plain leaves out the bars.
+func printBurndown(p plan, sessions []session, plain bool) {
+	remaining := 0
+	left := map[string]int{}
+	for _, item := range p.Items {
+		remaining += item.Estimate
+		left[item.Task] += item.Estimate
+	}
+	total := remaining
+
+	fmt.Println("\nBurndown:")
+	if plain {
+		fmt.Printf("  start  %d left\n", total)
+	} else {
+		fmt.Printf("  start  %s %d\n", strings.Repeat("█", total), total)
+	}
+	for _, s := range sessions {
+		if !s.Completed || left[s.Task] == 0 {
+			continue
+		}
+		left[s.Task]--
+		remaining--
+		if plain {
+			fmt.Printf("  %s  %d left\n", s.End.Format("15:04"), remaining)
+			continue
+		}
+		bar := strings.Repeat("█", remaining) + strings.Repeat("░", total-remaining)
+		fmt.Printf("  %s  %s %d\n", s.End.Format("15:04"), bar, remaining)
+	}
+}
+
+// quality summarises how focused a set of sessions was.
+type quality struct {
+	sessions      int
+	completed     int
+	pauses        int
+	paused        time.Duration
+	interruptions int
+	streak        int // longest run of completed sessions without pauses or interruptions
+}
+
+// focusQuality computes the focus quality of sessions, which must be in
+// chronological order.
+func focusQuality(sessions []session) quality {
+	var q quality
+	run := 0
+	for _, s := range sessions {
+		q.sessions++
+		if s.pruned {
+			// Only the count of a pruned session is known.
+			if s.Completed {
+				q.completed++
+			}
+			run = 0
+			continue
+		}
+		q.pauses += s.Pauses
+		q.paused += s.Paused
+		q.interruptions += s.Interruptions
+		if s.Completed {
+			q.completed++
+		}
+		if s.Completed && s.Pauses == 0 && s.Interruptions == 0 {
+			run++
+			q.streak = max(q.streak, run)
+		} else {
+			run = 0
+		}
+	}
+	return q
+}
+
+// printQuality prints the focus quality statistics for sessions from the
+// last days days.
+func printQuality(sessions []session, days int) {
+	q := focusQuality(sessions)
+	if q.sessions == 0 {
+		return
+	}
+	period := "today"
+	if days > 1 {
+		period = fmt.Sprintf("last %d days", days)
+	}
+	fmt.Printf("\nFocus quality (%s):\n", period)
+	fmt.Printf("  Completion rate       %.0f%% (%d/%d)\n", 100*float64(q.completed)/float64(q.sessions), q.completed, q.sessions)
+	fmt.Printf("  Pauses per session    %.1f (%s paused in all)\n", float64(q.pauses)/float64(q.sessions), formatMinutes(q.paused))
+	fmt.Printf("  Interruptions         %d\n", q.interruptions)
+	fmt.Printf("  Longest clean streak  %d\n", q.streak)
+}
+
+// projectTotal is the work done on a project.
+type projectTotal struct {
+	Project   string        `json:"project"`
+	Completed int           `json:"completed"`
+	Focus     time.Duration `json:"focus"`
+}
+
+// projectTotals breaks sessions down by project, most focus first.
+func projectTotals(sessions []session) []projectTotal {
+	var totals []projectTotal
+	index := map[string]int{}
+	for _, s := range sessions {
+		i, ok := index[s.Project]
+		if !ok {
+			i = len(totals)
+			index[s.Project] = i
+			totals = append(totals, projectTotal{Project: s.Project})
+		}
+		if s.Completed {
+			totals[i].Completed++
+		}
+		totals[i].Focus += s.focused()
+	}
+	sort.SliceStable(totals, func(i, j int) bool { return totals[i].Focus > totals[j].Focus })
+	return totals
+}
+
+// printProjects breaks sessions down by project.
+func printProjects(sessions []session) {
+	totals := projectTotals(sessions)
+	if len(totals) < 2 && (len(totals) == 0 || totals[0].Project == "") {
+		return
+	}
+	fmt.Println("\nBy project:")
+	for _, t := range totals {
+		name := t.Project
+		if name == "" {
+			name = "(none)"
+		}
+		fmt.Printf("  %-30s %3d  %s\n", name, t.Completed, t.Focus.Truncate(time.Minute))
+	}
+}
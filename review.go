@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// topTasks is how many tasks a weekly review lists.
+const topTasks = 5
+
+// weeklyGoal is what a week should add up to: a number of completed
+// pomodoros, or an amount of focus time.
+type weeklyGoal struct {
+	pomodoros int
+	focus     time.Duration
+}
+
+// loadWeeklyGoal reads goals.week, e.g. "40" pomodoros or "20h" of focus.
+// It reports false when no goal is set.
+func loadWeeklyGoal(cfg config) (weeklyGoal, bool) {
+	v := strings.TrimSpace(cfg.get("goals.week", ""))
+	if v == "" {
+		return weeklyGoal{}, false
+	}
+	if n, err := strconv.Atoi(v); err == nil && n > 0 {
+		return weeklyGoal{pomodoros: n}, true
+	}
+	if d, err := time.ParseDuration(v); err == nil && d > 0 {
+		return weeklyGoal{focus: d}, true
+	}
+	log.Printf("Ignoring goals.week %q", v)
+	return weeklyGoal{}, false
+}
+
+// weekGoal returns the weekly goal for the week starting monday, cut down
+// in proportion to the days spent away. It reports false when no goal is
+// set or the whole week is spent away.
+func weekGoal(cfg config, monday time.Time) (weeklyGoal, int, bool) {
+	goal, ok := loadWeeklyGoal(cfg)
+	if !ok {
+		return goal, 0, false
+	}
+	periods, err := loadAway()
+	if err != nil {
+		log.Printf("Failed to read away periods: %v", err)
+	}
+	away := awayDays(periods, monday, monday.AddDate(0, 0, 7))
+	if away == 7 {
+		return goal, away, false
+	}
+	present := float64(7-away) / 7
+	goal.pomodoros = int(math.Ceil(float64(goal.pomodoros) * present))
+	goal.focus = time.Duration(float64(goal.focus) * present).Round(time.Minute)
+	return goal, away, true
+}
+
+// String describes the goal, e.g. "40 pomodoros" or "20h focus".
+func (g weeklyGoal) String() string {
+	if g.pomodoros > 0 {
+		return fmt.Sprintf("%d pomodoros", g.pomodoros)
+	}
+	return formatMinutes(g.focus) + " focus"
+}
+
+// attained returns the fraction of the goal met by pomodoros completed
+// with focus time.
+func (g weeklyGoal) attained(pomodoros int, focus time.Duration) float64 {
+	if g.pomodoros > 0 {
+		return float64(pomodoros) / float64(g.pomodoros)
+	}
+	return float64(focus) / float64(g.focus)
+}
+
+// progress describes how far pomodoros and focus go towards the goal,
+// e.g. "32/40 pomodoros (80%)".
+func (g weeklyGoal) progress(pomodoros int, focus time.Duration) string {
+	done := fmt.Sprintf("%d/%d pomodoros", pomodoros, g.pomodoros)
+	if g.pomodoros == 0 {
+		done = fmt.Sprintf("%s/%s focus", formatMinutes(focus), formatMinutes(g.focus))
+	}
+	return fmt.Sprintf("%s (%.0f%%)", done, 100*g.attained(pomodoros, focus))
+}
+
+// awayNote mentions the days spent away, if any, after a goal.
+func awayNote(away int) string {
+	switch away {
+	case 0:
+		return ""
+	case 1:
+		return ", a day away"
+	}
+	return fmt.Sprintf(", %d days away", away)
+}
+
+// printGoal prints this week's progress towards the weekly goal.
+func printGoal(cfg config) {
+	monday := startOfWeek(time.Now())
+	goal, away, ok := weekGoal(cfg, monday)
+	if !ok {
+		return
+	}
+	r := reviewWeek(thisWeek(), monday)
+	fmt.Printf("\nWeekly goal: %s%s\n", goal.progress(r.Pomodoros, r.Focus), awayNote(away))
+}
+
+// taskTotal is the time spent on a task over a week.
+type taskTotal struct {
+	Task      string        `json:"task"`
+	Pomodoros int           `json:"pomodoros"`
+	Focus     time.Duration `json:"focus"`
+}
+
+// weekReview sums up a week for `pomo review`.
+type weekReview struct {
+	Week          string        `json:"week"` // its Monday, YYYY-MM-DD
+	Goal          string        `json:"goal,omitempty"`
+	Attained      float64       `json:"attained,omitempty"` // fraction of the goal met
+	Away          int           `json:"away,omitempty"`     // days spent away, which the goal leaves out
+	Pomodoros     int           `json:"pomodoros"`
+	Abandoned     int           `json:"abandoned"`
+	Focus         time.Duration `json:"focus"`
+	Days          [7]int        `json:"days"` // pomodoros completed, Monday first
+	Pauses        int           `json:"pauses"`
+	Interruptions int           `json:"interruptions"`
+	Tasks         []taskTotal   `json:"tasks"` // most focus first
+	Note          string        `json:"note,omitempty"`
+}
+
+// reviewWeek sums up the sessions of the week starting at monday.
+func reviewWeek(sessions []session, monday time.Time) weekReview {
+	r := weekReview{Week: monday.Format("2006-01-02"), Tasks: []taskTotal{}}
+	tasks := map[string]*taskTotal{}
+	for _, s := range sessions {
+		if s.Start.Before(monday) || !s.Start.Before(monday.AddDate(0, 0, 7)) {
+			continue
+		}
+		r.Focus += s.focused()
+		r.Pauses += s.Pauses
+		r.Interruptions += s.Interruptions
+		if !s.Completed {
+			r.Abandoned++
+		} else {
+			r.Pomodoros++
+			r.Days[(int(s.Start.Weekday())+6)%7]++
+		}
+		t := tasks[s.Task]
+		if t == nil {
+			t = &taskTotal{Task: s.Task}
+			tasks[s.Task] = t
+		}
+		t.Focus += s.focused()
+		if s.Completed {
+			t.Pomodoros++
+		}
+	}
+	for _, t := range tasks {
+		r.Tasks = append(r.Tasks, *t)
+	}
+	sort.Slice(r.Tasks, func(i, j int) bool {
+		a, b := r.Tasks[i], r.Tasks[j]
+		if a.Focus != b.Focus {
+			return a.Focus > b.Focus
+		}
+		return a.Pomodoros > b.Pomodoros
+	})
+	return r
+}
+
+// retrospective is a note written about a week with `pomo review`.
+type retrospective struct {
+	Week    string    `json:"week"`
+	Written time.Time `json:"written"`
+	Note    string    `json:"note"`
+}
+
+func reviewsPath() string {
+	return filepath.Join(dataDir(), "reviews.jsonl")
+}
+
+// appendRetrospective adds r to the end of the reviews file, encrypting
+// the note along with the history.
+func appendRetrospective(r retrospective) error {
+	note, err := seal(r.Note)
+	if err != nil {
+		return err
+	}
+	r.Note = note
+	if err := os.MkdirAll(dataDir(), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(reviewsPath(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewEncoder(f).Encode(r)
+}
+
+// loadRetrospective returns the latest note written about week, or "".
+func loadRetrospective(week string) string {
+	f, err := os.Open(reviewsPath())
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	note := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r retrospective
+		if json.Unmarshal(scanner.Bytes(), &r) == nil && r.Week == week {
+			note = r.Note
+		}
+	}
+	return unseal(note)
+}
+
+// interactive reports whether stdin is a terminal.
+func interactive() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// reviewCommand implements `pomo review`, which walks through this week,
+// or with --last the previous one: goal attainment, top tasks and
+// interruptions. It then asks for a short retrospective note, or stores
+// the one given with --note.
+func reviewCommand(args []string) {
+	fs := flag.NewFlagSet("review", flag.ExitOnError)
+	last := fs.Bool("last", false, "review last week rather than this one")
+	note := fs.String("note", "", "retrospective note to store without asking")
+	parseFlags(fs, args)
+
+	sessions, err := loadSummary()
+	if err != nil {
+		failf(codeFailed, "Failed to read history: %v", err)
+	}
+	monday := startOfWeek(time.Now())
+	if *last {
+		monday = monday.AddDate(0, 0, -7)
+	}
+	r := reviewWeek(sessions, monday)
+	cfg := loadConfig()
+	goal, away, hasGoal := weekGoal(cfg, monday)
+	r.Away = away
+	if hasGoal {
+		r.Goal, r.Attained = goal.String(), goal.attained(r.Pomodoros, r.Focus)
+	}
+	if *note != "" {
+		if err := appendRetrospective(retrospective{Week: r.Week, Written: time.Now(), Note: *note}); err != nil {
+			failf(codeFailed, "Failed to save note: %v", err)
+		}
+	}
+	r.Note = loadRetrospective(r.Week)
+	if jsonOutput {
+		succeed(r)
+		return
+	}
+
+	fmt.Printf("Week of %s\n\n", r.Week)
+	if hasGoal {
+		fmt.Printf("  Goal           %s%s\n", goal.progress(r.Pomodoros, r.Focus), awayNote(away))
+	}
+	fmt.Printf("  Pomodoros      %d (%d abandoned)\n", r.Pomodoros, r.Abandoned)
+	fmt.Printf("  Focus          %s\n", formatMinutes(r.Focus))
+	fmt.Printf("  Interruptions  %d\n", r.Interruptions)
+	fmt.Printf("  Pauses         %d\n", r.Pauses)
+
+	fmt.Println("\nBy day:")
+	periods, _ := loadAway()
+	for i, n := range r.Days {
+		day := monday.AddDate(0, 0, i)
+		if n == 0 && awayOn(periods, day) {
+			fmt.Printf("  %s  away\n", day.Format("Mon"))
+			continue
+		}
+		fmt.Printf("  %s  %-12s %d\n", day.Format("Mon"), strings.Repeat("●", min(n, 12)), n)
+	}
+	if len(r.Tasks) > 0 {
+		fmt.Println("\nTop tasks:")
+		for _, t := range r.Tasks[:min(len(r.Tasks), topTasks)] {
+			task := t.Task
+			if task == "" {
+				task = "(no task)"
+			}
+			fmt.Printf("  %-30s %3d  %s\n", truncate(task, 30), t.Pomodoros, formatMinutes(t.Focus))
+		}
+	}
+
+	if r.Note != "" {
+		fmt.Printf("\nRetrospective:\n  %s\n", r.Note)
+	}
+	if *note != "" || !interactive() {
+		return
+	}
+	fmt.Print("\nRetrospective note (enter to skip): ")
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if line = strings.TrimSpace(line); line != "" {
+		if err := appendRetrospective(retrospective{Week: r.Week, Written: time.Now(), Note: line}); err != nil {
+			failf(codeFailed, "Failed to save note: %v", err)
+		}
+		fmt.Println("Saved.")
+	}
+}
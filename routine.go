@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// phase is a single interval of a running timer.
+type phase struct {
+	Kind     string        `json:"kind"` // "work", "break", "long break", "meeting" or "delay"
+	Duration time.Duration `json:"duration"`
+
+	// Ratio is set on open-ended flowtime work phases, which have no
+	// Duration: the break that follows lasts Ratio times the work done.
+	Ratio float64 `json:"ratio,omitempty"`
+
+	// Command is set on work phases run by `pomo run`, which end when
+	// it stops them rather than by themselves, running into overtime if
+	// need be.
+	Command string `json:"command,omitempty"`
+}
+
+// isBreak reports whether kind is a break or long break.
+func isBreak(kind string) bool {
+	return strings.HasSuffix(kind, "break")
+}
+
+// parseSequence parses a sequence such as "4x25/5+20": four 25 minute work
+// intervals separated by 5 minute breaks, followed by a 20 minute long break.
+func parseSequence(spec string) ([]phase, error) {
+	spec = strings.ReplaceAll(spec, "×", "x")
+	spec, long, hasLong := strings.Cut(spec, "+")
+
+	count := 1
+	if n, rest, ok := strings.Cut(spec, "x"); ok {
+		c, err := strconv.Atoi(strings.TrimSpace(n))
+		if err != nil || c < 1 {
+			return nil, fmt.Errorf("invalid count in %q", spec)
+		}
+		count, spec = c, rest
+	}
+
+	workStr, breakStr, hasBreak := strings.Cut(spec, "/")
+	work, err := parseMinutes(workStr)
+	if err != nil {
+		return nil, err
+	}
+	var brk time.Duration
+	if hasBreak {
+		if brk, err = parseMinutes(breakStr); err != nil {
+			return nil, err
+		}
+	}
+
+	var phases []phase
+	for i := 0; i < count; i++ {
+		if i > 0 && brk > 0 {
+			phases = append(phases, phase{Kind: "break", Duration: brk})
+		}
+		phases = append(phases, phase{Kind: "work", Duration: work})
+	}
+	if hasLong {
+		d, err := parseMinutes(strings.TrimSuffix(strings.TrimSpace(long), "break"))
+		if err != nil {
+			return nil, err
+		}
+		phases = append(phases, phase{Kind: "long break", Duration: d})
+	}
+	return phases, nil
+}
+
+// presets are the built-in work/break cycles selectable with --preset and
+// usable by name in routines.
+var presets = map[string][]phase{
+	"pomodoro": {
+		{Kind: "work", Duration: 25 * time.Minute}, {Kind: "break", Duration: 5 * time.Minute},
+		{Kind: "work", Duration: 25 * time.Minute}, {Kind: "break", Duration: 5 * time.Minute},
+		{Kind: "work", Duration: 25 * time.Minute}, {Kind: "break", Duration: 5 * time.Minute},
+		{Kind: "work", Duration: 25 * time.Minute}, {Kind: "long break", Duration: 15 * time.Minute},
+	},
+	"25-5":  {{Kind: "work", Duration: 25 * time.Minute}, {Kind: "break", Duration: 5 * time.Minute}},
+	"50-10": {{Kind: "work", Duration: 50 * time.Minute}, {Kind: "break", Duration: 10 * time.Minute}},
+	"52-17": {{Kind: "work", Duration: 52 * time.Minute}, {Kind: "break", Duration: 17 * time.Minute}},
+	"90-20": {{Kind: "work", Duration: 90 * time.Minute}, {Kind: "break", Duration: 20 * time.Minute}},
+}
+
+// preset returns a copy of the named preset's phases.
+func preset(name string) ([]phase, error) {
+	p, ok := presets[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown preset %q", name)
+	}
+	return slices.Clone(p), nil
+}
+
+// resolveRoutine expands the named routine from the [routines] section of
+// the config. A routine is a comma separated list of sequences or names of
+// other routines, presets or profiles, e.g. daily = "morning, 52-17,
+// writing, 2x25/5". It also returns the first profile named, which sets up
+// the session.
+func resolveRoutine(cfg config, name string, seen map[string]bool) ([]phase, string, error) {
+	spec, ok := cfg["routines."+name]
+	if !ok {
+		return nil, "", fmt.Errorf("unknown routine %q", name)
+	}
+	if seen[name] {
+		return nil, "", fmt.Errorf("routine %q refers to itself", name)
+	}
+	seen[name] = true
+	defer delete(seen, name)
+
+	var phases []phase
+	first := ""
+	for _, part := range strings.Split(spec, ",") {
+		p, prof, err := routineStep(cfg, strings.TrimSpace(part), seen)
+		if err != nil {
+			return nil, "", err
+		}
+		if first == "" {
+			first = prof
+		}
+		phases = append(phases, p...)
+	}
+	return phases, first, nil
+}
+
+// routineStep expands a step of a routine: the name of a routine, preset
+// or profile, in that order, or else a sequence. A profile stands for the
+// phases of its duration. It also returns the first profile named.
+func routineStep(cfg config, step string, seen map[string]bool) ([]phase, string, error) {
+	if _, isRoutine := cfg["routines."+step]; isRoutine {
+		return resolveRoutine(cfg, step, seen)
+	}
+	if _, isPreset := presets[step]; isPreset {
+		p, err := preset(step)
+		return p, "", err
+	}
+	if hasProfile(cfg, step) {
+		if seen["profiles."+step] {
+			return nil, "", fmt.Errorf("profile %q refers to itself", step)
+		}
+		seen["profiles."+step] = true
+		defer delete(seen, "profiles."+step)
+		spec := cfg.get("profiles."+step+".duration", "")
+		if spec == "" {
+			spec = defaultDuration(cfg, time.Now())
+		}
+		p, _, err := profilePhases(cfg, spec, seen)
+		if err != nil {
+			return nil, "", fmt.Errorf("profiles.%s.duration: %w", step, err)
+		}
+		return p, step, nil
+	}
+	p, err := parseSequence(step)
+	return p, "", err
+}
+
+// profilePhases expands a profile's duration: a single work interval of
+// a duration such as "50m" or "long", or else a routine step, so that a
+// profile can run a sequence such as "4x25/5+20".
+func profilePhases(cfg config, spec string, seen map[string]bool) ([]phase, string, error) {
+	if d, err := parseDuration(cfg, "work", spec); err == nil {
+		return []phase{{Kind: "work", Duration: d}}, "", nil
+	}
+	return routineStep(cfg, strings.TrimSpace(spec), seen)
+}
+
+// parseRatio parses a break ratio written as a fraction ("1/5") or a
+// decimal ("0.2").
+func parseRatio(s string) (float64, error) {
+	if num, den, ok := strings.Cut(s, "/"); ok {
+		n, err1 := strconv.ParseFloat(strings.TrimSpace(num), 64)
+		d, err2 := strconv.ParseFloat(strings.TrimSpace(den), 64)
+		if err1 != nil || err2 != nil || d == 0 {
+			return 0, fmt.Errorf("invalid ratio %q", s)
+		}
+		return n / d, nil
+	}
+	return strconv.ParseFloat(strings.TrimSpace(s), 64)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseSequence(t *testing.T) {
+	work := func(m int) phase { return phase{Kind: "work", Duration: time.Duration(m) * time.Minute} }
+	brk := func(m int) phase { return phase{Kind: "break", Duration: time.Duration(m) * time.Minute} }
+	long := func(m int) phase { return phase{Kind: "long break", Duration: time.Duration(m) * time.Minute} }
+
+	tests := []struct {
+		spec string
+		want []phase
+	}{
+		{"25", []phase{work(25)}},
+		{"4x25/5+20", []phase{work(25), brk(5), work(25), brk(5), work(25), brk(5), work(25), long(20)}},
+		{"2×50/10", []phase{work(50), brk(10), work(50)}},
+		{"3x1h30", []phase{work(90), work(90), work(90)}},
+		{"2x25/5+15break", []phase{work(25), brk(5), work(25), long(15)}},
+		{"25m+1h", []phase{work(25), long(60)}},
+	}
+	for _, tt := range tests {
+		got, err := parseSequence(tt.spec)
+		if err != nil {
+			t.Errorf("parseSequence(%q): %v", tt.spec, err)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("parseSequence(%q) = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestParseSequenceRejects(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"x25",
+		"0x25",
+		"-1x25",
+		"4x0/5",
+		"4x25/0",
+		"4x-25/5",
+		"4x25/5+0",
+		"4x25/5+-15",
+		"4xlots/5",
+	} {
+		if phases, err := parseSequence(spec); err == nil {
+			t.Errorf("parseSequence(%q) = %v, want an error", spec, phases)
+		}
+	}
+}
+
+func TestResolveRoutineWithProfiles(t *testing.T) {
+	cfg := config{
+		"routines.morning":          "3x50/10",
+		"routines.daily":            "morning, writing, review",
+		"routines.loop":             "looping",
+		"profiles.writing.duration": "2x25/5",
+		"profiles.writing.project":  "book",
+		"profiles.review.duration":  "short",
+		"profiles.looping.duration": "loop",
+	}
+	work := func(m int) phase { return phase{Kind: "work", Duration: time.Duration(m) * time.Minute} }
+	brk := func(m int) phase { return phase{Kind: "break", Duration: time.Duration(m) * time.Minute} }
+
+	phases, prof, err := resolveRoutine(cfg, "daily", map[string]bool{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []phase{work(50), brk(10), work(50), brk(10), work(50), work(25), brk(5), work(25), work(25)}
+	if !slices.Equal(phases, want) {
+		t.Errorf("daily = %v, want %v", phases, want)
+	}
+	if prof != "writing" {
+		t.Errorf("daily sets up profile %q, want the first named, writing", prof)
+	}
+	if _, _, err := resolveRoutine(cfg, "loop", map[string]bool{}); err == nil {
+		t.Error("a routine whose profile names the routine again resolved")
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// runGrace is how long a command interrupted when its time is up has to
+// exit before it is killed.
+const runGrace = 10 * time.Second
+
+// runCommand implements `pomo run [duration] [flags] -- command...`, which
+// runs command in a work session of its own. --end says what ends the
+// session: "either" the command finishing or the time running out, which
+// interrupts the command (the default); only the "command" finishing,
+// running into overtime if need be; or only the "timer", leaving the
+// command running. The command and its exit status are recorded with the
+// session, and pomo exits with the command's status.
+func runCommand(args []string) {
+	dash := slices.Index(args, "--")
+	if dash == -1 || dash == len(args)-1 {
+		usage("pomo run [duration] [flags] -- <command> [arguments]")
+	}
+	command := args[dash+1:]
+
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	req := timerFlags(fs)
+	end := fs.String("end", "either", "what ends the session: either, command or timer")
+	positional := parseFlags(fs, args[:dash])
+	if !slices.Contains([]string{"either", "command", "timer"}, *end) {
+		usage("--end must be either, command or timer")
+	}
+	if req.DryRun {
+		usage("pomo run has no --dry-run")
+	}
+	if req.Delay > 0 {
+		usage("pomo run has no --in, as the command starts right away")
+	}
+	cfg := loadConfig()
+	var durationStr string
+	if len(positional) > 0 {
+		durationStr = positional[0]
+	} else {
+		durationStr = mustDefaultStart(cfg, req, time.Now())
+	}
+	work := phase{Kind: "work", Duration: mustDuration(cfg, "work", durationStr), Command: strings.Join(command, " ")}
+	launchTimer(req, []phase{work})
+	if req.Name == "" {
+		req.Name = defaultTimer
+	}
+
+	// Ctrl-C reaches the command directly; pomo waits to record how it
+	// exited. Caught signals, unlike ignored ones, are reset for the
+	// command.
+	signal.Notify(make(chan os.Signal, 1), syscall.SIGINT, syscall.SIGQUIT)
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if jsonOutput {
+		// Keep stdout for the result.
+		cmd.Stdout = os.Stderr
+	}
+	exited := make(chan int, 1)
+	if err := cmd.Start(); err != nil {
+		send(request{Cmd: "stop", Name: req.Name, Outcome: "abandon", Exit: ptr(127)})
+		if jsonOutput {
+			writeResult(result{Error: &resultError{Code: codeFailed, Message: err.Error()}})
+		} else {
+			fmt.Fprintf(os.Stderr, "pomo: %v\n", err)
+		}
+		os.Exit(127)
+	}
+	go func() {
+		cmd.Wait()
+		exited <- exitStatus(cmd.ProcessState)
+	}()
+
+	status, done, stopped := 0, false, false
+	stop := func(complete bool, exit *int) {
+		outcome := "abandon"
+		if complete {
+			outcome = "complete"
+		}
+		if _, err := send(request{Cmd: "stop", Name: req.Name, Outcome: outcome, Exit: exit}); err != nil && !errors.Is(err, errNoDaemon) {
+			fmt.Fprintf(os.Stderr, "pomo: %v\n", err)
+		}
+		stopped = true
+	}
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for !done {
+		select {
+		case status = <-exited:
+			done = true
+			if !stopped && *end != "timer" {
+				// The session counts if the command succeeded.
+				stop(status == 0, &status)
+			}
+		case <-ticker.C:
+			if stopped {
+				continue
+			}
+			resp, err := send(request{Cmd: "list", Name: req.Name})
+			if err != nil || len(resp.Timers) == 0 {
+				// Stopped by hand: leave the command be.
+				stopped = true
+				continue
+			}
+			if t := resp.Timers[0]; t.Ends.IsZero() || time.Now().Before(t.Ends) || *end == "command" {
+				continue
+			}
+			if *end == "timer" {
+				stop(true, nil)
+				continue
+			}
+			fmt.Fprintln(os.Stderr, "pomo: time is up, interrupting the command")
+			cmd.Process.Signal(os.Interrupt)
+			select {
+			case status = <-exited:
+			case <-time.After(runGrace):
+				cmd.Process.Kill()
+				status = <-exited
+			}
+			done = true
+			stop(true, &status)
+		}
+	}
+	if *end == "timer" && !stopped {
+		// The command finished first; the session goes on until the
+		// timer ends it.
+		if _, err := send(request{Cmd: "exited", Name: req.Name, Exit: &status}); err != nil {
+			fmt.Fprintf(os.Stderr, "pomo: %v\n", err)
+		}
+	}
+	succeed(map[string]any{"exit": status})
+	os.Exit(status)
+}
+
+// exitStatus returns the exit status of a finished process as a shell
+// would: 128 plus the signal for one that was killed.
+func exitStatus(ps *os.ProcessState) int {
+	if ws, ok := ps.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return 128 + int(ws.Signal())
+	}
+	return ps.ExitCode()
+}
+
+// ptr returns a pointer to v.
+func ptr[T any](v T) *T {
+	return &v
+}
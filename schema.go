@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// schemaVersion is the version of the on-disk data layout, kept in the
+// schema file in the data directory. Backups record the version of the
+// data they hold, so that restoring data from a newer pomo can be refused.
+const schemaVersion = 2
+
+// migration upgrades the data directory by one schema version.
+type migration struct {
+	what string
+	run  func() error
+}
+
+// migrations[i] upgrades data from schema version i+1 to i+2. A release
+// that changes how existing data is stored adds one and bumps
+// schemaVersion, rather than teaching every reader the old layout.
+var migrations = []migration{
+	{"history, breaks and meetings in UTC", migrateToUTC},
+}
+
+func schemaPath() string {
+	return filepath.Join(dataDir(), "schema")
+}
+
+// dataVersion returns the schema version of the data directory: the one
+// in the schema file, 1 for data from before versions were kept, or
+// schemaVersion when there is no data yet.
+func dataVersion() (int, error) {
+	data, err := os.ReadFile(schemaPath())
+	if err == nil {
+		return strconv.Atoi(strings.TrimSpace(string(data)))
+	}
+	if !os.IsNotExist(err) {
+		return 0, err
+	}
+	entries, err := os.ReadDir(dataDir())
+	if err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+	for _, e := range entries {
+		if ext := filepath.Ext(e.Name()); ext == ".jsonl" || ext == ".json" {
+			return 1, nil
+		}
+	}
+	return schemaVersion, nil
+}
+
+// setDataVersion records version in the schema file.
+func setDataVersion(version int) error {
+	if err := os.MkdirAll(dataDir(), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(schemaPath(), []byte(strconv.Itoa(version)+"\n"), 0644)
+}
+
+// migrate brings the data directory up to schemaVersion, one migration at
+// a time, after backing it up next to the data directory. Data written by
+// a newer pomo is refused rather than misread. It holds the history lock,
+// so that a daemon and a command migrating at once take turns.
+func migrate() error {
+	lock, err := lockHistory()
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	version, err := dataVersion()
+	if err != nil {
+		return fmt.Errorf("reading the schema version: %w", err)
+	}
+	if version < 1 {
+		return fmt.Errorf("%s has an invalid schema version %d", dataDir(), version)
+	}
+	if version > schemaVersion {
+		return fmt.Errorf("%s uses schema version %d, this pomo supports up to %d", dataDir(), version, schemaVersion)
+	}
+	if version < schemaVersion {
+		archive := filepath.Join(filepath.Dir(dataDir()), fmt.Sprintf("pomo-schema-%d.tar.gz", version))
+		if err := writeBackup(archive); err != nil {
+			return fmt.Errorf("backing up to %s: %w", archive, err)
+		}
+		log.Printf("Backed up data to %s before migrating it", archive)
+	}
+	for ; version < schemaVersion; version++ {
+		m := migrations[version-1]
+		if err := m.run(); err != nil {
+			return fmt.Errorf("migrating to schema version %d, %s: %w", version+1, m.what, err)
+		}
+		if err := setDataVersion(version + 1); err != nil {
+			return err
+		}
+		log.Printf("Migrated data to schema version %d: %s", version+1, m.what)
+	}
+	if _, err := os.Stat(schemaPath()); os.IsNotExist(err) {
+		return setDataVersion(schemaVersion)
+	}
+	return nil
+}
+
+// upgradeData migrates the data directory for a command that reads it,
+// which may run before any daemon has started and migrated it.
+func upgradeData() {
+	if err := migrate(); err != nil {
+		failf(codeFailed, "Failed to migrate data: %v", err)
+	}
+}
+
+// rewriteRecords rewrites every record in the JSON lines file at path
+// with fix, in place. Lines that do not parse as a T are kept as they
+// are. A missing file is left missing.
+func rewriteRecords[T any](path string, fix func(*T)) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var out bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		var record T
+		if json.Unmarshal(line, &record) == nil {
+			fix(&record)
+			if fixed, err := json.Marshal(record); err == nil {
+				line = fixed
+			}
+		}
+		out.Write(line)
+		out.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return replaceFile(path, out.Bytes())
+}
+
+// replaceFile atomically replaces the file at path with data.
+func replaceFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".pomo-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// migrateToUTC stores the times of records written in local time in UTC,
+// with the offset they were written at, as sessions, breaks and meetings
+// are written now. Records with an offset are in UTC already.
+func migrateToUTC() error {
+	if err := rewriteRecords(historyPath(), func(s *session) {
+		if s.Offset == 0 {
+			*s = s.inUTC()
+		}
+	}); err != nil {
+		return err
+	}
+	if err := rewriteRecords(breaksPath(), func(b *takenBreak) {
+		if b.Offset == 0 {
+			_, b.Offset = b.Start.Zone()
+			b.Start, b.End = b.Start.UTC(), b.End.UTC()
+		}
+	}); err != nil {
+		return err
+	}
+	return rewriteRecords(meetingsPath(), func(m *meeting) {
+		if m.Offset == 0 {
+			_, m.Offset = m.Start.Zone()
+			m.Start, m.End = m.Start.UTC(), m.End.UTC()
+		}
+	})
+}
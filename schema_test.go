@@ -0,0 +1,18 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestMigrateRejectsInvalidVersions(t *testing.T) {
+	for _, version := range []string{"0", "-3", "junk", "999"} {
+		testData(t)
+		if err := os.WriteFile(schemaPath(), []byte(version+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := migrate(); err == nil {
+			t.Errorf("migrate with schema version %q succeeded, want an error", version)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// scoreDays is how many days the focus score trend covers.
+const scoreDays = 30
+
+// sparks draw the focus score trend, lowest first.
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// loadScoreTarget reads score.target, the completed pomodoros a day that
+// earn the full volume part of the focus score (8 by default).
+func loadScoreTarget(cfg config) int {
+	if n, err := strconv.Atoi(cfg.get("score.target", "")); err == nil && n > 0 {
+		return n
+	}
+	return 8
+}
+
+// focusScore rates a day's sessions and breaks from 0 to 100: 40 points
+// for the share of sessions completed, 30 for completing target of them,
+// 15 for few interruptions (none left at two a session) and 15 for the
+// share of breaks taken in full. It reports false for a day without
+// sessions.
+func focusScore(sessions []session, breaks []takenBreak, target int) (int, bool) {
+	q := focusQuality(sessions)
+	if q.sessions == 0 {
+		return 0, false
+	}
+	completion := float64(q.completed) / float64(q.sessions)
+	volume := min(float64(q.completed)/float64(target), 1)
+	calm := max(1-float64(q.interruptions)/float64(q.sessions)/2, 0)
+	score := 40*completion + 30*volume + 15*calm + 15*breakCompliance(breaks).rate()
+	return int(score + 0.5), true
+}
+
+// dailyScores returns the focus score of each of the days days up to and
+// including the one holding last, oldest first, with -1 for days without
+// sessions and -2 for those spent away.
+func dailyScores(sessions []session, breaks []takenBreak, away []awayPeriod, last time.Time, days, target int) []int {
+	first := startOfDay(last).AddDate(0, 0, 1-days)
+	scores := make([]int, days)
+	for i := range scores {
+		from, to := first.AddDate(0, 0, i), first.AddDate(0, 0, i+1)
+		var day []session
+		for _, s := range sessionsSince(sessions, from) {
+			if s.Start.Before(to) {
+				day = append(day, s)
+			}
+		}
+		var taken []takenBreak
+		for _, b := range breaks {
+			if !b.Start.Before(from) && b.Start.Before(to) {
+				taken = append(taken, b)
+			}
+		}
+		switch score, ok := focusScore(day, taken, target); {
+		case ok:
+			scores[i] = score
+		case awayOn(away, from):
+			scores[i] = -2
+		default:
+			scores[i] = -1
+		}
+	}
+	return scores
+}
+
+// averageScore returns the mean of the scores of days with sessions, or
+// -1 if there are none.
+func averageScore(scores []int) int {
+	sum, n := 0, 0
+	for _, s := range scores {
+		if s >= 0 {
+			sum, n = sum+s, n+1
+		}
+	}
+	if n == 0 {
+		return -1
+	}
+	return (sum + n/2) / n
+}
+
+// trendLine draws scores as a sparkline, a blank for days without
+// sessions and a dot for days away.
+func trendLine(scores []int) string {
+	var b strings.Builder
+	for _, s := range scores {
+		if s == -2 {
+			b.WriteRune('·')
+			continue
+		}
+		if s < 0 {
+			b.WriteRune(' ')
+			continue
+		}
+		b.WriteRune(sparks[min(s*len(sparks)/101, len(sparks)-1)])
+	}
+	return b.String()
+}
+
+// scoreTrend returns the focus scores of the scoreDays days up to the one
+// holding last.
+func scoreTrend(sessions []session, last time.Time, target int) []int {
+	breaks, err := loadBreaks(startOfDay(last).AddDate(0, 0, 1-scoreDays))
+	if err != nil {
+		log.Printf("Failed to read breaks: %v", err)
+	}
+	away, err := loadAway()
+	if err != nil {
+		log.Printf("Failed to read away periods: %v", err)
+	}
+	return dailyScores(sessions, breaks, away, last, scoreDays, target)
+}
+
+// scoreField renders the {score} status field: today's focus score, or
+// nothing before the first session.
+func scoreField(cfg config) string {
+	sessions, _ := loadSessions()
+	breaks, _ := loadBreaks(startOfDay(time.Now()))
+	score, ok := focusScore(sessionsSince(sessions, startOfDay(time.Now())), breaks, loadScoreTarget(cfg))
+	if !ok {
+		return ""
+	}
+	return strconv.Itoa(score)
+}
+
+// printScore prints today's focus score and its trend over scoreDays
+// days. plain leaves out the sparkline.
+func printScore(sessions []session, target int, plain bool) {
+	scores := scoreTrend(sessions, time.Now(), target)
+	today := scores[len(scores)-1]
+	if today < 0 && averageScore(scores) < 0 {
+		return
+	}
+	fmt.Println()
+	switch today {
+	case -2:
+		fmt.Println("Focus score: away today")
+	case -1:
+		fmt.Println("Focus score: no sessions today")
+	default:
+		fmt.Printf("Focus score: %d/100\n", today)
+	}
+	if plain {
+		fmt.Printf("  %d day average  %d\n", scoreDays, averageScore(scores))
+		return
+	}
+	fmt.Printf("  %d days  %s  average %d\n", scoreDays, trendLine(scores), averageScore(scores))
+}
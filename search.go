@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// matches reports whether s mentions query, case-insensitively, in its
+// task, note, tags or checkpoints.
+func (s session) matches(query string) bool {
+	query = strings.ToLower(query)
+	if strings.Contains(strings.ToLower(s.Task), query) || strings.Contains(strings.ToLower(s.Note), query) {
+		return true
+	}
+	if slices.ContainsFunc(s.Checkpoints, func(c checkpoint) bool {
+		return strings.Contains(strings.ToLower(c.Note), query)
+	}) {
+		return true
+	}
+	return slices.ContainsFunc(s.Tags, func(tag string) bool {
+		return strings.Contains(strings.ToLower(tag), query)
+	})
+}
+
+// parseDay parses a YYYY-MM-DD date as local midnight.
+func parseDay(s string) (time.Time, error) {
+	return time.ParseInLocation(time.DateOnly, s, time.Local)
+}
+
+// historyCommand implements `pomo history search <query>`, listing past
+// sessions whose task, note or tags contain the query. --since and
+// --until bound the dates searched (both inclusive) and --project keeps
+// one project's sessions.
+func historyCommand(args []string) {
+	if len(args) == 0 || args[0] != "search" {
+		usage("pomo history search <text>")
+	}
+	fs := flag.NewFlagSet("history search", flag.ExitOnError)
+	since := fs.String("since", "", "first day to search, as YYYY-MM-DD")
+	until := fs.String("until", "", "last day to search, as YYYY-MM-DD")
+	project := fs.String("project", "", "only search this project's sessions")
+	args = parseFlags(fs, args[1:])
+	if len(args) != 1 {
+		usage("pomo history search <text>")
+	}
+
+	var from, to time.Time
+	var err error
+	if *since != "" {
+		if from, err = parseDay(*since); err != nil {
+			failf(codeUsage, "Failed to parse --since: %v", err)
+		}
+	}
+	if *until != "" {
+		if to, err = parseDay(*until); err != nil {
+			failf(codeUsage, "Failed to parse --until: %v", err)
+		}
+		to = to.AddDate(0, 0, 1)
+	}
+
+	sessions, err := loadSessions()
+	if err != nil {
+		failf(codeFailed, "Failed to read history: %v", err)
+	}
+	matched := []session{}
+	for _, s := range sessionsSince(sessions, from) {
+		if !to.IsZero() && !s.Start.Before(to) {
+			continue
+		}
+		if (*project != "" && s.Project != *project) || !s.matches(args[0]) {
+			continue
+		}
+		matched = append(matched, s)
+	}
+	if jsonOutput {
+		succeed(matched)
+		return
+	}
+	if len(matched) == 0 {
+		fmt.Println("No matching sessions.")
+		os.Exit(1)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, s := range matched {
+		status := "done"
+		if !s.Completed {
+			status = "abandoned"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", s.Start.Format("2006-01-02 15:04"),
+			s.End.Sub(s.Start).Truncate(time.Minute), status, s.Task, s.Project, strings.Join(s.Tags, ","))
+	}
+	w.Flush()
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// stdioRequest is a line read by `pomo serve --stdio`: a request to the
+// daemon, as on its socket, with an ID that the response echoes.
+type stdioRequest struct {
+	ID json.RawMessage `json:"id,omitempty"`
+	request
+}
+
+// stdioMessage is a line written by `pomo serve --stdio`: the response
+// to the request with ID, or with Event "status" a pushed update.
+type stdioMessage struct {
+	ID    json.RawMessage `json:"id,omitempty"`
+	Event string          `json:"event,omitempty"`
+	response
+}
+
+// stdioServer answers requests read from in on out, one JSON object per
+// line each way.
+type stdioServer struct {
+	mu  sync.Mutex // held while writing a line
+	out *json.Encoder
+
+	subscribed chan struct{} // closed to end the status pushes; nil when none
+}
+
+// write sends msg as a line of its own.
+func (s *stdioServer) write(msg stdioMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.out.Encode(msg)
+}
+
+// handle answers req. "subscribe" starts pushing the status whenever it
+// changes, and "unsubscribe" stops it. A "start" without phases runs a
+// single work interval of duration, or the configured default length.
+func (s *stdioServer) handle(req stdioRequest) {
+	reply := stdioMessage{ID: req.ID}
+	switch req.Cmd {
+	case "subscribe":
+		if s.subscribed == nil {
+			s.subscribed = make(chan struct{})
+			go s.push(s.subscribed)
+		}
+		reply.OK = true
+	case "unsubscribe":
+		if s.subscribed != nil {
+			close(s.subscribed)
+			s.subscribed = nil
+		}
+		reply.OK = true
+	default:
+		r := req.request
+		if r.Cmd == "start" && len(r.Phases) == 0 {
+			cfg := loadConfig()
+			d := r.Duration
+			if d == 0 {
+				spec, err := defaultStart(cfg, &r, time.Now())
+				if err != nil {
+					reply.Code, reply.Error = codeFailed, err.Error()
+					break
+				}
+				if d, err = parseDuration(cfg, "work", spec); err != nil {
+					reply.Code, reply.Error = codeUsage, err.Error()
+					break
+				}
+			}
+			r.Phases, r.Duration = []phase{{Kind: "work", Duration: d}}, 0
+		}
+		var resp response
+		var err error
+		if r.Cmd == "start" || r.Cmd == "unshelve" {
+			resp, err = sendStarting(r)
+		} else {
+			resp, err = send(r)
+		}
+		if err != nil {
+			reply.Code, reply.Error = sendError(err)
+			break
+		}
+		reply.response = resp
+	}
+	s.write(reply)
+}
+
+// push writes the status every time it changes, checking every second,
+// until stop is closed.
+func (s *stdioServer) push(stop chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	var last *response
+	for {
+		resp, err := send(request{Cmd: "statusline"})
+		if err != nil {
+			// No daemon, no timers.
+			resp = response{OK: true}
+		}
+		if last == nil || resp.Status != last.Status || !slices.EqualFunc(resp.Timers, last.Timers, sameState) {
+			s.write(stdioMessage{Event: "status", response: resp})
+			last = &resp
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sameState reports whether a and b describe a timer in the same phase,
+// ignoring the time left.
+func sameState(a, b timerInfo) bool {
+	return a.Name == b.Name && a.Phase == b.Phase && a.Paused == b.Paused && a.Task == b.Task
+}
+
+// serveCommand implements `pomo serve --stdio`, which embeds pomo in an
+// editor plugin or wrapper as a subprocess: it reads daemon requests, one
+// JSON object per line, from stdin and writes each response as a line to
+// stdout, with pushed status updates after "subscribe". It exits when
+// stdin ends.
+func serveCommand(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	stdio := fs.Bool("stdio", false, "speak newline-delimited JSON over stdin and stdout")
+	parseFlags(fs, args)
+	if !*stdio {
+		usage("pomo serve --stdio")
+	}
+	s := &stdioServer{out: json.NewEncoder(os.Stdout)}
+	in := bufio.NewReader(os.Stdin)
+	for {
+		line, err := in.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var req stdioRequest
+			if jerr := json.Unmarshal(line, &req); jerr != nil {
+				s.write(stdioMessage{response: response{Error: jerr.Error(), Code: codeUsage}})
+			} else {
+				s.handle(req)
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
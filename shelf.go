@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// shelved is a timer set aside with `pomo shelve`, paused with its
+// remaining time, task, notes and counters, until `pomo unshelve`.
+type shelved struct {
+	Shelved time.Time  `json:"shelved"`
+	Timer   savedTimer `json:"timer"`
+}
+
+func shelfPath() string {
+	return filepath.Join(dataDir(), "shelf.jsonl")
+}
+
+// loadShelf reads the shelved timers, oldest first. A missing file yields
+// none.
+func loadShelf() ([]shelved, error) {
+	f, err := os.Open(shelfPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var shelf []shelved
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var s shelved
+		if json.Unmarshal(scanner.Bytes(), &s) == nil {
+			s.Timer = s.Timer.unsealed()
+			shelf = append(shelf, s)
+		}
+	}
+	return shelf, scanner.Err()
+}
+
+// saveShelf replaces the shelved timers with shelf.
+func saveShelf(shelf []shelved) error {
+	if len(shelf) == 0 {
+		if err := os.Remove(shelfPath()); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	sealed := make([]shelved, len(shelf))
+	for i, s := range shelf {
+		timer, err := s.Timer.sealed()
+		if err != nil {
+			return err
+		}
+		sealed[i] = shelved{Shelved: s.Shelved, Timer: timer}
+	}
+	if err := os.MkdirAll(dataDir(), 0755); err != nil {
+		return err
+	}
+	return writeJSONLines(shelfPath(), sealed)
+}
+
+// shelve pauses the named timers and moves them to the shelf without
+// recording them. It is called with the lock held.
+func (d *daemon) shelve(names []string, now time.Time) response {
+	shelf, err := loadShelf()
+	if err != nil {
+		return response{Error: fmt.Sprintf("reading the shelf: %v", err)}
+	}
+	// Fail before taking the timers down if the shelf cannot be sealed.
+	if _, err := historyCipher(); err != nil {
+		return response{Error: fmt.Sprintf("writing the shelf: %v", err)}
+	}
+	for _, name := range names {
+		for _, s := range shelf {
+			if s.Timer.Name == name {
+				return response{Error: fmt.Sprintf("a timer named %q is shelved already", name), Code: codeExists}
+			}
+		}
+	}
+	for _, name := range append([]string(nil), names...) {
+		t := d.timers[name]
+		if !t.finished.IsZero() {
+			continue
+		}
+		t.pause(now, 0)
+		shelf = append(shelf, shelved{Shelved: now, Timer: t.snapshot()})
+		d.remove(name)
+	}
+	if err := saveShelf(shelf); err != nil {
+		return response{Error: fmt.Sprintf("writing the shelf: %v", err)}
+	}
+	d.persist(now)
+	return response{OK: true}
+}
+
+// unshelve takes the named timer, or every one when name is "", off the
+// shelf and resumes it where it was left. It is called with the lock
+// held.
+func (d *daemon) unshelve(name string, now time.Time) response {
+	shelf, err := loadShelf()
+	if err != nil {
+		return response{Error: fmt.Sprintf("reading the shelf: %v", err)}
+	}
+	var keep, take []shelved
+	for _, s := range shelf {
+		if name == "" || s.Timer.Name == name {
+			take = append(take, s)
+		} else {
+			keep = append(keep, s)
+		}
+	}
+	if len(take) == 0 {
+		return response{Error: "nothing shelved", Code: codeNotFound}
+	}
+	for _, s := range take {
+		if d.timers[s.Timer.Name] != nil {
+			return response{Error: fmt.Sprintf("timer %q is already running", s.Timer.Name), Code: codeExists}
+		}
+	}
+	for _, s := range take {
+		t := restoreTimer(s.Timer, s.Shelved, now)
+		t.bus, t.nag = d.bus, d.nag
+		t.resume(now)
+		if t.profile != nil {
+			runProfile(t, "on_start")
+		}
+		d.timers[t.name] = t
+		d.order = append(d.order, t.name)
+	}
+	if err := saveShelf(keep); err != nil {
+		return response{Error: fmt.Sprintf("writing the shelf: %v", err)}
+	}
+	d.persist(now)
+	return response{OK: true}
+}
+
+// shelfCommand implements `pomo shelve [name]` and `pomo unshelve
+// [name]`. Shelving sets the running timers, or the named one, aside
+// paused, e.g. at the end of the day, and unshelving picks them up again
+// with the time they had left. `pomo list` shows what is shelved.
+func shelfCommand(cmd string, args []string) {
+	if len(args) > 1 {
+		usage("pomo " + cmd + " [name]")
+	}
+	req := request{Cmd: cmd}
+	if len(args) == 1 {
+		req.Name = args[0]
+	}
+	if cmd == "shelve" {
+		if _, err := send(req); err != nil {
+			failSend(err)
+		}
+		succeed(nil)
+		return
+	}
+	if _, err := sendStarting(req); err != nil {
+		failSend(err)
+	}
+	succeed(nil)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"log"
+	"net"
+	"os"
+	"syscall"
+	"time"
+)
+
+// signals are those the daemon handles besides dumpSignals: termination,
+// including the terminal going away, and SIGUSR1 and SIGUSR2, which pause
+// and resume every timer, for keybindings and scripts written before pomo
+// talked over a socket.
+var signals = []os.Signal{syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT, syscall.SIGHUP, syscall.SIGUSR1, syscall.SIGUSR2}
+
+// onSignal handles s. It is called with the lock held.
+func (d *daemon) onSignal(s os.Signal, ln net.Listener, now time.Time) {
+	switch s {
+	case syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT, syscall.SIGHUP:
+		if d.persistent {
+			// A supervisor stopping the daemon, e.g. to restart it or
+			// for a reboot: leave the timers to the next one to recover,
+			// as after a crash.
+			d.persist(now)
+			d.exit(ln, true)
+		}
+		d.stopAll(now)
+		d.exit(ln, false)
+	// SIGUSR1 and SIGUSR2 do what pomo pause and pomo resume do, hooks
+	// and all, and then log the status as SIGINFO does.
+	case syscall.SIGUSR1:
+		d.signalled("pause", now)
+	case syscall.SIGUSR2:
+		d.signalled("resume", now)
+	default:
+		d.dump(now)
+	}
+}
+
+// signalled applies cmd to every timer, logging what became of it.
+func (d *daemon) signalled(cmd string, now time.Time) {
+	if resp := d.apply(request{Cmd: cmd}, now); !resp.OK {
+		log.Printf("Failed to %s on signal: %s", cmd, resp.Error)
+	}
+	d.dump(now)
+}
+
+// dump logs the status of every timer to daemon.log.
+func (d *daemon) dump(now time.Time) {
+	if len(d.order) == 0 {
+		log.Printf("Status: no timers")
+		return
+	}
+	for _, t := range d.list(d.order, now) {
+		state := t.Phase
+		if t.Paused {
+			state += ", paused"
+		}
+		line := "Status: " + t.Name + " " + state + ", " + t.Remaining.Round(time.Second).String() + " left"
+		if t.Task != "" {
+			line += ", " + t.Task
+		}
+		log.Print(line)
+	}
+}
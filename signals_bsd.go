@@ -0,0 +1,12 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// dumpSignals log the status without changing anything: SIGINFO, which
+// Ctrl-T sends on BSD and macOS terminals.
+var dumpSignals = []os.Signal{syscall.SIGINFO}
@@ -0,0 +1,13 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// dumpSignals log the status without changing anything. Linux has no
+// SIGINFO, so SIGWINCH stands in for it: the daemon has no terminal whose
+// size could change.
+var dumpSignals = []os.Signal{syscall.SIGWINCH}
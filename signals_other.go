@@ -0,0 +1,9 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package main
+
+import "os"
+
+// dumpSignals is empty where there is no SIGINFO; SIGUSR1 and SIGUSR2
+// still log the status after acting.
+var dumpSignals []os.Signal
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// slowLink notices when tmux answers slowly, e.g. through a socket
+// forwarded over SSH or under tmate, so that the status can be written
+// less often and kept short until it speeds up again.
+type slowLink struct {
+	after time.Duration // average round trip above which tmux is slow; 0 never
+	every time.Duration // least time between status updates while slow
+
+	mu      sync.Mutex
+	average time.Duration // moving average of recent round trips
+	slow    bool
+}
+
+// loadSlowLink reads status.slow_after and status.slow_every.
+func loadSlowLink(cfg config) *slowLink {
+	l := &slowLink{after: 250 * time.Millisecond, every: 15 * time.Second}
+	if v := cfg.get("status.slow_after", ""); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			l.after = d
+		} else {
+			log.Printf("Ignoring status.slow_after %q", v)
+		}
+	}
+	if d, err := time.ParseDuration(cfg.get("status.slow_every", "")); err == nil && d > 0 {
+		l.every = d
+	}
+	return l
+}
+
+// observe records how long a tmux update took. tmux is slow once the
+// average goes above after, and fast again once it falls under half that.
+func (l *slowLink) observe(took time.Duration) {
+	if l == nil || l.after == 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.average == 0 {
+		l.average = took
+	} else {
+		l.average = (3*l.average + took) / 4
+	}
+	switch {
+	case !l.slow && l.average > l.after:
+		log.Printf("tmux is slow to answer (%s), updating the status every %s", l.average.Round(time.Millisecond), l.every)
+		l.slow = true
+	case l.slow && l.average < l.after/2:
+		log.Printf("tmux answers quickly again (%s)", l.average.Round(time.Millisecond))
+		l.slow = false
+	}
+}
+
+// degraded reports whether tmux is slow.
+func (l *slowLink) degraded() bool {
+	if l == nil {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.slow
+}
+
+// pace holds up the next status update while tmux is slow. Updates queued
+// meanwhile are dropped in favour of the latest.
+func (l *slowLink) pace() {
+	if l.degraded() {
+		time.Sleep(l.every)
+	}
+}
+
+// minuteClock renders d in started minutes, e.g. "25m" for 24:30 left, with
+// a negative d shown as overtime, e.g. "+3m". A status shown this way
+// changes once a minute, which suits a slow tmux.
+func minuteClock(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign, d = "+", -d
+	}
+	return fmt.Sprintf("%s%dm", sign, int((d+time.Minute-time.Second)/time.Minute))
+}
+
+// minutes returns s with its clock in minutes.
+func (s phaseStyle) minutes() phaseStyle {
+	s.inMinutes = true
+	return s
+}
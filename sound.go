@@ -0,0 +1,81 @@
+package main
+
+import (
+	"cmp"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// sounds holds the audio settings from the [sound] section of the config.
+// Without a file for an event, the terminal bell is used instead.
+type sounds struct {
+	player string // command used to play files, e.g. "paplay"
+	alarm  string // played when a phase ends
+	tick   string // played while ticking
+	ticks  string // "off", "second" or "minute"
+	quiet  bool   // saving power: the bell rather than files, and no ticks
+}
+
+// loadSounds reads the sound settings from cfg.
+func loadSounds(cfg config) sounds {
+	return sounds{
+		player: cfg.get("sound.player", ""),
+		alarm:  expandHome(cfg.get("sound.alarm", "")),
+		tick:   expandHome(cfg.get("sound.tick_file", "")),
+		ticks:  cfg.get("sound.tick", "off"),
+	}
+}
+
+// expandHome replaces a leading ~ in path with the home directory.
+func expandHome(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		home, _ := os.UserHomeDir()
+		return filepath.Join(home, rest)
+	}
+	return path
+}
+
+// audioPlayer returns the configured player or the first one found on
+// PATH.
+func (s sounds) audioPlayer() string {
+	if s.player != "" {
+		return s.player
+	}
+	for _, p := range []string{"afplay", "paplay", "aplay"} {
+		if path, err := exec.LookPath(p); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// play plays file in the background, ringing the bell when no file or no
+// player is available.
+func (s sounds) play(file string) {
+	if simulated("play %s", cmp.Or(file, "bell")) {
+		return
+	}
+	player := s.audioPlayer()
+	if file == "" || player == "" || s.quiet {
+		beep()
+		return
+	}
+	args := append(strings.Fields(player), file)
+	cmd := exec.Command(args[0], args[1:]...)
+	if cmd.Start() == nil {
+		go cmd.Wait()
+	}
+}
+
+// shouldTick reports whether a tick is due with rem left in a work phase.
+func (s sounds) shouldTick(rem int) bool {
+	switch s.ticks {
+	case "second":
+		return true
+	case "minute":
+		return rem%60 == 0
+	}
+	return false
+}
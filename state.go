@@ -0,0 +1,292 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// persistEvery is how often the daemon saves its state while nothing
+// changes, bounding how much time is lost when it dies.
+const persistEvery = 30 * time.Second
+
+// savedTimer is the on-disk form of a running timer.
+type savedTimer struct {
+	Name          string        `json:"name"`
+	Phases        []phase       `json:"phases"`
+	Current       int           `json:"current"`
+	Task          string        `json:"task,omitempty"`
+	Given         string        `json:"given,omitempty"`
+	Inferred      string        `json:"inferred,omitempty"`
+	Note          string        `json:"note,omitempty"`
+	Project       string        `json:"project,omitempty"`
+	Tags          []string      `json:"tags,omitempty"`
+	Start         time.Time     `json:"start"`
+	End           time.Time     `json:"end"`
+	Paused        bool          `json:"paused,omitempty"`
+	Held          bool          `json:"held,omitempty"` // paused by a blocking hook rather than the user
+	Remaining     time.Duration `json:"remaining,omitempty"`
+	ResumeAt      time.Time     `json:"resume_at,omitempty"`
+	PausedAt      time.Time     `json:"paused_at,omitempty"`
+	Pauses        int           `json:"pauses,omitempty"`
+	PausedFor     time.Duration `json:"paused_for,omitempty"`
+	Interruptions int           `json:"interruptions,omitempty"`
+	Checkpoints   []checkpoint  `json:"checkpoints,omitempty"`
+	Pair          *pairing      `json:"pair,omitempty"`
+	Dest          *destination  `json:"dest,omitempty"`
+	Git           *gitInfo      `json:"git,omitempty"`
+	Repo          string        `json:"repo,omitempty"`
+	Profile       *profile      `json:"profile,omitempty"`
+}
+
+// savedState is what the daemon leaves on disk while it runs. A state file
+// that survives the daemon means it died without stopping its timers.
+type savedState struct {
+	Saved  time.Time    `json:"saved"`
+	Timers []savedTimer `json:"timers"`
+}
+
+func statePath() string {
+	return filepath.Join(dataDir(), "state.json")
+}
+
+// interruptedPath holds the state of a daemon that died, awaiting
+// `pomo recover`.
+func interruptedPath() string {
+	return filepath.Join(dataDir(), "interrupted.json")
+}
+
+// snapshot returns the on-disk form of t.
+func (t *timer) snapshot() savedTimer {
+	return savedTimer{
+		Name:          t.name,
+		Phases:        t.phases,
+		Current:       t.current,
+		Task:          t.task,
+		Given:         t.given,
+		Inferred:      t.inferred,
+		Note:          t.note,
+		Project:       t.project,
+		Tags:          t.tags,
+		Start:         t.startTime,
+		End:           t.endTime,
+		Paused:        t.paused,
+		Held:          t.held,
+		Remaining:     t.remaining,
+		ResumeAt:      t.resumeAt,
+		PausedAt:      t.pausedAt,
+		Pauses:        t.pauses,
+		PausedFor:     t.pausedFor,
+		Interruptions: t.interruptions,
+		Checkpoints:   t.checkpoints,
+		Pair:          t.pair,
+		Dest:          t.dest,
+		Profile:       t.profile,
+		Git:           t.git.Load(),
+		Repo:          t.repo,
+	}
+}
+
+// restoreTimer recreates a timer saved at saved, continuing its current
+// phase from where it was then. Time the daemon was down is not counted.
+func restoreTimer(st savedTimer, saved, now time.Time) *timer {
+	t := &timer{
+		name:          st.Name,
+		phases:        st.Phases,
+		current:       st.Current,
+		task:          st.Task,
+		given:         st.Given,
+		inferred:      st.Inferred,
+		note:          st.Note,
+		project:       st.Project,
+		tags:          st.Tags,
+		paused:        st.Paused,
+		held:          st.Held,
+		remaining:     st.Remaining,
+		resumeAt:      st.ResumeAt,
+		pausedAt:      st.PausedAt,
+		pauses:        st.Pauses,
+		pausedFor:     st.PausedFor,
+		interruptions: st.Interruptions,
+		checkpoints:   st.Checkpoints,
+		pair:          st.Pair,
+		pairSeen:      now,
+		dest:          st.Dest,
+		profile:       st.Profile,
+		repo:          st.Repo,
+	}
+	// Move the phase on by the downtime, so that it is counted neither as
+	// focus nor as a pause.
+	down := now.Sub(saved)
+	t.startTime, t.endTime = st.Start.Add(down), st.End.Add(down)
+	if !t.pausedAt.IsZero() {
+		t.pausedAt = t.pausedAt.Add(down)
+	}
+	t.git.Store(st.Git)
+	if t.held {
+		// The hook holding it died with the daemon, so nothing would
+		// release the hold: leave it paused from now for the user.
+		t.held, t.pausedAt = false, now
+	}
+	if t.paused && t.pausedAt.IsZero() {
+		// State saved before pauses were timed has no start for the
+		// current pause; count it from the restore.
+		t.pausedAt = now
+	}
+	return t
+}
+
+// readState reads a state file.
+func readState(path string) (savedState, error) {
+	var st savedState
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return st, err
+	}
+	if err := json.Unmarshal(data, &st); err != nil {
+		return st, err
+	}
+	timers := st.Timers[:0]
+	for _, t := range st.Timers {
+		if t.valid() {
+			timers = append(timers, t.unsealed())
+		}
+	}
+	st.Timers = timers
+	return st, nil
+}
+
+// valid reports whether t is on one of its phases, as a state file that
+// was edited by hand or cut short may leave it otherwise.
+func (t savedTimer) valid() bool {
+	return t.Current >= 0 && t.Current < len(t.Phases)
+}
+
+// persist saves the running timers to the state file. It is called with
+// the lock held.
+func (d *daemon) persist(now time.Time) {
+	if dryRun != nil {
+		return
+	}
+	st := savedState{Saved: now}
+	for _, name := range d.order {
+		if t := d.timers[name]; t.finished.IsZero() {
+			sealed, err := t.snapshot().sealed()
+			if err != nil {
+				log.Printf("Failed to save state: %v", err)
+				return
+			}
+			st.Timers = append(st.Timers, sealed)
+		}
+	}
+	d.persisted = now
+	if len(st.Timers) == 0 {
+		os.Remove(statePath())
+		return
+	}
+	data, err := json.Marshal(st)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(dataDir(), 0755); err != nil {
+		log.Printf("Failed to save state: %v", err)
+		return
+	}
+	tmp := statePath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err == nil {
+		err = os.Rename(tmp, statePath())
+	}
+	if err != nil {
+		log.Printf("Failed to save state: %v", err)
+	}
+}
+
+// recoverState deals with timers left behind by a daemon that died, as
+// configured by recovery.policy: "resume" restarts them, "log" records
+// them as interrupted, and "ask" (the default) sets them aside for
+// `pomo recover`.
+func (d *daemon) recoverState(policy string, now time.Time) {
+	st, err := readState(statePath())
+	if err != nil {
+		return
+	}
+	switch policy {
+	case "resume":
+		d.restore(st, now)
+	case "log":
+		logInterrupted(st)
+	default:
+		if err := os.Rename(statePath(), interruptedPath()); err != nil {
+			log.Printf("Failed to set aside interrupted state: %v", err)
+			return
+		}
+		msg := "pomo: an interrupted session was found; run `pomo recover resume` or `pomo recover log`"
+		tmuxMessage(msg)
+		return
+	}
+	os.Remove(statePath())
+}
+
+// restore adds the timers of st to the daemon. Timers whose names are
+// already in use are skipped.
+func (d *daemon) restore(st savedState, now time.Time) {
+	for _, saved := range st.Timers {
+		if d.timers[saved.Name] != nil || !saved.valid() {
+			continue
+		}
+		d.timers[saved.Name] = restoreTimer(saved, st.Saved, now)
+		d.timers[saved.Name].bus, d.timers[saved.Name].nag = d.bus, d.nag
+		d.order = append(d.order, saved.Name)
+	}
+}
+
+// logInterrupted records the work phases of st as abandoned when the
+// state was last saved.
+func logInterrupted(st savedState) {
+	for _, saved := range st.Timers {
+		t := restoreTimer(saved, st.Saved, st.Saved)
+		t.record(st.Saved, false)
+	}
+}
+
+// recoverCommand implements `pomo recover <resume|log>` for timers set
+// aside after the daemon died.
+func recoverCommand(args []string) {
+	fs := flag.NewFlagSet("recover", flag.ExitOnError)
+	args = parseFlags(fs, args)
+	st, err := readState(interruptedPath())
+	if err != nil {
+		if jsonOutput {
+			fail(codeNotFound, "no interrupted session to recover")
+		}
+		fmt.Println("No interrupted session to recover")
+		os.Exit(1)
+	}
+	if len(args) != 1 {
+		if jsonOutput {
+			writeResult(result{Error: &resultError{Code: codeUsage, Message: "usage: pomo recover <resume|log>"}, Data: st})
+			os.Exit(1)
+		}
+		for _, t := range st.Timers {
+			fmt.Printf("%s: %s phase, interrupted %s\n", t.Name, t.Phases[t.Current].Kind, st.Saved.Format("2006-01-02 15:04"))
+		}
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "resume":
+		if _, err := sendStarting(request{Cmd: "restore", State: &st}); err != nil {
+			failf(codeFailed, "Failed to resume: %v", err)
+		}
+	case "log":
+		logInterrupted(st)
+	default:
+		usage("pomo recover <resume|log>")
+	}
+	os.Remove(interruptedPath())
+	succeed(nil)
+}
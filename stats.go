@@ -0,0 +1,339 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+)
+
+// statsView is the state of the interactive `pomo stats` viewer.
+type statsView struct {
+	all    []session
+	breaks []takenBreak // breaks over the history, for focus scores
+	target int          // score.target
+	tags   []string     // every tag in the history, for the filter
+	week   bool         // show a week rather than a day
+	anchor time.Time    // a day in the period shown
+	tag    int          // 1 + index into tags of the filter; 0 for none
+	cursor int          // selected session
+	detail bool         // show the selected session in full
+	plain  bool         // no bar charts, for screen readers
+}
+
+// period returns the start and end of the day or week shown.
+func (v *statsView) period() (from, to time.Time) {
+	if v.week {
+		from = startOfWeek(v.anchor)
+		return from, from.AddDate(0, 0, 7)
+	}
+	from = startOfDay(v.anchor)
+	return from, from.AddDate(0, 0, 1)
+}
+
+// filter returns the tag sessions are filtered by, or "".
+func (v *statsView) filter() string {
+	if v.tag == 0 {
+		return ""
+	}
+	return v.tags[v.tag-1]
+}
+
+// sessions returns the sessions in the period that pass the tag filter.
+func (v *statsView) sessions() []session {
+	from, to := v.period()
+	var out []session
+	for _, s := range sessionsSince(v.all, from) {
+		if s.Start.Before(to) && (v.filter() == "" || slices.Contains(s.Tags, v.filter())) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// buckets returns the focus time per day of the week, or per hour of the
+// day, with a label for each.
+func (v *statsView) buckets(sessions []session) (labels []string, focus []time.Duration) {
+	from, _ := v.period()
+	if v.week {
+		for i := range 7 {
+			labels = append(labels, from.AddDate(0, 0, i).Format("Mon 02"))
+		}
+		focus = make([]time.Duration, 7)
+		for _, s := range sessions {
+			// Rounding absorbs daylight saving changes.
+			day := startOfDay(s.Start).Sub(from).Round(24 * time.Hour)
+			focus[int(day.Hours()/24)%7] += s.focused()
+		}
+		return labels, focus
+	}
+	// Hours with no sessions at either end of the day are left out.
+	first, last := 9, 17
+	for _, s := range sessions {
+		first, last = min(first, s.Start.Hour()), max(last, s.End.Hour())
+	}
+	for h := first; h <= last; h++ {
+		labels = append(labels, fmt.Sprintf("%02d:00 ", h))
+	}
+	focus = make([]time.Duration, len(labels))
+	for _, s := range sessions {
+		if i := s.Start.Hour() - first; i < len(focus) {
+			focus[i] += s.focused()
+		}
+	}
+	return labels, focus
+}
+
+// draw renders the viewer to the terminal.
+func (v *statsView) draw() {
+	cols, rows := terminalSize()
+	sessions := v.sessions()
+	v.cursor = min(max(v.cursor, 0), max(len(sessions)-1, 0))
+
+	var b strings.Builder
+	from, _ := v.period()
+	title := from.Format("Monday 2 January 2006")
+	if v.week {
+		title = "Week of " + from.Format("2 January 2006")
+	}
+	tag := v.filter()
+	if tag == "" {
+		tag = "all"
+	}
+	fmt.Fprintf(&b, "pomo stats  %s  (tag: %s)\n\n", title, tag)
+
+	labels, focus := v.buckets(sessions)
+	longest := slices.Max(focus)
+	width := max(cols-30, 10)
+	for i, label := range labels {
+		bar := ""
+		if !v.plain && longest > 0 {
+			n := int(float64(width) * float64(focus[i]) / float64(longest))
+			bar = strings.Repeat("█", n) + " "
+		}
+		fmt.Fprintf(&b, "  %-7s %s%s\n", label, bar, formatMinutes(focus[i]))
+	}
+
+	q := focusQuality(sessions)
+	var total time.Duration
+	for _, f := range focus {
+		total += f
+	}
+	fmt.Fprintf(&b, "\n  Completed %d/%d   Focus %s   Pauses %d   Interruptions %d\n",
+		q.completed, q.sessions, formatMinutes(total), q.pauses, q.interruptions)
+	// The trend runs up to the last day shown.
+	_, to := v.period()
+	away, _ := loadAway()
+	scores := dailyScores(v.all, v.breaks, away, to.AddDate(0, 0, -1), scoreDays, v.target)
+	if average := averageScore(scores); average >= 0 && v.plain {
+		fmt.Fprintf(&b, "  Focus score averaged %d over %d days\n", average, scoreDays)
+	} else if average >= 0 {
+		fmt.Fprintf(&b, "  Focus score  %s  %d day average %d\n", trendLine(scores), scoreDays, average)
+	}
+	b.WriteString("\n")
+
+	// The session list gets whatever rows are left.
+	used := strings.Count(b.String(), "\n") + 2
+	switch {
+	case len(sessions) == 0:
+		b.WriteString("  No sessions.\n")
+	case v.detail:
+		b.WriteString(sessionDetail(sessions[v.cursor]))
+	default:
+		room := max(rows-used, 1)
+		top := max(min(v.cursor-room/2, len(sessions)-room), 0)
+		for i := top; i < min(top+room, len(sessions)); i++ {
+			marker := "  "
+			if i == v.cursor {
+				marker = "> "
+			}
+			b.WriteString(marker + sessionLine(sessions[i], v.week) + "\n")
+		}
+	}
+	b.WriteString("\n←/→ period  d/w day/week  ↑/↓ select  enter details  t tag  q quit")
+
+	screen := "\033[H\033[2J" + strings.ReplaceAll(b.String(), "\n", "\r\n")
+	os.Stdout.WriteString(screen)
+}
+
+// summary describes the period shown for --output json.
+func (v *statsView) summary() map[string]any {
+	sessions := v.sessions()
+	labels, focus := v.buckets(sessions)
+	var buckets []map[string]any
+	var total time.Duration
+	for i, label := range labels {
+		buckets = append(buckets, map[string]any{"label": strings.TrimSpace(label), "focus": focus[i]})
+		total += focus[i]
+	}
+	from, to := v.period()
+	away, _ := loadAway()
+	q := focusQuality(sessions)
+	return map[string]any{
+		"from":          from.Format(time.DateOnly),
+		"until":         to.Format(time.DateOnly),
+		"buckets":       buckets,
+		"completed":     q.completed,
+		"sessions":      orEmpty(sessions),
+		"focus":         total,
+		"pauses":        q.pauses,
+		"interruptions": q.interruptions,
+		"scores":        dailyScores(v.all, v.breaks, away, to.AddDate(0, 0, -1), scoreDays, v.target),
+	}
+}
+
+// sessionLine summarises s on one line, with its weekday when week is set.
+func sessionLine(s session, week bool) string {
+	layout := "15:04"
+	if week {
+		layout = "Mon 15:04"
+	}
+	status := "done"
+	if !s.Completed {
+		status = "abandoned"
+	}
+	line := fmt.Sprintf("%-9s  %-6s %-9s  %s", s.Start.Format(layout), formatMinutes(s.End.Sub(s.Start)), status, s.Task)
+	if s.Project != "" {
+		line += " (" + s.Project + ")"
+	}
+	return line
+}
+
+// sessionDetail describes s in full.
+func sessionDetail(s session) string {
+	var b strings.Builder
+	status := "done"
+	if !s.Completed {
+		status = "abandoned"
+	}
+	fmt.Fprintf(&b, "  %s – %s  (%s planned, %s)\n", s.Start.Format("Mon 2 Jan 15:04"), s.End.Format("15:04"), formatMinutes(s.Duration), status)
+	for _, field := range [][2]string{
+		{"Task", s.Task},
+		{"Project", s.Project},
+		{"Tags", strings.Join(s.Tags, ", ")},
+		{"Note", s.Note},
+	} {
+		if field[1] != "" {
+			fmt.Fprintf(&b, "  %-14s %s\n", field[0], field[1])
+		}
+	}
+	fmt.Fprintf(&b, "  %-14s %d (%s)\n  %-14s %d\n", "Pauses", s.Pauses, formatClock(s.Paused), "Interruptions", s.Interruptions)
+	if g := s.Git; g != nil {
+		fmt.Fprintf(&b, "  %-14s %s %s\n", "Repository", g.Repo, g.Branch)
+		for _, c := range g.Commits {
+			fmt.Fprintf(&b, "    %s\n", c)
+		}
+	}
+	for _, c := range s.Checkpoints {
+		fmt.Fprintf(&b, "    %s  +%s  %s\n", c.At.Format("15:04"), formatClock(c.At.Sub(s.Start)), c.Note)
+	}
+	return b.String()
+}
+
+// key handles a key press. It reports whether the viewer should keep
+// running.
+func (v *statsView) key(k string) bool {
+	step := 1
+	if v.week {
+		step = 7
+	}
+	switch k {
+	case "q", "\x03", "\x1b":
+		if v.detail && k == "\x1b" {
+			v.detail = false
+			return true
+		}
+		return false
+	case "\x1b[D", "h":
+		v.anchor, v.cursor, v.detail = v.anchor.AddDate(0, 0, -step), 0, false
+	case "\x1b[C", "l":
+		v.anchor, v.cursor, v.detail = v.anchor.AddDate(0, 0, step), 0, false
+	case "\x1b[A", "k":
+		v.cursor--
+	case "\x1b[B", "j":
+		v.cursor++
+	case "d":
+		v.week, v.cursor, v.detail = false, 0, false
+	case "w":
+		v.week, v.cursor, v.detail = true, 0, false
+	case "t":
+		v.tag, v.cursor, v.detail = (v.tag+1)%(len(v.tags)+1), 0, false
+	case "\r", "\n":
+		v.detail = !v.detail
+	}
+	return true
+}
+
+// rawTerminal puts the terminal into raw mode with stty, returning a
+// function that restores it.
+func rawTerminal() (func(), error) {
+	saved, err := stty("-g")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return nil, err
+	}
+	return func() { stty(strings.TrimSpace(saved)) }, nil
+}
+
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// statsCommand implements `pomo stats`, an interactive viewer of the
+// history by day or week. With --output json it gives the first day or
+// week it would show.
+func statsCommand(args []string) {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	week := fs.Bool("week", false, "start with the current week rather than today")
+	parseFlags(fs, args)
+
+	sessions, err := loadSummary()
+	if err != nil {
+		failf(codeFailed, "Failed to read history: %v", err)
+	}
+	breaks, err := loadBreaks(time.Time{})
+	if err != nil {
+		failf(codeFailed, "Failed to read breaks: %v", err)
+	}
+	cfg := loadConfig()
+	v := &statsView{all: sessions, breaks: breaks, target: loadScoreTarget(cfg), week: *week, anchor: time.Now(), plain: accessible(cfg)}
+	for _, s := range sessions {
+		for _, tag := range s.Tags {
+			if !slices.Contains(v.tags, tag) {
+				v.tags = append(v.tags, tag)
+			}
+		}
+	}
+	slices.Sort(v.tags)
+	if jsonOutput {
+		succeed(v.summary())
+		return
+	}
+
+	restore, err := rawTerminal()
+	if err != nil {
+		failf(codeFailed, "Failed to set up the terminal: %v", err)
+	}
+	os.Stdout.WriteString("\033[?25l")
+	defer func() {
+		os.Stdout.WriteString("\033[?25h\033[H\033[2J")
+		restore()
+	}()
+
+	buf := make([]byte, 8)
+	for {
+		v.draw()
+		n, err := os.Stdin.Read(buf)
+		if err != nil || !v.key(string(buf[:n])) {
+			return
+		}
+	}
+}
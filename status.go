@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultStatusFormat is used when the config does not set status.format.
+const defaultStatusFormat = "{timer}"
+
+// renderStatus substitutes the {field} placeholders in format with the
+// given values. Unknown placeholders are left as they are.
+func renderStatus(format string, fields map[string]string) string {
+	pairs := make([]string, 0, 2*len(fields))
+	for k, v := range fields {
+		pairs = append(pairs, "{"+k+"}", v)
+	}
+	return strings.TrimSpace(strings.NewReplacer(pairs...).Replace(format))
+}
+
+// defaultTaskWidth is how many characters of the task name are shown
+// unless status.task_width says otherwise.
+const defaultTaskWidth = 20
+
+// phaseStyle holds the icon, label and tmux colour shown for each timer
+// state, and how much of the task name to show after the clock. In plain
+// mode timers are described in words instead.
+type phaseStyle struct {
+	icons, labels, colors map[string]string
+	taskWidth             int
+	taskTotal             bool // show the time spent on the task in all
+	plain                 bool
+	inMinutes             bool // clock in minutes rather than MM:SS
+	literal               bool // leave "#" as it is, for displays other than tmux
+}
+
+// loadPhaseStyle reads the [icons], [labels] and [colors] sections over
+// those of theme th.
+func loadPhaseStyle(cfg config, th theme) phaseStyle {
+	s := phaseStyle{
+		icons:     map[string]string{},
+		labels:    map[string]string{},
+		colors:    map[string]string{},
+		taskWidth: defaultTaskWidth,
+		taskTotal: cfg.get("status.task_total", "false") == "true",
+		plain:     accessible(cfg),
+	}
+	if n, err := strconv.Atoi(cfg.get("status.task_width", "")); err == nil && n >= 0 {
+		s.taskWidth = n
+	}
+	for _, state := range states {
+		s.icons[state] = cfg.get("icons."+state, th.icons[state])
+		s.labels[state] = cfg.get("labels."+state, th.labels[state])
+		s.colors[state] = cfg.get("colors."+state, th.colors[state])
+	}
+	return s
+}
+
+// render formats a status segment for a timer in state, e.g.
+// "☕ BREAK 04:59". A state without an icon, such as a custom phase kind,
+// falls back to the work icon and colour and its upper-cased name. The
+// done label follows the clock, and a negative clock is shown as overtime,
+// e.g. "+02:10".
+func (s phaseStyle) render(state, name string, clock time.Duration) string {
+	icon, ok := s.icons[state]
+	if s.plain {
+		if !ok {
+			state = s.escape(state)
+		}
+		return describe(state, name, clock)
+	}
+	label, color := s.labels[state], s.colors[state]
+	if !ok {
+		icon, label, color = s.icons["work"], s.escape(strings.ToUpper(strings.ReplaceAll(state, "_", " "))), s.colors["work"]
+	}
+	text := formatClock(clock)
+	if s.inMinutes {
+		text = minuteClock(clock)
+	}
+	if state == "done" {
+		text = strings.TrimSpace(text + " " + label)
+	} else if label != "" {
+		text = label + " " + text
+	}
+	text = strings.TrimSpace(icon + " " + name + text)
+	if color != "" {
+		text = "#[" + color + "] " + text + " #[default]"
+	}
+	return text
+}
+
+// formatClock renders d as MM:SS, with a negative d shown as overtime,
+// e.g. "+02:10".
+func formatClock(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign, d = "+", -d
+	}
+	d = d.Truncate(time.Second)
+	return fmt.Sprintf("%s%02d:%02d", sign, int(d.Minutes()), int(d.Seconds())%60)
+}
+
+// task renders the task name for the status, cut to the configured width
+// with an ellipsis. It is empty when there is no task or the width is 0.
+func (s phaseStyle) task(task string) string {
+	return s.escape(truncate(task, s.taskWidth))
+}
+
+// escape escapes text from users or panes with tmuxEscape, unless s is
+// for a display other than tmux.
+func (s phaseStyle) escape(text string) string {
+	if s.literal {
+		return text
+	}
+	return tmuxEscape(text)
+}
+
+// withTotal adds the time spent on task in all to it, e.g.
+// "(parser: 3h10m)".
+func (s phaseStyle) withTotal(task string, spent time.Duration) string {
+	if s.plain {
+		return fmt.Sprintf("%s, %s in all", task, formatMinutes(spent))
+	}
+	return "(" + task + ": " + formatMinutes(spent) + ")"
+}
+
+// taskFocus returns the focus time sessions in the history spent on task.
+func taskFocus(sessions []session, task string) time.Duration {
+	var spent time.Duration
+	for _, s := range sessions {
+		if s.Task == task {
+			spent += s.focused()
+		}
+	}
+	return spent
+}
+
+// truncate shortens s to at most width characters, ending it with "…"
+// when anything was cut.
+func truncate(s string, width int) string {
+	r := []rune(s)
+	if len(r) <= width {
+		return s
+	}
+	if width <= 1 {
+		return string(r[:width])
+	}
+	return strings.TrimSpace(string(r[:width-1])) + "…"
+}
+
+// historyFields computes the status fields that depend on the history.
+// They only change when a phase ends, so the daemon caches them.
+func historyFields(cfg config) map[string]string {
+	return map[string]string{
+		"burndown": burndownField(),
+		"budget":   budgetField(cfg),
+		"today":    todayField(accessible(cfg)),
+		"score":    scoreField(cfg),
+	}
+}
+
+// todayDots is how many of today's sessions {today} shows at most.
+const todayDots = 12
+
+// todayField renders the {today} status field: a dot per work session
+// today, ● when completed and ○ when abandoned, the latest on the right.
+// plain counts them in words instead.
+func todayField(plain bool) string {
+	sessions, _ := loadSessions()
+	var dots []string
+	completed := 0
+	for _, s := range sessionsSince(sessions, startOfDay(time.Now())) {
+		if s.Completed {
+			dots = append(dots, "●")
+			completed++
+		} else {
+			dots = append(dots, "○")
+		}
+	}
+	if plain {
+		if len(dots) == 0 {
+			return ""
+		}
+		return fmt.Sprintf("%d done, %d abandoned today", completed, len(dots)-completed)
+	}
+	if len(dots) > todayDots {
+		dots = append([]string{"…"}, dots[len(dots)-todayDots+1:]...)
+	}
+	return strings.Join(dots, "")
+}
+
+// burndown returns today's completed and planned pomodoro counts. planned
+// is zero when there is no plan for today.
+func burndown() (completed, planned int) {
+	p := loadPlan()
+	if len(p.Items) == 0 {
+		return 0, 0
+	}
+	sessions, _ := loadSessions()
+	done := completedByTask(sessionsSince(sessions, startOfDay(time.Now())))
+	for _, item := range p.Items {
+		planned += item.Estimate
+		completed += min(done[item.Task], item.Estimate)
+	}
+	return completed, planned
+}
+
+// burndownField renders the {burndown} status field, e.g. "3/8".
+func burndownField() string {
+	completed, planned := burndown()
+	if planned == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d/%d", completed, planned)
+}
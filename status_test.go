@@ -0,0 +1,96 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatusEscapesUserText(t *testing.T) {
+	now := time.Now()
+	testData(t)
+	tm := newTimer("a#(cmd)", []phase{{Kind: "work", Duration: 25 * time.Minute}}, "fix #[fg=red]", "", now)
+	style := loadPhaseStyle(config{}, themes[defaultTheme])
+
+	status := tm.status(now, true, style)
+	for _, want := range []string{"a##(cmd) ", "fix ##[fg=red]"} {
+		if !strings.Contains(status, want) {
+			t.Errorf("status %q does not contain %q", status, want)
+		}
+	}
+	if got := tm.status(now, true, style.uncolored()); !strings.Contains(got, "a#(cmd) ") || !strings.Contains(got, "fix #[fg=red]") {
+		t.Errorf("uncolored status %q escapes the name or task", got)
+	}
+}
+
+func TestInferredTitleEscaped(t *testing.T) {
+	now := time.Now()
+	testData(t)
+	// A program in the pane sets its title, which tmux reports as it is.
+	bin := t.TempDir()
+	script := "#!/bin/sh\necho '#(touch /tmp/x)'\n"
+	if err := os.WriteFile(filepath.Join(bin, "tmux"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin)
+
+	task := inferTask(config{"task.infer": "title"})
+	if task != "#(touch /tmp/x)" {
+		t.Fatalf("inferred task %q, want the pane title", task)
+	}
+	tm := newTimer("a", []phase{{Kind: "work", Duration: 25 * time.Minute}}, "", task, now)
+	status := tm.status(now, false, loadPhaseStyle(config{}, themes[defaultTheme]))
+	if !strings.Contains(status, "##(touch /tmp/x)") || strings.Contains(strings.ReplaceAll(status, "##", ""), "#(") {
+		t.Errorf("status %q runs the pane title as a command", status)
+	}
+}
+
+// dryRunOutput returns what f prints as a dry run, in which tmux commands
+// are printed rather than run.
+func dryRunOutput(t *testing.T, now time.Time, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(r)
+		out <- data
+	}()
+	stdout := os.Stdout
+	os.Stdout, dryRun = w, &simulation{start: now, now: now}
+	f()
+	os.Stdout, dryRun = stdout, nil
+	w.Close()
+	return string(<-out)
+}
+
+func TestMessagesEscapeNames(t *testing.T) {
+	now := time.Now()
+	d := testDaemon(t)
+	tm := newTimer("a#(cmd)", []phase{{Kind: "meeting", Duration: time.Minute}}, "", "", now)
+	d.timers[tm.name], d.order = tm, []string{tm.name}
+
+	out := dryRunOutput(t, now, func() { d.tick(now.Add(2 * time.Minute)) })
+	if !strings.Contains(out, "display-message -d 20000 a##(cmd): over time") {
+		t.Errorf("over time message not escaped:\n%s", out)
+	}
+}
+
+func TestRotateMessageEscapesNames(t *testing.T) {
+	now := time.Now()
+	d := testDaemon(t)
+	tm := newTimer("a", []phase{{Kind: "work", Duration: 25 * time.Minute}}, "", "", now)
+	tm.pair = &pairing{Driver: "#(x)", Navigator: "#[fg=red]", Rotate: 10 * time.Minute, Left: time.Second}
+	tm.pairSeen = now
+	d.timers[tm.name], d.order = tm, []string{tm.name}
+
+	out := dryRunOutput(t, now, func() { d.tick(now.Add(time.Minute)) })
+	if !strings.Contains(out, "Rotate: ##[fg=red] drives, ##(x) navigates") {
+		t.Errorf("rotate message not escaped:\n%s", out)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Sessions are only judged once there are enough of them to mean anything.
+const (
+	suggestMinSessions = 3
+	suggestGoodRate    = 0.8
+)
+
+// dayParts are the time-of-day buckets used for suggestions and for the
+// start.<part> default durations.
+var dayParts = []string{"morning", "afternoon", "evening"}
+
+// dayPart returns the time-of-day bucket t falls in.
+func dayPart(t time.Time) string {
+	switch h := t.Hour(); {
+	case h < 12:
+		return "morning"
+	case h < 17:
+		return "afternoon"
+	}
+	return "evening"
+}
+
+// defaultDuration returns the duration `pomo start` uses when none is
+// given: the one [days] sets for the day, start.<part of day>, then
+// start.duration, then 45 minutes.
+func defaultDuration(cfg config, now time.Time) string {
+	if d := dayDefault(cfg, now); d != "" && !hasProfile(cfg, d) {
+		return d
+	}
+	if d, ok := cfg["start."+dayPart(now)]; ok {
+		return d
+	}
+	return cfg.get("start.duration", "45m")
+}
+
+// rate counts how many of a group of sessions were completed.
+type rate struct {
+	completed, total int
+}
+
+func (r rate) value() float64 {
+	return float64(r.completed) / float64(r.total)
+}
+
+// completionRates groups fixed-length sessions by part of day and planned
+// duration.
+func completionRates(sessions []session) map[string]map[time.Duration]rate {
+	rates := map[string]map[time.Duration]rate{}
+	for _, s := range sessions {
+		// Flowtime sessions have no planned length to judge.
+		if s.Break > 0 || s.Duration == 0 {
+			continue
+		}
+		part := dayPart(s.Start)
+		if rates[part] == nil {
+			rates[part] = map[time.Duration]rate{}
+		}
+		r := rates[part][s.Duration]
+		r.total++
+		if s.Completed {
+			r.completed++
+		}
+		rates[part][s.Duration] = r
+	}
+	return rates
+}
+
+// suggestDuration picks the longest duration with a good completion rate
+// for a part of day. It falls back to two thirds of the shortest duration
+// tried, rounded to five minutes. ok is false without enough data.
+func suggestDuration(rates map[time.Duration]rate) (d time.Duration, ok bool) {
+	var durations []time.Duration
+	for d, r := range rates {
+		if r.total >= suggestMinSessions {
+			durations = append(durations, d)
+		}
+	}
+	if len(durations) == 0 {
+		return 0, false
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] > durations[j] })
+	for _, d := range durations {
+		if rates[d].value() >= suggestGoodRate {
+			return d, true
+		}
+	}
+	shortest := durations[len(durations)-1]
+	return max((shortest * 2 / 3).Round(5*time.Minute), 5*time.Minute), true
+}
+
+// suggestion is the length suggested for a part of the day, with the
+// completion rates it was judged on.
+type suggestion struct {
+	Part      string           `json:"part"`
+	Suggested time.Duration    `json:"suggested"`
+	Rates     []suggestionRate `json:"rates"` // shortest first
+}
+
+type suggestionRate struct {
+	Duration  time.Duration `json:"duration"`
+	Completed int           `json:"completed"`
+	Total     int           `json:"total"`
+}
+
+// suggestCommand implements `pomo suggest [--apply]`. It reports how often
+// sessions of each length are completed at each part of the day and
+// recommends lengths; --apply saves them as start.<part> defaults.
+func suggestCommand(args []string) {
+	fs := flag.NewFlagSet("suggest", flag.ExitOnError)
+	apply := fs.Bool("apply", false, "save the suggestions as default durations")
+	parseFlags(fs, args)
+
+	sessions, err := loadSessions()
+	if err != nil {
+		failf(codeFailed, "Failed to read history: %v", err)
+	}
+	rates := completionRates(sessions)
+
+	var suggested []suggestion
+	for _, part := range dayParts {
+		d, ok := suggestDuration(rates[part])
+		if !ok {
+			continue
+		}
+		sug := suggestion{Part: part, Suggested: d}
+
+		var durations []time.Duration
+		for d := range rates[part] {
+			durations = append(durations, d)
+		}
+		sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+		for _, dur := range durations {
+			r := rates[part][dur]
+			sug.Rates = append(sug.Rates, suggestionRate{dur, r.completed, r.total})
+			if jsonOutput {
+				continue
+			}
+			line := fmt.Sprintf("Your %s %s sessions complete %.0f%% of the time (%d/%d)", formatMinutes(dur), part, 100*r.value(), r.completed, r.total)
+			if r.total >= suggestMinSessions && r.value() < suggestGoodRate && dur > d {
+				line += fmt.Sprintf("; try %s", formatMinutes(d))
+			}
+			fmt.Println(line)
+		}
+		suggested = append(suggested, sug)
+		if !jsonOutput {
+			fmt.Printf("Suggested %s length: %s\n\n", part, formatMinutes(d))
+		}
+
+		if *apply {
+			if err := setConfig("start."+part, formatMinutes(d)); err != nil {
+				failf(codeFailed, "Failed to update config: %v", err)
+			}
+		}
+	}
+	if len(suggested) == 0 {
+		message := fmt.Sprintf("Not enough history yet: need %d sessions of a length at a time of day.", suggestMinSessions)
+		if !jsonOutput {
+			fmt.Println(message)
+		}
+		fail(codeFailed, message)
+	}
+	succeed(suggested)
+}
+
+// formatMinutes renders d compactly, e.g. "45m" or "1h30m".
+func formatMinutes(d time.Duration) string {
+	d = d.Round(time.Minute)
+	if d < time.Hour {
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	}
+	if d%time.Hour == 0 {
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dh%dm", int(d.Hours()), int(d.Minutes())%60)
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	// presenceEvery is how often a daemon repeats its presence while
+	// nothing changes.
+	presenceEvery = 30 * time.Second
+	// presenceExpiry is how long the board keeps a teammate who has gone
+	// quiet, e.g. because their machine went to sleep.
+	presenceExpiry = 2 * time.Minute
+	// boardMembers caps how many teammates a board keeps track of.
+	boardMembers = 200
+)
+
+// presence is what a teammate's daemon broadcasts to the team board.
+type presence struct {
+	Name      string        `json:"name"`
+	Phase     string        `json:"phase"` // as in timerInfo, or "idle"
+	Paused    bool          `json:"paused,omitempty"`
+	Remaining time.Duration `json:"remaining"`      // as of Seen ago; time spent so far in flow
+	Seen      time.Duration `json:"seen,omitempty"` // since the board last heard, filled in by the board
+}
+
+// teamServer returns the board's URL and access token from the [team]
+// section of cfg. The token may be a keyring entry.
+func teamServer(cfg config) (string, string, error) {
+	server := strings.TrimSuffix(cfg.get("team.server", ""), "/")
+	token, err := resolveSecret(cfg.get("team.token", ""))
+	return server, token, err
+}
+
+// teamRequest makes an HTTP request to the board at server.
+func teamRequest(ctx context.Context, method, server, token, path string, body any) (*http.Response, error) {
+	var data []byte
+	if body != nil {
+		data, _ = json.Marshal(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, server+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err == nil && resp.StatusCode >= 300 {
+		resp.Body.Close()
+		err = fmt.Errorf("board answered %s", resp.Status)
+	}
+	return resp, err
+}
+
+// broadcaster keeps the team board up to date with the daemon's timers.
+// Presence is only broadcast when team.broadcast is on.
+type broadcaster struct {
+	server, token, name string
+
+	mu      sync.Mutex
+	last    presence
+	sent    time.Time
+	sending bool
+	failing bool
+}
+
+// loadBroadcaster reads the [team] section of cfg. It returns nil unless
+// broadcasting is turned on.
+func loadBroadcaster(cfg config) *broadcaster {
+	server, token, err := teamServer(cfg)
+	if err != nil {
+		log.Printf("Failed to read team.token: %v", err)
+		return nil
+	}
+	if server == "" || cfg.get("team.broadcast", "false") != "true" {
+		return nil
+	}
+	return &broadcaster{server: server, token: token, name: cfg.get("team.name", os.Getenv("USER"))}
+}
+
+// presence returns what the daemon broadcasts: the first timer on show,
+// or idle. It is called with the lock held.
+func (d *daemon) presence(now time.Time) presence {
+	shown := d.shown(now)
+	if len(shown) == 0 {
+		shown = d.order
+	}
+	if len(shown) == 0 {
+		return presence{Phase: "idle"}
+	}
+	info := d.timers[shown[0]].info(now)
+	return presence{Phase: info.Phase, Paused: info.Paused, Remaining: info.Remaining}
+}
+
+// update broadcasts p when it changes phase, and otherwise every
+// presenceEvery, in the background.
+func (b *broadcaster) update(p presence, now time.Time) {
+	if b == nil {
+		return
+	}
+	p.Name = b.name
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	changed := p.Phase != b.last.Phase || p.Paused != b.last.Paused
+	if b.sending || !changed && now.Sub(b.sent) < presenceEvery {
+		return
+	}
+	b.last, b.sent = p, now
+	if simulated("broadcast %s to %s", p.Phase, b.server) {
+		return
+	}
+	b.sending = true
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), tmuxTimeout)
+		defer cancel()
+		resp, err := teamRequest(ctx, "PUT", b.server, b.token, "/presence/"+url.PathEscape(p.Name), p)
+		if err == nil {
+			resp.Body.Close()
+		}
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.sending = false
+		switch {
+		case err != nil && !b.failing:
+			log.Printf("Failed to update the team board: %v", err)
+			b.failing = true
+			// Try again on the next tick.
+			b.last = presence{}
+		case err != nil:
+			b.last = presence{}
+		case b.failing:
+			log.Printf("Team board updates recovered")
+			b.failing = false
+		}
+	}()
+}
+
+// leave takes the daemon off the board as it exits.
+func (b *broadcaster) leave() {
+	if b == nil || dryRun != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if resp, err := teamRequest(ctx, "DELETE", b.server, b.token, "/presence/"+url.PathEscape(b.name), nil); err == nil {
+		resp.Body.Close()
+	}
+}
+
+// board is the shared presence board served by `pomo board serve`.
+type board struct {
+	mu      sync.Mutex
+	members map[string]presence
+	heard   map[string]time.Time
+	token   string
+}
+
+// list returns the members heard from recently, with their time left as
+// of now.
+func (b *board) list(now time.Time) []presence {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.expire(now)
+	out := []presence{}
+	for name, p := range b.members {
+		p.Seen = now.Sub(b.heard[name])
+		switch {
+		case p.Paused || p.Phase == "idle" || p.Phase == "done":
+		case p.Phase == "flow":
+			p.Remaining += p.Seen
+		default:
+			p.Remaining -= p.Seen
+		}
+		out = append(out, p)
+	}
+	slices.SortFunc(out, func(a, b presence) int { return strings.Compare(a.Name, b.Name) })
+	return out
+}
+
+// expire drops the members not heard from for presenceExpiry. It is
+// called with the lock held.
+func (b *board) expire(now time.Time) {
+	for name := range b.members {
+		if now.Sub(b.heard[name]) > presenceExpiry {
+			delete(b.members, name)
+			delete(b.heard, name)
+		}
+	}
+}
+
+// ServeHTTP answers GET /presence, and PUT and DELETE /presence/<name>.
+func (b *board) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if b.token != "" && !tokenMatches(r.Header.Get("Authorization"), "Bearer "+b.token) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	name := r.PathValue("name")
+	switch r.Method {
+	case "GET":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(b.list(time.Now()))
+	case "PUT":
+		var p presence
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		p.Name, p.Seen = name, 0
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.expire(time.Now())
+		if _, ok := b.members[name]; !ok && len(b.members) >= boardMembers {
+			http.Error(w, "board full", http.StatusTooManyRequests)
+			return
+		}
+		b.members[name], b.heard[name] = p, time.Now()
+	case "DELETE":
+		b.mu.Lock()
+		delete(b.members, name)
+		delete(b.heard, name)
+		b.mu.Unlock()
+	}
+}
+
+// tokenMatches reports whether got is want, taking the same time whatever
+// it differs in so that a token can't be guessed from response times.
+func tokenMatches(got, want string) bool {
+	return subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
+
+// loopback reports whether the listen address addr is reachable only from
+// this machine.
+func loopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// boardCommand implements `pomo board`, which lists teammates on the board
+// at team.server, and `pomo board serve`, which runs a board.
+func boardCommand(args []string) {
+	cfg := loadConfig()
+	server, token, err := teamServer(cfg)
+	if err != nil {
+		failf(codeFailed, "Failed to read team.token: %v", err)
+	}
+	if len(args) > 0 && args[0] == "serve" {
+		fs := flag.NewFlagSet("board serve", flag.ExitOnError)
+		listen := fs.String("listen", cfg.get("team.listen", "127.0.0.1:7777"), "address to serve the board on")
+		parseFlags(fs, args[1:])
+		if token == "" && !loopback(*listen) {
+			failf(codeUsage, "Refusing to serve the board on %s without team.token", *listen)
+		}
+		b := &board{members: map[string]presence{}, heard: map[string]time.Time{}, token: token}
+		mux := http.NewServeMux()
+		mux.Handle("GET /presence", b)
+		mux.Handle("PUT /presence/{name}", b)
+		mux.Handle("DELETE /presence/{name}", b)
+		err := http.ListenAndServe(*listen, mux)
+		failf(codeFailed, "Failed to serve the board: %v", err)
+	}
+	if server == "" {
+		failf(codeUsage, "No team.server in the config")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	resp, err := teamRequest(ctx, "GET", server, token, "/presence", nil)
+	if err != nil {
+		failf(codeFailed, "Failed to reach the board: %v", err)
+	}
+	defer resp.Body.Close()
+	var members []presence
+	if err := json.NewDecoder(resp.Body).Decode(&members); err != nil {
+		failf(codeFailed, "Failed to read the board: %v", err)
+	}
+	if jsonOutput {
+		succeed(members)
+		return
+	}
+	if len(members) == 0 {
+		fmt.Println("Nobody is on the board.")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tPHASE\tREMAINING\tSEEN")
+	for _, p := range members {
+		phase, remaining := p.Phase, formatClock(p.Remaining)
+		if p.Paused {
+			phase += " (paused)"
+		}
+		if p.Phase == "idle" || p.Phase == "done" {
+			remaining = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s ago\n", p.Name, phase, remaining, p.Seen.Truncate(time.Second))
+	}
+	w.Flush()
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// weztermVar is the WezTerm user variable the status is written to, for
+// wezterm.lua to show.
+const weztermVar = "pomo"
+
+// terminalTab identifies the terminal tab a timer was started from, so
+// that its tab bar can show the status too.
+type terminalTab struct {
+	Kind   string `json:"kind"`             // "kitty" or "wezterm"
+	To     string `json:"to,omitempty"`     // kitty's remote control address
+	Window string `json:"window,omitempty"` // kitty window id
+	TTY    string `json:"tty,omitempty"`    // WezTerm's terminal device
+	Tmux   bool   `json:"tmux,omitempty"`   // the tty is a tmux pane, so escapes need passing through
+}
+
+// currentTerminalTab returns the tab pomo runs in when terminal.backend
+// names its terminal, or nil.
+func currentTerminalTab(cfg config) *terminalTab {
+	switch kind := cfg.get("terminal.backend", ""); kind {
+	case "":
+		return nil
+	case "kitty":
+		to := cfg.get("terminal.to", os.Getenv("KITTY_LISTEN_ON"))
+		if to == "" {
+			log.Printf("kitty has no remote control address: set listen_on in kitty.conf")
+			return nil
+		}
+		return &terminalTab{Kind: kind, To: to, Window: os.Getenv("KITTY_WINDOW_ID")}
+	case "wezterm":
+		cmd := exec.Command("tty")
+		cmd.Stdin = os.Stdin
+		out, err := cmd.Output()
+		if err != nil {
+			return nil
+		}
+		return &terminalTab{Kind: kind, TTY: strings.TrimSpace(string(out)), Tmux: os.Getenv("TMUX") != ""}
+	default:
+		log.Printf("Ignoring terminal.backend %q: use kitty or wezterm", kind)
+		return nil
+	}
+}
+
+// tabBar keeps a terminal's tab bar showing the status, in the background
+// like a statusWriter. Only the latest status is kept.
+type tabBar struct {
+	tab *terminalTab
+
+	mu      sync.Mutex
+	last    string
+	sending bool
+	failing bool
+}
+
+// show writes status to the tab bar if it changed and no write is under
+// way; a status skipped meanwhile is written on the next refresh.
+func (b *tabBar) show(status string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if status == b.last || b.sending {
+		return
+	}
+	b.last, b.sending = status, true
+	go func() {
+		err := b.tab.write(status)
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.sending = false
+		switch {
+		case err != nil && !b.failing:
+			log.Printf("Error updating the %s tab bar: %v", b.tab.Kind, err)
+			b.failing = true
+			b.last = ""
+		case err != nil:
+			b.last = ""
+		case b.failing:
+			log.Printf("%s tab bar updates recovered", b.tab.Kind)
+			b.failing = false
+		}
+	}()
+}
+
+// clear empties the tab bar as the daemon exits, giving kitty back its own
+// tab title.
+func (b *tabBar) clear() {
+	if b != nil {
+		b.tab.write("")
+	}
+}
+
+// write shows status in the tab: as kitty's tab title, or in WezTerm's
+// user variable.
+func (t *terminalTab) write(status string) error {
+	if t.Kind == "kitty" {
+		ctx, cancel := context.WithTimeout(context.Background(), tmuxTimeout)
+		defer cancel()
+		args := []string{"@", "--to", t.To, "set-tab-title"}
+		if t.Window != "" {
+			args = append(args, "--match", "window_id:"+t.Window)
+		}
+		if status != "" {
+			args = append(args, status)
+		}
+		return exec.CommandContext(ctx, "kitty", args...).Run()
+	}
+	f, err := os.OpenFile(t.TTY, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	seq := "\x1b]1337;SetUserVar=" + weztermVar + "=" + base64.StdEncoding.EncodeToString([]byte(status)) + "\a"
+	if t.Tmux {
+		// tmux hands the sequence on to the terminal it runs in, given
+		// allow-passthrough.
+		seq = "\x1bPtmux;" + strings.ReplaceAll(seq, "\x1b", "\x1b\x1b") + "\x1b\\"
+	}
+	_, err = f.WriteString(seq)
+	return err
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// states are the timer states a theme gives an icon, label and colour.
+var states = []string{"work", "flow", "break", "long_break", "meeting", "delay", "overtime", "paused", "done"}
+
+// theme bundles a look for the status: per-state icons, labels and tmux
+// styles (e.g. "fg=black,bg=yellow"), and a default status.format.
+type theme struct {
+	icons, labels, colors map[string]string
+	format                string
+}
+
+// defaultTheme is used when neither the config nor --theme picks one.
+const defaultTheme = "emoji"
+
+// themes are the built-in themes, selected with `theme = "name"` or
+// --theme. Config sections still override individual entries.
+var themes = map[string]theme{
+	"emoji": {
+		icons: map[string]string{
+			"work": "🍅", "flow": "🍅", "break": "☕", "long_break": "🌴", "meeting": "📅", "delay": "⏳", "overtime": "📅", "paused": "⏸", "done": "✅",
+		},
+		labels: map[string]string{
+			"flow": "FLOW", "break": "BREAK", "long_break": "LONG BREAK", "meeting": "MEETING", "delay": "starts in", "overtime": "OVERTIME", "paused": "PAUSED", "done": "passed",
+		},
+		colors: map[string]string{"overtime": "fg=red,bold"},
+	},
+	"minimal": {
+		labels: map[string]string{
+			"work": "w", "flow": "f", "break": "b", "long_break": "lb", "meeting": "m", "delay": "in", "overtime": "m", "paused": "p", "done": "done",
+		},
+		colors: map[string]string{"overtime": "fg=red"},
+	},
+	"nerd-font": {
+		icons: map[string]string{
+			"work": "", "flow": "", "break": "", "long_break": "", "delay": "", "paused": "", "done": "",
+		},
+		labels: map[string]string{"delay": "starts in", "done": "passed"},
+		colors: map[string]string{
+			"work": "fg=red", "flow": "fg=magenta", "break": "fg=green", "long_break": "fg=green",
+			"meeting": "fg=cyan", "delay": "fg=white", "overtime": "fg=red,bold", "paused": "fg=yellow", "done": "fg=blue",
+		},
+		format: "{timer} {burndown} {budget}",
+	},
+	"high-contrast": {
+		labels: map[string]string{
+			"work": "WORK", "flow": "FLOW", "break": "BREAK", "long_break": "LONG BREAK",
+			"meeting": "MEETING", "delay": "STARTS IN", "overtime": "OVERTIME", "paused": "PAUSED", "done": "DONE",
+		},
+		colors: map[string]string{
+			"work":       "fg=black,bg=yellow,bold",
+			"flow":       "fg=black,bg=cyan,bold",
+			"break":      "fg=black,bg=green,bold",
+			"long_break": "fg=black,bg=green,bold",
+			"meeting":    "fg=black,bg=cyan,bold",
+			"delay":      "fg=black,bg=white,bold",
+			"overtime":   "fg=white,bg=red,bold,blink",
+			"paused":     "fg=white,bg=red,bold",
+			"done":       "fg=black,bg=white,bold",
+		},
+		format: "{timer} {burndown} {budget}",
+	},
+}
+
+// lookupTheme returns the built-in theme called name.
+func lookupTheme(name string) (theme, error) {
+	th, ok := themes[name]
+	if !ok {
+		return theme{}, fmt.Errorf("unknown theme %q", name)
+	}
+	return th, nil
+}
+
+// useTheme switches the status to theme name, falling back to the default
+// theme when it is unknown. The config's own settings still win.
+func (d *daemon) useTheme(cfg config, name string) {
+	th, err := lookupTheme(name)
+	if err != nil {
+		th = themes[defaultTheme]
+	}
+	format := th.format
+	if format == "" {
+		format = defaultStatusFormat
+	}
+	d.format = cfg.get("status.format", format)
+	d.style = loadPhaseStyle(cfg, th)
+}
+
+// themesCommand implements `pomo themes`, listing the built-in themes with
+// a preview of a work phase and a break. Colours are left out, as they are
+// tmux styles.
+func themesCommand() {
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	current := loadConfig().get("theme", defaultTheme)
+	if jsonOutput {
+		succeed(map[string]any{"themes": names, "current": current})
+		return
+	}
+	for _, name := range names {
+		style := loadPhaseStyle(config{}, themes[name])
+		style.colors = nil
+		marker := " "
+		if name == current {
+			marker = "*"
+		}
+		fmt.Printf("%s %-14s %s   %s\n", marker, name,
+			style.render("work", "", 25*time.Minute), style.render("break", "", 5*time.Minute))
+	}
+}
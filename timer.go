@@ -0,0 +1,528 @@
+package main
+
+import (
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// finishedLinger is how long a finished timer stays visible before it is
+// removed from the status.
+const finishedLinger = 5 * time.Second
+
+// recording counts sessions still being recorded in the background, which
+// the daemon waits for before it exits.
+var recording sync.WaitGroup
+
+// timer runs a sequence of phases. All methods are called with the daemon
+// lock held.
+type timer struct {
+	name    string
+	phases  []phase
+	current int
+	task    string
+	project string
+
+	// taskSpent is the time the history spent on spentOn, the task, with
+	// status.task_total; spentOn is "" until it is looked up.
+	taskSpent time.Duration
+	spentOn   string
+
+	tags []string
+
+	given    string // task given when started, for every work phase
+	inferred string // task inferred from the pane started in, for work the plan names none for
+	note     string // recorded with every session
+	saved    string // ID of the session last recorded
+
+	startTime time.Time // start of the current phase
+	endTime   time.Time // end of the current phase when not paused
+
+	paused    bool
+	remaining time.Duration // remaining time when paused
+	resumeAt  time.Time     // when a pause with a limit ends; zero if none
+	pausedAt  time.Time     // when the current pause began
+	pausedFor time.Duration // earlier pauses of the current phase
+	warned    bool          // the current pause has outlasted pause.max
+	held      bool          // paused by hold rather than the user, until a blocking hook succeeds; a pause by the user ends it
+	cued      time.Duration // time left when cues were last checked; marks since then are due
+
+	// Counted for the current phase and recorded with it.
+	pauses        int
+	extended      time.Duration
+	interruptions int
+	checkpoints   []checkpoint
+	events        []spanEvent
+
+	bus  *eventBus               // integrations told about the timer; nil for none
+	nag  *breakNag               // nags about skipped breaks; nil for none
+	git  atomic.Pointer[gitInfo] // repository worked in, with git.record; refreshed off the lock
+	repo string                  // repository checked for uncommitted work, with git.nudge
+	exit *int                    // exit status of the phase's command, once known
+
+	dest    *destination // where the timer is shown; nil for the default
+	profile *profile     // session setup started with; nil for none
+
+	pair     *pairing  // nil unless pair programming
+	pairSeen time.Time // when the pair's turn was last counted
+
+	finished time.Time // when the last phase ended; zero while running
+	wrapUp   bool      // stop after the current phase, e.g. at the end of the workday
+	overdue  bool      // a meeting has run past its end
+
+	began bool // set when a phase begins, cleared once the daemon has reacted
+}
+
+// newTimer starts a timer running phases at now.
+func newTimer(name string, phases []phase, given, inferred string, now time.Time) *timer {
+	t := &timer{name: name, phases: phases, given: given, inferred: inferred}
+	t.begin(now)
+	return t
+}
+
+// phase returns the phase currently running.
+func (t *timer) phase() phase {
+	return t.phases[t.current]
+}
+
+// begin starts the current phase at now.
+func (t *timer) begin(now time.Time) {
+	t.startTime = now
+	t.endTime = now.Add(t.phase().Duration)
+	t.pauses, t.pausedFor, t.extended, t.interruptions, t.checkpoints, t.events = 0, 0, 0, 0, nil, nil
+	t.paused, t.held = false, false
+	t.began = true
+	if t.phase().Kind == "work" {
+		t.task = t.given
+		if t.task == "" {
+			t.task = nextPlanned()
+		}
+		if t.task == "" {
+			t.task = t.inferred
+		}
+		if g := t.git.Load(); g != nil {
+			go func() { t.git.CompareAndSwap(g, g.restart()) }()
+		}
+	}
+	t.publish("started", now)
+}
+
+// publish tells integrations that the current phase has just been ev.
+func (t *timer) publish(ev string, now time.Time) {
+	t.bus.publish(event{Type: ev, At: now, Timer: t.name, Phase: t.phase().Kind})
+}
+
+// pause freezes the remaining time of the current phase. A positive
+// resumeAfter resumes it automatically once that much time has passed.
+// Pausing a held phase turns the hold into a pause, which the hook then
+// leaves alone.
+func (t *timer) pause(now time.Time, resumeAfter time.Duration) {
+	if !t.finished.IsZero() {
+		return
+	}
+	if resumeAfter > 0 {
+		t.resumeAt = now.Add(resumeAfter)
+	} else {
+		t.resumeAt = time.Time{}
+	}
+	if t.paused && !t.held {
+		return
+	}
+	if !t.held {
+		t.remaining = t.endTime.Sub(now)
+	}
+	t.paused, t.held = true, false
+	t.pausedAt, t.warned = now, false
+	t.pauses++
+	t.events = append(t.events, spanEvent{"pause", now})
+	t.publish("paused", now)
+}
+
+// hold freezes the current phase like a pause until resume, e.g. while a
+// blocking hook runs. It is not one: it is not recorded or published, and
+// pause.max does not apply.
+func (t *timer) hold(now time.Time) {
+	if t.paused || !t.finished.IsZero() {
+		return
+	}
+	t.remaining = t.endTime.Sub(now)
+	t.paused, t.held = true, true
+}
+
+// resume continues a paused or held phase.
+func (t *timer) resume(now time.Time) {
+	if !t.paused {
+		return
+	}
+	t.endTime = now.Add(t.remaining)
+	if t.held {
+		t.paused, t.held = false, false
+		t.resumeAt = time.Time{}
+		return
+	}
+	t.pausedFor += now.Sub(t.pausedAt)
+	t.paused, t.held = false, false
+	t.resumeAt = time.Time{}
+	t.events = append(t.events, spanEvent{"resume", now})
+	t.publish("resumed", now)
+}
+
+// pausedTotal returns how long the current phase has been paused up to
+// now, including any pause in progress.
+func (t *timer) pausedTotal(now time.Time) time.Duration {
+	if t.paused && !t.held {
+		return t.pausedFor + max(now.Sub(t.pausedAt), 0)
+	}
+	return t.pausedFor
+}
+
+// taken returns how long the current phase has run by now, not counting
+// pauses.
+func (t *timer) taken(now time.Time) time.Duration {
+	return now.Sub(t.startTime) - t.pausedTotal(now)
+}
+
+// interrupt notes an interruption of the current work phase without
+// pausing it.
+func (t *timer) interrupt(now time.Time) {
+	if t.finished.IsZero() && t.phase().Kind == "work" {
+		t.interruptions++
+		t.events = append(t.events, spanEvent{"interruption", now})
+	}
+}
+
+// checkpoint notes a milestone in the current work phase.
+func (t *timer) checkpoint(now time.Time, note string) {
+	if t.finished.IsZero() && t.phase().Kind == "work" {
+		t.checkpoints = append(t.checkpoints, checkpoint{At: now, Note: note})
+		t.events = append(t.events, spanEvent{"checkpoint: " + note, now})
+	}
+}
+
+// openEnded reports whether the current phase runs until it is skipped,
+// as flowtime work does. Its endTime is when it started, less any pauses.
+func (t *timer) openEnded() bool {
+	return t.phase().Duration == 0
+}
+
+// extend adds d to the current phase.
+func (t *timer) extend(d time.Duration) {
+	if !t.finished.IsZero() || t.openEnded() {
+		return
+	}
+	if t.paused {
+		t.remaining += d
+	} else {
+		t.endTime = t.endTime.Add(d)
+	}
+	t.extended += d
+	t.overdue = false
+}
+
+// skip ends the current phase early and moves on to the next one.
+func (t *timer) skip(now time.Time) {
+	if !t.finished.IsZero() {
+		return
+	}
+	if t.openEnded() && t.phase().Kind == "work" {
+		// Ending flowtime work earns a break in proportion to it.
+		s := t.session(now, true)
+		s.Break = time.Duration(float64(s.Duration) * t.phase().Ratio).Truncate(time.Second)
+		t.save(s)
+		if s.Break > 0 {
+			t.phases = slices.Insert(t.phases, t.current+1, phase{Kind: "break", Duration: s.Break})
+		}
+	} else {
+		t.record(now, false)
+	}
+	t.publish("phase_ended", now)
+	t.paused, t.held = false, false
+	if t.current == len(t.phases)-1 {
+		t.finished = now
+		return
+	}
+	t.current++
+	t.begin(now)
+}
+
+// session returns the history entry for the current phase ending at end.
+func (t *timer) session(end time.Time, completed bool) session {
+	s := session{
+		Start:         t.startTime,
+		End:           end,
+		Duration:      t.phase().Duration,
+		Task:          t.task,
+		Note:          t.note,
+		Project:       t.project,
+		Tags:          t.tags,
+		Completed:     completed,
+		Pauses:        t.pauses,
+		Paused:        t.pausedTotal(end),
+		Interruptions: t.interruptions,
+		Checkpoints:   t.checkpoints,
+		Git:           t.git.Load(),
+		Command:       t.phase().Command,
+		Exit:          t.exit,
+	}
+	if t.openEnded() {
+		// Flowtime work has no target, so whatever was done counts.
+		s.Duration = t.left(end)
+		s.Completed = true
+	}
+	return s
+}
+
+// record logs the current phase to the history if it is a work phase, to
+// the breaks file if it is a break, or to the meetings file if it is a
+// meeting.
+func (t *timer) record(end time.Time, completed bool) {
+	switch t.phase().Kind {
+	case "work":
+		t.save(t.session(end, completed))
+	case "break", "long break":
+		t.nag.recordBreak(takenBreak{
+			Start:     t.startTime,
+			End:       end,
+			Planned:   t.phase().Duration,
+			Taken:     t.taken(end),
+			Completed: completed,
+			Kind:      t.phase().Kind,
+			Pauses:    t.pauses,
+			Paused:    t.pausedTotal(end),
+			Extended:  t.extended,
+		})
+	case "meeting":
+		m := meeting{
+			Start:   t.startTime,
+			End:     end,
+			Planned: t.phase().Duration,
+			Overrun: max(-t.left(end), 0),
+			Name:    t.name,
+			Project: t.project,
+		}
+		if err := appendMeeting(m); err != nil {
+			log.Printf("Failed to record meeting: %v", err)
+		}
+	}
+}
+
+// save gives s an ID and appends it to the history, logging any failure,
+// and publishes it. The commits of a session in a repository are collected
+// first, in the background so that git doesn't hold up the daemon.
+func (t *timer) save(s session) {
+	s.ID = newUUID()
+	bus, name, events := t.bus, t.name, t.events
+	store := func() error {
+		err := appendSession(s)
+		if err != nil {
+			log.Printf("Failed to record session: %v", err)
+		}
+		bus.recorded(name, s, events)
+		return err
+	}
+	if s.Git == nil {
+		t.saved = s.ID
+		if store() != nil {
+			t.saved = ""
+		}
+		return
+	}
+	// Taken to be recorded, as the check-in for it is asked for at once.
+	t.saved = s.ID
+	recording.Add(1)
+	go func() {
+		defer recording.Done()
+		s.Git = s.Git.finish(s.Start)
+		store()
+	}()
+}
+
+// stop records the current phase, if the timer is still running, as
+// outcome: "complete", "abandon", or "discard" to leave it out of the
+// history.
+func (t *timer) stop(now time.Time, outcome string) {
+	if t.finished.IsZero() && outcome != "discard" {
+		t.record(now, outcome == "complete")
+	}
+	if t.finished.IsZero() {
+		t.publish("stopped", now)
+	}
+}
+
+// tick advances the timer to now. It reports whether a phase ended.
+// Phases that wait, such as meetings, never end by themselves; they run
+// into overtime instead.
+func (t *timer) tick(now time.Time) bool {
+	if t.paused && !t.resumeAt.IsZero() && !now.Before(t.resumeAt) {
+		t.resume(now)
+	}
+	if t.paused || !t.finished.IsZero() || t.openEnded() || t.waits() || now.Before(t.endTime) {
+		return false
+	}
+	t.record(t.endTime, true)
+	t.publish("phase_ended", t.endTime)
+	if t.current == len(t.phases)-1 || t.wrapUp {
+		t.finished = t.endTime
+		return true
+	}
+	// Move on to the next phase of the sequence, from when the last one
+	// ended rather than from when a tick noticed, so that a late tick
+	// does not push back the rest of the sequence.
+	t.current++
+	t.begin(t.endTime)
+	return true
+}
+
+// waits reports whether the current phase only ends when stopped, running
+// into overtime instead: meetings, and commands run by `pomo run` until
+// they exit.
+func (t *timer) waits() bool {
+	return t.phase().Kind == "meeting" || t.phase().Command != "" && t.exit == nil
+}
+
+// overran reports, once, that a phase that waits has run past its planned
+// end.
+func (t *timer) overran(now time.Time) bool {
+	if t.overdue || !t.waits() || t.left(now) >= 0 {
+		return false
+	}
+	t.overdue = true
+	return true
+}
+
+// previous returns the phase tick has just ended.
+func (t *timer) previous() phase {
+	if !t.finished.IsZero() {
+		return t.phase()
+	}
+	return t.phases[t.current-1]
+}
+
+// ended describes the phase change tick has just made, for alerts.
+func (t *timer) ended() string {
+	if !t.finished.IsZero() {
+		return t.name + ": " + t.phase().Kind + " finished"
+	}
+	return t.name + ": " + t.previous().Kind + " over, " + t.phase().Kind + " started"
+}
+
+// rotate counts the time since it was last called against the pair's
+// turn, if the timer is working. It reports whether the pair swapped.
+func (t *timer) rotate(now time.Time) bool {
+	if t.pair == nil {
+		return false
+	}
+	elapsed := now.Sub(t.pairSeen)
+	t.pairSeen = now
+	return t.working() && t.pair.advance(elapsed)
+}
+
+// expired reports whether the timer has finished and been shown as such
+// for long enough to be removed.
+func (t *timer) expired(now time.Time) bool {
+	return !t.finished.IsZero() && now.Sub(t.finished) >= finishedLinger
+}
+
+// working reports whether the timer is counting down a work phase.
+func (t *timer) working() bool {
+	return t.finished.IsZero() && !t.paused && t.phase().Kind == "work"
+}
+
+// left returns the time remaining in the current phase, or for an
+// open-ended phase the time spent in it so far.
+func (t *timer) left(now time.Time) time.Duration {
+	rem := t.endTime.Sub(now).Truncate(time.Second)
+	switch {
+	case !t.finished.IsZero():
+		return 0
+	case t.paused:
+		rem = t.remaining
+	}
+	if t.openEnded() {
+		return -rem
+	}
+	return rem
+}
+
+// info describes the timer for `pomo list`.
+func (t *timer) info(now time.Time) timerInfo {
+	kind := t.phase().Kind
+	switch {
+	case !t.finished.IsZero():
+		kind = "done"
+	case t.openEnded():
+		kind = "flow"
+	}
+	var ends time.Time
+	if t.finished.IsZero() && !t.paused && !t.openEnded() {
+		ends = t.endTime
+	}
+	return timerInfo{
+		Name:      t.name,
+		Ends:      ends,
+		Phase:     kind,
+		Remaining: t.left(now),
+		Paused:    t.paused,
+		Held:      t.held,
+		Pauses:    t.pauses,
+		PausedFor: t.pausedTotal(now),
+		Task:      t.task,
+		Project:   t.project,
+	}
+}
+
+// state names what the timer is doing for display: "paused", "done",
+// "flow", "overtime", or the current phase kind with spaces as
+// underscores.
+func (t *timer) state() string {
+	switch {
+	case !t.finished.IsZero():
+		return "done"
+	case t.paused:
+		return "paused"
+	case t.openEnded():
+		return "flow"
+	case t.overdue:
+		return "overtime"
+	}
+	return strings.ReplaceAll(t.phase().Kind, " ", "_")
+}
+
+// status renders the timer for the tmux status line, prefixed with its
+// name when withName is set.
+func (t *timer) status(now time.Time, withName bool, style phaseStyle) string {
+	name := ""
+	if withName {
+		name = style.escape(t.name) + " "
+	}
+	if !t.finished.IsZero() {
+		return style.render("done", name, t.finished.Sub(t.startTime).Truncate(time.Second))
+	}
+	status := style.render(t.state(), name, t.left(now))
+	sep := " "
+	if style.plain {
+		sep = ", "
+	}
+	if t.pair != nil {
+		status += sep + style.escape(t.pair.label(style.plain))
+	}
+	if task := style.task(t.task); task != "" {
+		if style.taskTotal && t.spentOn == t.task {
+			task = style.withTotal(task, t.taskSpent+t.worked(now))
+		}
+		status += sep + task
+	}
+	return status
+}
+
+// worked returns how long the current phase has been worked so far, or
+// zero outside work.
+func (t *timer) worked(now time.Time) time.Duration {
+	if t.phase().Kind != "work" {
+		return 0
+	}
+	return max(now.Sub(t.startTime)-t.pausedTotal(now), 0)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+)
+
+// heldTimer returns a timer on its break, held at now as by a blocking
+// on_work_end hook.
+func heldTimer(t *testing.T, now time.Time) *timer {
+	t.Helper()
+	testData(t)
+	tm := newTimer("a", []phase{{Kind: "break", Duration: 5 * time.Minute}, {Kind: "work", Duration: 25 * time.Minute}}, "", "", now)
+	tm.hold(now)
+	return tm
+}
+
+func TestPauseWhileHeld(t *testing.T) {
+	now := time.Now()
+	tm := heldTimer(t, now)
+
+	tm.pause(now.Add(time.Minute), 0)
+	// Not held, the hook finishing leaves the pause alone.
+	if !tm.paused || tm.held || tm.pauses != 1 {
+		t.Fatalf("after pausing: paused %v, held %v, %d pauses; want paused, not held, 1 pause", tm.paused, tm.held, tm.pauses)
+	}
+	tm.resume(now.Add(3 * time.Minute))
+	if tm.paused {
+		t.Error("still paused after resuming")
+	}
+	if got := tm.pausedTotal(now.Add(3 * time.Minute)); got != 2*time.Minute {
+		t.Errorf("paused for %s, want 2m0s from the pause on", got)
+	}
+	if got := tm.endTime.Sub(now.Add(3 * time.Minute)); got != 5*time.Minute {
+		t.Errorf("%s left after resuming, want 5m0s", got)
+	}
+}
+
+func TestSkipEndsHold(t *testing.T) {
+	now := time.Now()
+	tm := heldTimer(t, now)
+
+	tm.skip(now.Add(time.Minute))
+	if tm.paused || tm.held {
+		t.Fatalf("after skipping: paused %v, held %v", tm.paused, tm.held)
+	}
+	tm.pause(now.Add(2*time.Minute), 0)
+	if !tm.paused || tm.pauses != 1 {
+		t.Errorf("pause after skipping a hold: paused %v, %d pauses", tm.paused, tm.pauses)
+	}
+}
+
+func TestRestoreEndsHold(t *testing.T) {
+	now := time.Now()
+	tm := heldTimer(t, now)
+
+	later := now.Add(time.Hour)
+	restored := restoreTimer(tm.snapshot(), now, later)
+	if !restored.paused || restored.held {
+		t.Fatalf("restored: paused %v, held %v; want paused, not held", restored.paused, restored.held)
+	}
+	if got := restored.pausedTotal(later.Add(time.Minute)); got != time.Minute {
+		t.Errorf("paused for %s, want 1m0s from the restore on", got)
+	}
+	restored.resume(later.Add(time.Minute))
+	if got := restored.endTime.Sub(later.Add(time.Minute)); got != 5*time.Minute {
+		t.Errorf("%s left after resuming, want 5m0s", got)
+	}
+}
+
+func TestRestoreDoesNotCountDowntime(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	testData(t)
+	tm := newTimer("a", []phase{{Kind: "work", Duration: 25 * time.Minute}}, "parser", "", now)
+
+	saved := now.Add(10 * time.Minute)
+	later := saved.Add(time.Hour)
+	restored := restoreTimer(tm.snapshot(), saved, later)
+	end := later.Add(15 * time.Minute)
+	if !restored.endTime.Equal(end) {
+		t.Fatalf("restored timer ends at %s, want %s", restored.endTime, end)
+	}
+	restored.record(end, true)
+	sessions, err := loadSessions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 {
+		t.Fatalf("%d sessions recorded, want 1", len(sessions))
+	}
+	if got := sessions[0].focused(); got != 25*time.Minute {
+		t.Errorf("focused for %s, want 25m0s without the downtime", got)
+	}
+}
+
+func TestLateTickKeepsSchedule(t *testing.T) {
+	now := time.Now()
+	testData(t)
+	tm := newTimer("a", []phase{{Kind: "work", Duration: 25 * time.Minute}, {Kind: "break", Duration: 5 * time.Minute}}, "", "", now)
+
+	// A tick in low-power mode notices the end of work a minute late.
+	if !tm.tick(now.Add(26 * time.Minute)) {
+		t.Fatal("work did not end")
+	}
+	if want := now.Add(25 * time.Minute); !tm.startTime.Equal(want) {
+		t.Errorf("break started at %s, want %s when work ended", tm.startTime, want)
+	}
+	if want := now.Add(30 * time.Minute); !tm.endTime.Equal(want) {
+		t.Errorf("break ends at %s, want %s", tm.endTime, want)
+	}
+}
+
+func TestReadStateSkipsTimersOffTheirPhases(t *testing.T) {
+	testData(t)
+	work := []phase{{Kind: "work", Duration: 25 * time.Minute}}
+	st := savedState{Saved: time.Now(), Timers: []savedTimer{
+		{Name: "a", Phases: work},
+		{Name: "b", Phases: work, Current: 1},
+		{Name: "c", Phases: work, Current: -1},
+		{Name: "d"},
+	}}
+	data, err := json.Marshal(st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(statePath(), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readState(statePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Timers) != 1 || got.Timers[0].Name != "a" {
+		t.Errorf("read %d timers, want only a", len(got.Timers))
+	}
+}
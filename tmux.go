@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// tmuxSocket returns the socket of the tmux server pomo talks to: the one
+// named in $TMUX when run inside tmux, which is the innermost server when
+// nested, or else the default socket under $TMUX_TMPDIR, as tmux itself
+// would choose. Under sudo the invoking user's socket is used.
+func tmuxSocket() string {
+	if socket, _, _ := strings.Cut(os.Getenv("TMUX"), ","); socket != "" {
+		return socket
+	}
+	dir := os.Getenv("TMUX_TMPDIR")
+	if dir == "" {
+		dir = "/tmp"
+	}
+	uid := strconv.Itoa(os.Getuid())
+	if sudo := os.Getenv("SUDO_UID"); sudo != "" && uid == "0" {
+		uid = sudo
+	}
+	return filepath.Join(dir, "tmux-"+uid, "default")
+}
+
+// tmuxCommand returns a tmux command run against the server of tmuxSocket,
+// so that it reaches the right server whatever the environment of the
+// process that runs it.
+func tmuxCommand(args ...string) *exec.Cmd {
+	if simulated("tmux %s", strings.Join(args, " ")) {
+		return exec.Command("true")
+	}
+	return exec.Command("tmux", append([]string{"-S", tmuxSocket()}, args...)...)
+}
+
+// tmuxCommandContext is tmuxCommand with a context.
+func tmuxCommandContext(ctx context.Context, args ...string) *exec.Cmd {
+	if simulated("tmux %s", strings.Join(args, " ")) {
+		return exec.CommandContext(ctx, "true")
+	}
+	return exec.CommandContext(ctx, "tmux", append([]string{"-S", tmuxSocket()}, args...)...)
+}
+
+// tmuxBatch joins commands into the arguments of a single tmux invocation,
+// separated by ";", so that tmux applies them together and no client ever
+// sees some of them done and others not. An argument ending in ";" is
+// escaped so that tmux does not take it for a separator.
+func tmuxBatch(commands ...[]string) []string {
+	var args []string
+	for i, command := range commands {
+		if i > 0 {
+			args = append(args, ";")
+		}
+		for _, arg := range command {
+			if strings.HasSuffix(arg, ";") {
+				arg = strings.TrimSuffix(arg, ";") + `\;`
+			}
+			args = append(args, arg)
+		}
+	}
+	return args
+}
+
+// tmuxEscape doubles each "#" in text, so that tmux shows it rather than
+// reading it as a format, a style or a #(command). Anything with names
+// from users, panes or repositories in it needs escaping before it goes
+// into the status or a message.
+func tmuxEscape(text string) string {
+	return strings.ReplaceAll(text, "#", "##")
+}
+
+// shellQuote quotes s as a single word for sh.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// runShell returns a tmux command, as a string for commands such as
+// confirm-before that take one, running the shell command cmd. cmd is
+// quoted for tmux and its "#" escaped, as run-shell expands formats.
+func runShell(cmd string) string {
+	quoted := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "#", "##").Replace(cmd)
+	return `run-shell -b "` + quoted + `"`
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// widthEvery is how often the width of the tmux clients is checked.
+const widthEvery = 5 * time.Second
+
+// compactView switches the status to a shorter format on narrow terminals,
+// so the timer does not push the window list off the status line.
+type compactView struct {
+	below  int    // client width under which the status is compact; 0 disables
+	format string // status format used when compact
+	width  atomic.Int64
+}
+
+// loadCompactView reads status.compact_below and status.compact_format.
+func loadCompactView(cfg config) *compactView {
+	c := &compactView{below: 100, format: cfg.get("status.compact_format", defaultStatusFormat)}
+	if n, err := strconv.Atoi(cfg.get("status.compact_below", "")); err == nil && n >= 0 {
+		c.below = n
+	}
+	return c
+}
+
+// watch checks the width of the narrowest attached client every
+// widthEvery, for as long as the daemon runs.
+func (c *compactView) watch() {
+	if c.below == 0 {
+		return
+	}
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), tmuxTimeout)
+		out, err := tmuxCommandContext(ctx, "list-clients", "-F", "#{client_width}").Output()
+		cancel()
+		narrowest := 0
+		if err == nil {
+			for _, line := range strings.Fields(string(out)) {
+				if w, err := strconv.Atoi(line); err == nil && (narrowest == 0 || w < narrowest) {
+					narrowest = w
+				}
+			}
+		}
+		c.width.Store(int64(narrowest))
+		time.Sleep(widthEvery)
+	}
+}
+
+// narrow reports whether the status should be compact. It is not while no
+// client's width is known.
+func (c *compactView) narrow() bool {
+	w := c.width.Load()
+	return w > 0 && w < int64(c.below)
+}
+
+// compact returns s for a narrow status: icons without labels, except in
+// plain mode where the words are the point, and no task.
+func (s phaseStyle) compact() phaseStyle {
+	s.taskWidth = 0
+	if !s.plain {
+		labels := map[string]string{}
+		for state, label := range s.labels {
+			if s.icons[state] == "" {
+				// Without an icon the label is all that tells states apart.
+				labels[state] = label
+			}
+		}
+		s.labels = labels
+	}
+	return s
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// workday ends the day at a configured time: once it has passed, running
+// timers finish their current interval and stop instead of moving on.
+type workday struct {
+	set      bool
+	end      time.Duration // since midnight
+	notified time.Time     // day the "done for today" message was shown
+}
+
+// loadWorkday reads workday.end, e.g. "18:00".
+func loadWorkday(cfg config) workday {
+	end := cfg.get("workday.end", "")
+	if end == "" {
+		return workday{}
+	}
+	t, err := time.Parse("15:04", end)
+	if err != nil {
+		log.Printf("Ignoring workday.end %q: %v", end, err)
+		return workday{}
+	}
+	return workday{set: true, end: time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute}
+}
+
+// over reports whether now is past the end of the workday.
+func (w *workday) over(now time.Time) bool {
+	return w.set && !now.Before(startOfDay(now).Add(w.end))
+}
+
+// endedBetween reports whether the workday ended after from and by to,
+// on the day of to.
+func (w *workday) endedBetween(from, to time.Time) bool {
+	end := startOfDay(to).Add(w.end)
+	return w.set && from.Before(end) && !to.Before(end)
+}
+
+// finish shows the "done for today" message with the day's summary, once
+// a day.
+func (w *workday) finish(now time.Time) {
+	if day := startOfDay(now); !w.notified.Equal(day) {
+		w.notified = day
+		showMessage("Done for today. " + todaySummary())
+	}
+}
+
+// todaySummary describes today's work, e.g. "6 pomodoros completed, 2h30m
+// focused".
+func todaySummary() string {
+	sessions, _ := loadSessions()
+	var completed int
+	var focus time.Duration
+	for _, s := range sessionsSince(sessions, startOfDay(time.Now())) {
+		if s.Completed {
+			completed++
+		}
+		focus += s.focused()
+	}
+	return fmt.Sprintf("%d pomodoros completed, %s focused", completed, focus.Truncate(time.Minute))
+}
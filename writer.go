@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"log"
+	"maps"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tmuxTimeout bounds a single tmux call so a hung server cannot wedge the
+// display.
+const tmuxTimeout = 5 * time.Second
+
+// templateOption is the tmux user option holding a status-right that
+// contains a {pomo} placeholder, so it survives being overwritten. It is
+// unset once status-right no longer derives from it.
+const templateOption = "@pomo-template"
+
+// statusWriter writes status text to tmux from its own goroutine, so that a
+// slow, failing or panicking display never holds up the timers. Every
+// destination that changed is updated in a single tmux invocation, so that
+// tmux never shows some of them updated and others not. Only the latest
+// statuses are kept; intermediate ones are dropped.
+type statusWriter struct {
+	mu      sync.Mutex
+	pending map[destination]string // latest statuses, nil once taken
+	resets  map[destination]string // destinations to put back, with what they showed
+	shown   map[destination]string // destinations on show, with what they showed before pomo
+	wake    chan struct{}
+
+	link     *slowLink
+	cleanup  string // status.cleanup, see cleanupPolicy
+	template string // the user's status-right with a {pomo} placeholder, if any
+
+	writing sync.Mutex             // held while tmux is being updated
+	last    map[destination]string // statuses last written successfully
+	failing bool                   // the last tmux update failed
+	stopped bool
+}
+
+// newStatusWriter starts the display goroutine under a watchdog that
+// restarts it if it panics.
+func newStatusWriter(link *slowLink, cleanup string) *statusWriter {
+	w := &statusWriter{
+		wake:    make(chan struct{}, 1),
+		resets:  map[destination]string{},
+		shown:   map[destination]string{},
+		link:    link,
+		cleanup: cleanup,
+		last:    map[destination]string{},
+	}
+	go w.supervise()
+	return w
+}
+
+// show queues statuses, every destination's, to be written to tmux. The
+// global status line is kept out of the hidden sessions by giving them
+// their own option showing what it did before pomo. Destinations shown
+// before and left out now are put back the way they were in the same
+// update.
+func (w *statusWriter) show(statuses map[destination]string, hidden []string) {
+	w.mu.Lock()
+	all := maps.Clone(statuses)
+	for dest := range statuses {
+		w.track(dest)
+		if dest.Target != "" || dest.window() {
+			continue
+		}
+		for _, session := range hidden {
+			covered := destination{Display: dest.Display, Target: session}
+			if _, ok := statuses[covered]; ok {
+				continue
+			}
+			w.track(covered)
+			if all[covered] = w.shown[covered]; all[covered] == "" {
+				all[covered] = w.shown[dest]
+			}
+		}
+	}
+	for dest, original := range w.shown {
+		if _, ok := all[dest]; !ok {
+			w.resets[dest] = original
+			delete(w.shown, dest)
+		}
+	}
+	w.pending = all
+	w.mu.Unlock()
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// track notes what dest shows before pomo writes to it, unless it is on
+// show already. It is called with mu held.
+func (w *statusWriter) track(dest destination) {
+	if _, ok := w.shown[dest]; ok {
+		return
+	}
+	if original, ok := w.resets[dest]; ok {
+		// Shown again before it was put back.
+		w.shown[dest] = original
+		delete(w.resets, dest)
+		return
+	}
+	w.shown[dest] = w.original(dest)
+}
+
+// original returns what dest shows before pomo writes to it, as saved for
+// the next daemon. Only the global status-right supports a {pomo}
+// placeholder. It is called with mu held.
+func (w *statusWriter) original(dest destination) string {
+	if dest == (destination{Display: "status-right"}) {
+		w.template = statusTemplate()
+		if w.cleanup == "restore" {
+			return savedStatus()
+		}
+		return restingStatus()
+	}
+	return dest.saved()
+}
+
+// showing reports whether dest is on show.
+func (w *statusWriter) showing(dest destination) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, ok := w.shown[dest]
+	return ok
+}
+
+// reset returns the tmux commands that put dest back, showing original,
+// and forget the copy that savedStatus or saved made. Restoring
+// status-right puts back any template too, which then needs no copy; the
+// resting status keeps it, as it has lost the placeholder.
+func (w *statusWriter) reset(dest destination, original string) [][]string {
+	commands := [][]string{dest.resetCommand(original)}
+	if dest != (destination{Display: "status-right"}) {
+		commands = append(commands, dest.forgetCommand())
+	} else if w.cleanup == "restore" {
+		commands = append(commands, dest.forgetCommand(), []string{"set-option", "-gu", templateOption})
+	}
+	return commands
+}
+
+// supervise runs the display loop, restarting it after a panic, until the
+// writer is closed.
+func (w *statusWriter) supervise() {
+	for {
+		closed := false
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("Display loop panicked, restarting: %v", r)
+				}
+			}()
+			w.loop()
+			closed = true
+		}()
+		if closed {
+			return
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// loop writes the queued statuses to tmux until the writer is closed.
+func (w *statusWriter) loop() {
+	for range w.wake {
+		w.mu.Lock()
+		statuses, resets, template := w.pending, w.resets, w.template
+		w.pending, w.resets = nil, map[destination]string{}
+		w.mu.Unlock()
+		if statuses != nil || len(resets) > 0 {
+			w.write(statuses, resets, template)
+			w.link.pace()
+		}
+	}
+}
+
+// close waits for any update in progress, discards later ones and puts
+// every destination back the way it was. It must not be shown anything
+// afterwards.
+func (w *statusWriter) close() {
+	w.writing.Lock()
+	w.stopped = true
+	w.writing.Unlock()
+	w.mu.Lock()
+	var commands [][]string
+	for dest, original := range w.shown {
+		commands = append(commands, w.reset(dest, original)...)
+	}
+	for dest, original := range w.resets {
+		commands = append(commands, w.reset(dest, original)...)
+	}
+	w.mu.Unlock()
+	if len(commands) > 0 {
+		tmuxCommand(tmuxBatch(commands...)...).Run()
+	}
+	close(w.wake)
+}
+
+// write updates the destinations whose status changed and resets those
+// no longer shown, logging when updates start and stop failing.
+func (w *statusWriter) write(statuses, resets map[destination]string, template string) {
+	w.writing.Lock()
+	defer w.writing.Unlock()
+	if w.stopped {
+		return
+	}
+	var commands [][]string
+	for dest, original := range resets {
+		commands = append(commands, w.reset(dest, original)...)
+		delete(w.last, dest)
+	}
+	for dest, status := range statuses {
+		// Skip what has not visibly changed, e.g. while paused.
+		if last, ok := w.last[dest]; ok && status == last && !w.failing {
+			continue
+		}
+		value := status
+		if template != "" && dest == (destination{Display: "status-right"}) {
+			value = strings.ReplaceAll(template, "{pomo}", status)
+		}
+		commands = append(commands, dest.command(value))
+	}
+	if len(commands) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), tmuxTimeout)
+	defer cancel()
+	began := time.Now()
+	err := tmuxCommandContext(ctx, tmuxBatch(commands...)...).Run()
+	if err == nil {
+		w.link.observe(time.Since(began))
+	}
+	switch {
+	case err != nil && !w.failing:
+		log.Printf("Error updating tmux: %v", err)
+		w.failing = true
+	case err == nil && w.failing:
+		log.Printf("tmux updates recovered")
+		w.failing = false
+	}
+	if err == nil {
+		for dest, status := range statuses {
+			w.last[dest] = status
+		}
+	}
+}
+
+// statusTemplate returns the user's status-right when it contains a {pomo}
+// placeholder, saving it in templateOption first. Once pomo has written to
+// status-right the placeholder is gone, so the saved copy is used instead,
+// as long as status-right is still filled in from it; otherwise the user
+// has set another one since, and the copy is unset.
+func statusTemplate() string {
+	current, _ := tmuxCommand("show-option", "-gv", "status-right").Output()
+	status := strings.TrimRight(string(current), "\n")
+	if strings.Contains(status, "{pomo}") {
+		tmuxCommand(tmuxBatch([]string{"set-option", "-g", templateOption, status})...).Run()
+		return status
+	}
+	saved, _ := tmuxCommand("show-option", "-gqv", templateOption).Output()
+	template := strings.TrimRight(string(saved), "\n")
+	if template != "" && !filledIn(template, status) {
+		tmuxCommand("set-option", "-gu", templateOption).Run()
+		return ""
+	}
+	return template
+}
+
+// filledIn reports whether status is template with something, perhaps
+// nothing, in place of its {pomo} placeholders.
+func filledIn(template, status string) bool {
+	parts := strings.Split(template, "{pomo}")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	return regexp.MustCompile(`(?s)^` + strings.Join(parts, ".*") + `$`).MatchString(status)
+}
+
+// restingStatus is what status-right is reset to when pomo stops: the
+// user's template without pomo's segment, or nothing.
+func restingStatus() string {
+	saved, _ := tmuxCommand("show-option", "-gqv", templateOption).Output()
+	return strings.ReplaceAll(strings.TrimRight(string(saved), "\n"), "{pomo}", "")
+}